@@ -25,10 +25,16 @@ package config
 
 type PublishConf struct {
 	ParseSps string `json:"parse_sps"`
+	// whether the new publisher kick the old one, or it's rejected when stream is busy.
+	Takeover string `json:"takeover"`
 }
 
 func (this *PublishConf) initDefault() {
 	if this.ParseSps == "" {
 		this.ParseSps = "on"
 	}
+
+	if this.Takeover == "" {
+		this.Takeover = "off"
+	}
 }
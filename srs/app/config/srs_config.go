@@ -35,7 +35,10 @@ type SrsConfig struct {
 	ChunkSize      uint32                `json:"chunk_size"`
 	MaxConnections uint32                `json:"max_connection"`
 	pithy_print_ms int64                 `json:"pithy_print_ms"`
+	PingInterval   uint32                `json:"ping_interval"`
+	PingTimeout    uint32                `json:"ping_timeout"`
 	WorkDir        string                `json:"work_dir"`
+	Rtmps          *RtmpsConf            `json:"rtmps"`
 	VHosts         map[string]*VHostConf `json:"vhosts"`
 	subscribers    []*SrsAppSubscriber
 }
@@ -69,11 +72,19 @@ func (this *SrsConfig) initDefault() {
 		this.WorkDir = "./"
 	}
 
+	if this.Rtmps != nil {
+		this.Rtmps.initDefault()
+	}
+
 	for _, v := range this.VHosts {
 		v.initDefault()
 	}
 }
 
+func (this *SrsConfig) GetRtmpsEnabled() bool {
+	return this.Rtmps != nil && this.Rtmps.Enabled == "on"
+}
+
 func (this *SrsConfig) AddSubscriber(s *SrsAppSubscriber) {
 	this.subscribers = append(this.subscribers, s)
 }
@@ -216,6 +227,28 @@ func GetDvrPlan(vhost string) string {
 	return h.Dvr.DvrPlan
 }
 
+const SRS_CONF_DEFAULT_TIME_JITTER = "full"
+
+// the time jitter algorithm of player, full, zero or off.
+func GetTimeJitter(vhost string) string {
+	h := GetInstance().GetVHost(vhost)
+	if h == nil || h.Enabled != "on" || h.TimerJitter == "" {
+		return SRS_CONF_DEFAULT_TIME_JITTER
+	}
+
+	return h.TimerJitter
+}
+
+// the time jitter algorithm of dvr, full, zero or off.
+func GetDvrTimeJitter(vhost string) string {
+	h := GetInstance().GetVHost(vhost)
+	if h == nil || h.Enabled != "on" || h.Dvr == nil || h.Dvr.TimerJitter == "" {
+		return SRS_CONF_DEFAULT_TIME_JITTER
+	}
+
+	return h.Dvr.TimerJitter
+}
+
 const SRS_CONF_DEFAULT_1STPKT_TIMEOUT = 2000
 
 func GetPublish1stpktTimeout(vhost string) uint32 {
@@ -231,6 +264,19 @@ func GetPublish1stpktTimeout(vhost string) uint32 {
 	return SRS_CONF_DEFAULT_1STPKT_TIMEOUT
 }
 
+/**
+* whether the publisher takeover the stream which is publishing, the old publisher is kicked,
+* for the hot-standby encoder, the second publisher is rejected if off.
+ */
+func GetPublishTakeover(vhost string) bool {
+	h := GetInstance().GetVHost(vhost)
+	if h == nil || h.Enabled != "on" || h.Publish == nil {
+		return false
+	}
+
+	return h.Publish.Takeover == "on"
+}
+
 const SRS_CONF_DEFAULT_NORPKT_TIMEOUT = 5000
 
 func GetPublishNormalPktTimeout(vhost string) uint32 {
@@ -246,6 +292,133 @@ func GetPublishNormalPktTimeout(vhost string) uint32 {
 	return SRS_CONF_DEFAULT_NORPKT_TIMEOUT
 }
 
+// the destinations to forward the stream to, nil if vhost not found or disabled.
+func GetForward(vhost string) []string {
+	h := GetInstance().GetVHost(vhost)
+	if h == nil || h.Enabled != "on" {
+		return nil
+	}
+
+	return h.Forward
+}
+
+// the vhost is edge when mode is remote, which pull stream from and proxy publish to origin.
+const SRS_CONF_VHOST_MODE_REMOTE = "remote"
+
+func GetVhostIsEdge(vhost string) bool {
+	h := GetInstance().GetVHost(vhost)
+	if h == nil || h.Enabled != "on" {
+		return false
+	}
+
+	return h.Mode == SRS_CONF_VHOST_MODE_REMOTE
+}
+
+func GetVhostEdgeOrigin(vhost string) []string {
+	h := GetInstance().GetVHost(vhost)
+	if h == nil || h.Enabled != "on" {
+		return nil
+	}
+
+	return h.Origin
+}
+
+const SRS_CONF_DEFAULT_EDGE_IDLE_TIMEOUT = 10000
+
+// the time in ms to wait before stop pulling from origin, when the last player left edge.
+func GetEdgeIdleTimeout(vhost string) uint32 {
+	h := GetInstance().GetVHost(vhost)
+	if h == nil || h.Enabled != "on" {
+		return SRS_CONF_DEFAULT_EDGE_IDLE_TIMEOUT
+	}
+
+	return h.EdgeIdleTimeout
+}
+
+const SRS_CONF_DEFAULT_SOURCE_IDLE_TIMEOUT = 30000
+
+/**
+* the time in ms to keep the source without publisher and player, the player can wait for the publisher,
+* the default is used for 0, which reaps the source before client attach to it.
+ */
+func GetSourceIdleTimeout(vhost string) uint32 {
+	h := GetInstance().GetVHost(vhost)
+	if h == nil || h.Enabled != "on" || h.SourceIdleTimeout == 0 {
+		return SRS_CONF_DEFAULT_SOURCE_IDLE_TIMEOUT
+	}
+
+	return h.SourceIdleTimeout
+}
+
+const SRS_CONF_DEFAULT_REPUBLISH_TIMEOUT = 5000
+
+/**
+* the time in ms to keep the players when publisher stop, for the encoder to reconnect,
+* the players are disconnected when no publisher republish in this window.
+ */
+func GetRepublishTimeout(vhost string) uint32 {
+	h := GetInstance().GetVHost(vhost)
+	if h == nil || h.Enabled != "on" || h.RepublishTimeout == 0 {
+		return SRS_CONF_DEFAULT_REPUBLISH_TIMEOUT
+	}
+
+	return h.RepublishTimeout
+}
+
+const SRS_CONF_DEFAULT_QUEUE_LENGTH = 10
+
+/**
+* the max duration in seconds of the queue to send, for example, the forwarder,
+* the queue is shrinked when exceed, to drop the messages rather than block the source.
+ */
+func GetQueueLength(vhost string) uint32 {
+	h := GetInstance().GetVHost(vhost)
+	if h == nil || h.Enabled != "on" || h.QueueLength == 0 {
+		return SRS_CONF_DEFAULT_QUEUE_LENGTH
+	}
+
+	return h.QueueLength
+}
+
+const SRS_CONF_DEFAULT_MW_LATENCY = 350
+
+// the max time in ms to merge the messages for player, the latency increased by merged write.
+func GetMwLatency(vhost string) uint32 {
+	h := GetInstance().GetVHost(vhost)
+	if h == nil || h.Enabled != "on" || h.MwLatency == 0 {
+		return SRS_CONF_DEFAULT_MW_LATENCY
+	}
+
+	return h.MwLatency
+}
+
+const SRS_CONF_DEFAULT_MW_MSGS = 8
+
+// the max messages to merge for player, send when got the messages or the latency exceed.
+func GetMwMsgs(vhost string) uint32 {
+	h := GetInstance().GetVHost(vhost)
+	if h == nil || h.Enabled != "on" || h.MwMsgs == 0 {
+		return SRS_CONF_DEFAULT_MW_MSGS
+	}
+
+	return h.MwMsgs
+}
+
+const SRS_CONF_DEFAULT_SO_PERSIST_PATH = "./objects/[vhost]/[app]/[name].json"
+
+// the json file to store the persistent shared object, empty if persistence disabled.
+func GetSharedObjectPersistPath(vhost string) string {
+	h := GetInstance().GetVHost(vhost)
+	if h == nil || h.Enabled != "on" || h.SharedObject == nil || h.SharedObject.Persistence != "on" {
+		return ""
+	}
+
+	if h.SharedObject.PersistPath == "" {
+		return SRS_CONF_DEFAULT_SO_PERSIST_PATH
+	}
+	return h.SharedObject.PersistPath
+}
+
 const SRS_CONF_DEFAULT_PITHY_PRINT_MS = 10000
 
 func (this *SrsConfig) GetPithyPrintMs() int64 {
@@ -256,6 +429,28 @@ func (this *SrsConfig) GetPithyPrintMs() int64 {
 	return this.pithy_print_ms
 }
 
+const SRS_CONF_DEFAULT_PING_INTERVAL = 10000
+
+// the interval in ms to send PingRequest to the player and publisher.
+func (this *SrsConfig) GetPingInterval() uint32 {
+	if this.PingInterval == 0 {
+		return SRS_CONF_DEFAULT_PING_INTERVAL
+	}
+
+	return this.PingInterval
+}
+
+const SRS_CONF_DEFAULT_PING_TIMEOUT = 30000
+
+// the time in ms without PingResponse, after which the peer is dead and the connection is closed.
+func (this *SrsConfig) GetPingTimeout() uint32 {
+	if this.PingTimeout == 0 {
+		return SRS_CONF_DEFAULT_PING_TIMEOUT
+	}
+
+	return this.PingTimeout
+}
+
 var config *SrsConfig
 var once sync.Once
 
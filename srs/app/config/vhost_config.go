@@ -24,31 +24,39 @@ CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
 package config
 
 type VHostConf struct {
-	Enabled              string          `json:"enabled"`
-	MinLatency           string          `json:"min_latency"`
-	GopCache             string          `json:"gop_cache"`
-	QueueLength          uint32          `json:"queue_length"`
-	SendMinInterval      uint32          `json:"send_min_interval"`
-	ReduceSequenceHeader string          `json:"reduce_sequence_header"`
-	Publish1stPktTimeout uint32          `json:"publish_1stpkt_timeout"`
-	PublishNormalTimeout uint32          `json:"publish_normal_timeout"`
-	Forward              []string        `json:"forward"`
-	ChunkSize            uint32          `json:"chunk_size"`
-	TimerJitter          string          `json:"time_jitter"`
-	MixCorrect           string          `json:"mix_correct"`
-	Atc                  string          `json:"atc"`
-	AtcAuto              string          `json:"act_auto"`
-	HeartBeat            *HeartBeatConf  `json:"heartbeat"`
-	Stats                *StatsConf      `json:"stats"`
-	HttpApi              *HttpApiConf    `json:"http_api"`
-	HttpServer           *HttpServerConf `json:"http_server"`
-	Security             *SecurityConf   `json:"security"`
-	Dvr                  *DvrConf        `json:"dvr"`
-	HttpStatic           *HttpStaticConf `json:"http_static"`
-	HttpRemux            *HttpRemuxConf  `json:"http_remux"`
-	Hls                  *HlsConf        `json:"hls"`
-	HttpHooks            *HttpHooksConf  `json:"http_hooks"`
-	Publish              *PublishConf    `json:"publish"`
+	Enabled              string            `json:"enabled"`
+	MinLatency           string            `json:"min_latency"`
+	GopCache             string            `json:"gop_cache"`
+	QueueLength          uint32            `json:"queue_length"`
+	SendMinInterval      uint32            `json:"send_min_interval"`
+	MwLatency            uint32            `json:"mw_latency"`
+	MwMsgs               uint32            `json:"mw_msgs"`
+	ReduceSequenceHeader string            `json:"reduce_sequence_header"`
+	Publish1stPktTimeout uint32            `json:"publish_1stpkt_timeout"`
+	PublishNormalTimeout uint32            `json:"publish_normal_timeout"`
+	Forward              []string          `json:"forward"`
+	Mode                 string            `json:"mode"`
+	Origin               []string          `json:"origin"`
+	EdgeIdleTimeout      uint32            `json:"edge_idle_timeout"`
+	SourceIdleTimeout    uint32            `json:"source_idle_timeout"`
+	RepublishTimeout     uint32            `json:"republish_timeout"`
+	ChunkSize            uint32            `json:"chunk_size"`
+	TimerJitter          string            `json:"time_jitter"`
+	MixCorrect           string            `json:"mix_correct"`
+	Atc                  string            `json:"atc"`
+	AtcAuto              string            `json:"act_auto"`
+	HeartBeat            *HeartBeatConf    `json:"heartbeat"`
+	Stats                *StatsConf        `json:"stats"`
+	HttpApi              *HttpApiConf      `json:"http_api"`
+	HttpServer           *HttpServerConf   `json:"http_server"`
+	Security             *SecurityConf     `json:"security"`
+	Dvr                  *DvrConf          `json:"dvr"`
+	HttpStatic           *HttpStaticConf   `json:"http_static"`
+	HttpRemux            *HttpRemuxConf    `json:"http_remux"`
+	Hls                  *HlsConf          `json:"hls"`
+	HttpHooks            *HttpHooksConf    `json:"http_hooks"`
+	Publish              *PublishConf      `json:"publish"`
+	SharedObject         *SharedObjectConf `json:"shared_object"`
 }
 
 func (this *VHostConf) initDefault() {
@@ -61,6 +69,14 @@ func (this *VHostConf) initDefault() {
 		this.QueueLength = 10
 	}
 
+	if this.MwLatency == 0 {
+		this.MwLatency = SRS_CONF_DEFAULT_MW_LATENCY
+	}
+
+	if this.MwMsgs == 0 {
+		this.MwMsgs = SRS_CONF_DEFAULT_MW_MSGS
+	}
+
 	if this.ReduceSequenceHeader == "" {
 		this.ReduceSequenceHeader = "off"
 	}
@@ -73,6 +89,18 @@ func (this *VHostConf) initDefault() {
 		this.PublishNormalTimeout = 7000
 	}
 
+	if this.EdgeIdleTimeout == 0 {
+		this.EdgeIdleTimeout = SRS_CONF_DEFAULT_EDGE_IDLE_TIMEOUT
+	}
+
+	if this.SourceIdleTimeout == 0 {
+		this.SourceIdleTimeout = SRS_CONF_DEFAULT_SOURCE_IDLE_TIMEOUT
+	}
+
+	if this.RepublishTimeout == 0 {
+		this.RepublishTimeout = SRS_CONF_DEFAULT_REPUBLISH_TIMEOUT
+	}
+
 	if this.ChunkSize == 0 {
 		this.ChunkSize = 65000
 	}
@@ -136,4 +164,8 @@ func (this *VHostConf) initDefault() {
 	if this.Publish != nil {
 		this.Publish.initDefault()
 	}
+
+	if this.SharedObject != nil {
+		this.SharedObject.initDefault()
+	}
 }
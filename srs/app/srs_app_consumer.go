@@ -26,8 +26,13 @@ package app
 import (
 	"errors"
 	log "github.com/sirupsen/logrus"
+	"go_srs/srs/app/config"
+	"go_srs/srs/codec/flv"
 	"go_srs/srs/protocol/packet"
 	"go_srs/srs/protocol/rtmp"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type ConsumerStopListener interface {
@@ -41,6 +46,20 @@ type SrsConsumer struct {
 	StreamId        int
 	queueRecvThread *SrsQueueRecvThread
 	consuming       bool
+	// when paused, the messages are cached from the last keyframe, to resume from the gop.
+	paused     bool
+	pausedGop  bool
+	pausedMsgs []*rtmp.SrsRtmpMessage
+	// when resume without gop, drop the video util the next keyframe.
+	waitKeyframe bool
+	// the consumer is stopped by source or the recv error of conn, only once.
+	stopOnce sync.Once
+	// merge the messages to send in a writev.
+	mwLatency time.Duration
+	mwMsgs    int
+	mwStat    SrsMergedWriteStat
+	// correct the timestamp by the jitter algorithm of vhost, monotonic when publisher republish.
+	jitter *SrsRtmpJitter
 }
 
 func NewSrsConsumer(s *SrsSource, c *SrsRtmpConn) Consumer {
@@ -49,7 +68,10 @@ func NewSrsConsumer(s *SrsSource, c *SrsRtmpConn) Consumer {
 		queue:    NewSrsMessageQueue(),
 		source:   s,
 		conn:     c,
-		StreamId: 1,
+		StreamId:  1,
+		mwLatency: time.Millisecond * time.Duration(config.GetMwLatency(c.req.vhost)),
+		mwMsgs:    int(config.GetMwMsgs(c.req.vhost)),
+		jitter:    NewSrsRtmpJitter(),
 	}
 	consumer.queueRecvThread = NewSrsQueueRecvThread(consumer, c.rtmp)
 	consumer.queueRecvThread.Start()
@@ -70,21 +92,29 @@ func (this *SrsConsumer) ConsumeCycle() error {
 		for !this.queueRecvThread.Empty() { //process signal message
 			msg := this.queueRecvThread.GetMsg()
 			if msg != nil {
+				// the control message is owned by consumer, the packet is decoded and processed.
 				err := this.processPlayControlMsg(msg)
+				msg.Release()
 				if err != nil {
 					return err
 				}
 			}
 		}
 		//todo process realtime stream
-		msg, err := this.queue.Wait()
+		msgs, err := this.queue.WaitBatch(this.mwMsgs, this.mwLatency)
 		if err != nil {
 			return err
 		}
 
-		if msg != nil {
-			err := this.conn.rtmp.SendMsg(msg, this.StreamId)
-			_ = err
+		if this.paused {
+			for i := 0; i < len(msgs); i++ {
+				this.cachePausedMsg(msgs[i])
+			}
+			continue
+		}
+
+		if err = this.sendMsgs(msgs); err != nil {
+			return err
 		}
 	}
 
@@ -92,9 +122,11 @@ func (this *SrsConsumer) ConsumeCycle() error {
 }
 
 func (this *SrsConsumer) StopConsume() error {
-	this.conn.Close()
-	this.queueRecvThread.Stop()
-	this.queue.Break()
+	this.stopOnce.Do(func() {
+		this.conn.Close()
+		this.queueRecvThread.Stop()
+		this.queue.Break()
+	})
 	return nil
 }
 
@@ -104,7 +136,7 @@ func (this *SrsConsumer) OnRecvError(err error) {
 }
 
 func (this *SrsConsumer) processPlayControlMsg(msg *rtmp.SrsRtmpMessage) error {
-	if !msg.GetHeader().IsAmf0Command() && !msg.GetHeader().IsAmf3Command() {
+	if !msg.GetHeader().IsAmf0Command() && !msg.GetHeader().IsAmf3Command() && !msg.GetHeader().IsAmf0SharedObject() {
 		//ignore
 		return nil
 	}
@@ -113,9 +145,19 @@ func (this *SrsConsumer) processPlayControlMsg(msg *rtmp.SrsRtmpMessage) error {
 	if err != nil {
 		return err
 	}
-	//todo add callpacket
-	//todo process pause message
 	switch pkt.(type) {
+	case *packet.SrsCallPacket:
+		{
+			return this.conn.rtmp.OnCall(pkt.(*packet.SrsCallPacket), int(msg.GetHeader().GetStreamId()))
+		}
+	case *packet.SrsSharedObjectPacket:
+		{
+			return this.conn.OnSharedObject(pkt.(*packet.SrsSharedObjectPacket))
+		}
+	case *packet.SrsFMLEStartPacket:
+		{
+			return this.conn.rtmp.OnFmleStart(pkt.(*packet.SrsFMLEStartPacket))
+		}
 	case *packet.SrsCloseStreamPacket:
 		{
 			//todo fix close stream action
@@ -123,14 +165,104 @@ func (this *SrsConsumer) processPlayControlMsg(msg *rtmp.SrsRtmpMessage) error {
 		}
 	case *packet.SrsPausePacket:
 		{
-			//todo pause stream
-			return nil
+			return this.onPlayClientPause(pkt.(*packet.SrsPausePacket).IsPause.Value)
+		}
+	case *packet.SrsSeekPacket:
+		{
+			return this.conn.rtmp.OnPlayClientSeek(this.StreamId, pkt.(*packet.SrsSeekPacket).Offset.Value)
 		}
 	}
 	return nil
 }
 
-//todo add rtmp jitter algorithm
+/**
+* when paused, stop sending and cache the gop,
+* when unpaused, resume from the gop cached in pause, or from the next keyframe of live.
+ */
+func (this *SrsConsumer) onPlayClientPause(isPause bool) error {
+	if err := this.conn.rtmp.OnPlayClientPause(this.StreamId, isPause); err != nil {
+		return err
+	}
+
+	if isPause == this.paused {
+		return nil
+	}
+	this.paused = isPause
+	log.Info("consumer paused=", isPause)
+
+	if isPause {
+		return nil
+	}
+
+	msgs := this.pausedMsgs
+	this.waitKeyframe = !this.pausedGop
+	this.pausedMsgs = nil
+	this.pausedGop = false
+	return this.sendMsgs(msgs)
+}
+
+func (this *SrsConsumer) cachePausedMsg(msg *rtmp.SrsRtmpMessage) {
+	header := msg.GetHeader()
+	payload := msg.GetPayload()
+	if header.IsVideo() && flvcodec.VideoIsKeyFrame(payload) && !flvcodec.VideoIsSequenceHeader(payload) {
+		for i := 0; i < len(this.pausedMsgs); i++ {
+			this.pausedMsgs[i].Release()
+		}
+		this.pausedMsgs = this.pausedMsgs[0:0]
+		this.pausedGop = true
+	}
+
+	// the sequence header and metadata are always required.
+	isSH := (header.IsVideo() && flvcodec.VideoIsSequenceHeader(payload)) || (header.IsAudio() && flvcodec.AudioIsSequenceHeader(payload))
+	if this.pausedGop || isSH || header.IsAmf0Data() || header.IsAmf3Data() {
+		this.pausedMsgs = append(this.pausedMsgs, msg)
+	} else {
+		msg.Release()
+	}
+}
+
+// send the messages to client, which are released whether sent or not.
+func (this *SrsConsumer) sendMsgs(msgs []*rtmp.SrsRtmpMessage) error {
+	sendMsgs := msgs[:0]
+	for i := 0; i < len(msgs); i++ {
+		msg := msgs[i]
+		if this.waitKeyframe && msg.GetHeader().IsVideo() && !flvcodec.VideoIsSequenceHeader(msg.GetPayload()) {
+			if !flvcodec.VideoIsKeyFrame(msg.GetPayload()) {
+				msg.Release()
+				continue
+			}
+			this.waitKeyframe = false
+		}
+		sendMsgs = append(sendMsgs, msg)
+	}
+
+	if len(sendMsgs) == 0 {
+		return nil
+	}
+
+	err := this.conn.rtmp.SendMessages(sendMsgs, this.StreamId)
+	// the payloads are written, release the messages.
+	for i := 0; i < len(sendMsgs); i++ {
+		sendMsgs[i].Release()
+	}
+	if err != nil {
+		return err
+	}
+
+	atomic.AddInt64(&this.mwStat.Writes, 1)
+	atomic.AddInt64(&this.mwStat.Msgs, int64(len(sendMsgs)))
+	return nil
+}
+
+func (this *SrsConsumer) GetMergedWriteStat() SrsMergedWriteStat {
+	return SrsMergedWriteStat{
+		Writes: atomic.LoadInt64(&this.mwStat.Writes),
+		Msgs:   atomic.LoadInt64(&this.mwStat.Msgs),
+	}
+}
+
 func (this *SrsConsumer) Enqueue(msg *rtmp.SrsRtmpMessage, atc bool, jitterAlgorithm *SrsRtmpJitterAlgorithm) {
+	msg = this.jitter.CorrectCopy(msg, consumerJitterAlgorithm(atc, jitterAlgorithm))
 	this.queue.Enqueue(msg)
+	msg.Release()
 }
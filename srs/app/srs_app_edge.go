@@ -0,0 +1,410 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2019 GOSRS(gosrs)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package app
+
+import (
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"go_srs/srs/app/config"
+	"go_srs/srs/protocol/packet"
+	"go_srs/srs/protocol/rtmp"
+	"sync"
+	"time"
+)
+
+// the timeout to connect to the origin.
+const SRS_EDGE_CONNECT_TIMEOUT = 3 * time.Second
+
+// the interval to retry the ingest, doubled after each failure until the max.
+const SRS_EDGE_INGEST_RETRY_MIN = 1 * time.Second
+const SRS_EDGE_INGEST_RETRY_MAX = 30 * time.Second
+
+// the next origin to connect for each edge vhost, for round robin.
+var edgeRoundRobinMtx sync.Mutex
+var edgeRoundRobin map[string]int
+
+func init() {
+	edgeRoundRobin = make(map[string]int)
+}
+
+/**
+* connect to the origin of edge vhost, the origin is selected by round robin,
+* and failover to the next origin when connect failed.
+ */
+func srsEdgeConnect(req *SrsRequest) (*rtmp.SrsRtmpClient, error) {
+	origins := config.GetVhostEdgeOrigin(req.vhost)
+	if len(origins) == 0 {
+		return nil, errors.New("no origin for edge vhost.")
+	}
+
+	edgeRoundRobinMtx.Lock()
+	start := edgeRoundRobin[req.vhost]
+	edgeRoundRobin[req.vhost] = (start + 1) % len(origins)
+	edgeRoundRobinMtx.Unlock()
+
+	var err error
+	for i := 0; i < len(origins); i++ {
+		origin := origins[(start+i)%len(origins)]
+		client, e := rtmp.DialSrsRtmpClient(srsForwardUrl(origin, req), SRS_EDGE_CONNECT_TIMEOUT)
+		if e == nil {
+			log.Info("edge connected to origin ", origin)
+			return client, nil
+		}
+
+		log.Warn("edge connect to origin ", origin, " failed, err=", e)
+		err = e
+	}
+	return nil, err
+}
+
+/**
+* the play edge pull the stream from origin to the local source,
+* start when the first player come, and stop when the last player left
+* for the idle timeout of vhost.
+ */
+type SrsPlayEdge struct {
+	source *SrsSource
+	req    *SrsRequest
+
+	mtx       sync.Mutex
+	ingesting bool
+	client    *rtmp.SrsRtmpClient
+	exit      chan bool
+	// the timer to stop ingest when idle, the seq is increased to cancel the fired timer.
+	idleTimer *time.Timer
+	idleSeq   int64
+}
+
+func NewSrsPlayEdge(s *SrsSource, req *SrsRequest) *SrsPlayEdge {
+	return &SrsPlayEdge{
+		source: s,
+		req:    req,
+	}
+}
+
+// when player start play, start ingest if not, and cancel the idle timer.
+func (this *SrsPlayEdge) OnClientPlay() error {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	this.cancelIdle()
+	if this.ingesting {
+		return nil
+	}
+
+	this.ingesting = true
+	this.exit = make(chan bool)
+	go this.ingestCycle(this.exit)
+	return nil
+}
+
+// when all players left, stop ingest after the idle timeout.
+func (this *SrsPlayEdge) OnAllClientStop() {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	if !this.ingesting || this.idleTimer != nil {
+		return
+	}
+
+	seq := this.idleSeq
+	timeout := time.Duration(config.GetEdgeIdleTimeout(this.req.vhost)) * time.Millisecond
+	this.idleTimer = time.AfterFunc(timeout, func() {
+		this.onIdle(seq)
+	})
+	log.Info("edge no player, stop ingest after ", timeout)
+}
+
+func (this *SrsPlayEdge) cancelIdle() {
+	this.idleSeq++
+	if this.idleTimer != nil {
+		this.idleTimer.Stop()
+		this.idleTimer = nil
+	}
+}
+
+func (this *SrsPlayEdge) onIdle(seq int64) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	if seq != this.idleSeq || !this.ingesting {
+		return
+	}
+
+	log.Info("edge stop ingest ", this.req.GetStreamUrl(), ", no player for idle timeout")
+	this.idleTimer = nil
+	this.stopIngest()
+}
+
+// stop ingest immediately, for example, the source is reaped.
+func (this *SrsPlayEdge) Stop() {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	this.cancelIdle()
+	if this.ingesting {
+		this.stopIngest()
+	}
+}
+
+func (this *SrsPlayEdge) stopIngest() {
+	this.ingesting = false
+	close(this.exit)
+	if this.client != nil {
+		this.client.Close()
+	}
+}
+
+/**
+* the cycle to ingest from origin, retry with backoff when error.
+* @return when the ingest is stopped.
+ */
+func (this *SrsPlayEdge) ingestCycle(exit chan bool) {
+	defer this.source.clearGopCache()
+
+	interval := SRS_EDGE_INGEST_RETRY_MIN
+	for {
+		connected, err := this.ingest(exit)
+		if connected {
+			interval = SRS_EDGE_INGEST_RETRY_MIN
+		}
+
+		select {
+		case <-exit:
+			return
+		default:
+		}
+
+		log.Error("edge ingest ", this.req.GetStreamUrl(), " failed, retry after ", interval, ", err=", err)
+		select {
+		case <-exit:
+			return
+		case <-time.After(interval):
+		}
+
+		if interval *= 2; interval > SRS_EDGE_INGEST_RETRY_MAX {
+			interval = SRS_EDGE_INGEST_RETRY_MAX
+		}
+	}
+}
+
+func (this *SrsPlayEdge) ingest(exit chan bool) (bool, error) {
+	client, err := srsEdgeConnect(this.req)
+	if err != nil {
+		return false, err
+	}
+	defer client.Close()
+
+	this.mtx.Lock()
+	select {
+	case <-exit:
+		this.mtx.Unlock()
+		return false, errors.New("edge ingest stopped")
+	default:
+	}
+	this.client = client
+	this.mtx.Unlock()
+
+	defer func() {
+		this.mtx.Lock()
+		if this.client == client {
+			this.client = nil
+		}
+		this.mtx.Unlock()
+	}()
+
+	streamId, err := client.CreateStream()
+	if err != nil {
+		return false, err
+	}
+
+	if err = client.Play(client.Stream(), streamId); err != nil {
+		return false, err
+	}
+
+	return true, client.PlayCycle(func(msg *rtmp.SrsRtmpMessage) error {
+		defer msg.Release()
+		return this.processIngestMessage(client, msg)
+	})
+}
+
+// deliver the message from origin to source, like the publish message.
+func (this *SrsPlayEdge) processIngestMessage(client *rtmp.SrsRtmpClient, msg *rtmp.SrsRtmpMessage) error {
+	header := msg.GetHeader()
+	if header.IsAudio() {
+		return this.source.OnAudio(msg)
+	}
+
+	if header.IsVideo() {
+		return this.source.OnVideo(msg)
+	}
+
+	if header.IsAggregate() {
+		msgs, err := msg.DemuxAggregate()
+		if err != nil {
+			return err
+		}
+
+		for i := 0; i < len(msgs); i++ {
+			err = this.processIngestMessage(client, msgs[i])
+			msgs[i].Release()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if header.IsAmf0Data() || header.IsAmf3Data() {
+		pkt, err := client.DecodeMessage(msg)
+		if err != nil {
+			return err
+		}
+
+		switch pkt := pkt.(type) {
+		case *packet.SrsOnMetaDataPacket:
+			return this.source.OnMetaData(msg, pkt)
+		case *packet.SrsOnDataPacket:
+			return this.source.OnData(msg, pkt)
+		}
+	}
+	return nil
+}
+
+/**
+* the publish edge proxy the stream published to edge to the origin,
+* the publish fail when the connection to origin closed.
+ */
+type SrsPublishEdge struct {
+	req *SrsRequest
+
+	mtx    sync.Mutex
+	client *rtmp.SrsRtmpClient
+	queue  *SrsMessageQueue
+}
+
+func NewSrsPublishEdge(req *SrsRequest) *SrsPublishEdge {
+	return &SrsPublishEdge{
+		req: req,
+	}
+}
+
+// when client publish to edge, connect to origin and publish the stream.
+func (this *SrsPublishEdge) OnClientPublish() error {
+	client, err := srsEdgeConnect(this.req)
+	if err != nil {
+		return err
+	}
+
+	if err = client.SetChunkSize(config.GetInstance().GetChunkSize(this.req.vhost)); err != nil {
+		client.Close()
+		return err
+	}
+
+	streamId, err := client.FmlePublish(client.Stream())
+	if err != nil {
+		client.Close()
+		return err
+	}
+
+	queue := NewSrsMessageQueue()
+	this.mtx.Lock()
+	if this.client != nil {
+		this.mtx.Unlock()
+		client.Close()
+		return errors.New("edge is publishing to origin.")
+	}
+	this.client = client
+	this.queue = queue
+	this.mtx.Unlock()
+
+	go this.sendCycle(client, queue, streamId)
+	go this.recvCycle(client)
+	return nil
+}
+
+func (this *SrsPublishEdge) OnProxyPublish(msg *rtmp.SrsRtmpMessage) error {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	if this.queue == nil {
+		return errors.New("edge proxy to origin closed")
+	}
+	this.queue.Enqueue(msg)
+	return nil
+}
+
+func (this *SrsPublishEdge) OnProxyUnpublish() {
+	this.mtx.Lock()
+	client := this.client
+	this.mtx.Unlock()
+
+	if client != nil {
+		this.closeUpstream(client)
+	}
+}
+
+func (this *SrsPublishEdge) sendCycle(client *rtmp.SrsRtmpClient, queue *SrsMessageQueue, streamId int) {
+	for {
+		msg, err := queue.Wait()
+		if err != nil {
+			return
+		}
+
+		if msg == nil {
+			continue
+		}
+
+		err = client.SendMsg(msg, streamId)
+		msg.Release()
+		if err != nil {
+			log.Error("edge proxy publish to origin failed, err=", err)
+			this.closeUpstream(client)
+			return
+		}
+	}
+}
+
+// read the messages from origin, for the protocol control messages, for example, the ack.
+func (this *SrsPublishEdge) recvCycle(client *rtmp.SrsRtmpClient) {
+	for {
+		if _, err := client.RecvMessage(); err != nil {
+			this.closeUpstream(client)
+			return
+		}
+	}
+}
+
+func (this *SrsPublishEdge) closeUpstream(client *rtmp.SrsRtmpClient) {
+	client.Close()
+
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	if this.client != client {
+		return
+	}
+
+	this.queue.Break()
+	this.queue = nil
+	this.client = nil
+}
@@ -25,7 +25,6 @@ package app
 
 import (
 	"errors"
-	"fmt"
 	"go_srs/srs/codec/flv"
 	"go_srs/srs/protocol/rtmp"
 )
@@ -86,11 +85,16 @@ func (this *SrsGopCache) cache(msg *rtmp.SrsRtmpMessage) error {
 		this.cachedVideoCount = 1
 	}
 
+	msg.Retain()
 	this.gopCache = append(this.gopCache, msg)
 	return nil
 }
 
 func (this *SrsGopCache) clear() {
+	for i := 0; i < len(this.gopCache); i++ {
+		this.gopCache[i].Release()
+		this.gopCache[i] = nil
+	}
 	this.gopCache = this.gopCache[0:0]
 	this.cachedVideoCount = 0
 	this.audioAfterLastVideoCount = 0
@@ -111,11 +115,3 @@ func (this *SrsGopCache) startTime() int64 {
 func (this *SrsGopCache) pureAudio() bool {
 	return this.cachedVideoCount == 0
 }
-
-func (this *SrsGopCache) dump(consumer Consumer, atc bool, jitterAlgorithm *SrsRtmpJitterAlgorithm) error {
-	for i := 0; i < len(this.gopCache); i++ {
-		consumer.Enqueue(this.gopCache[i], atc, jitterAlgorithm)
-	}
-	fmt.Println("****************dump count=", len(this.gopCache), "****************")
-	return nil
-}
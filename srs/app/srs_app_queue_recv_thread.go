@@ -25,9 +25,12 @@ package app
 
 import (
 	"go_srs/srs/protocol/rtmp"
+	"sync"
 )
 
 type SrsQueueRecvThread struct {
+	// the queue is appended by recv thread and taken by consumer.
+	mtx        sync.Mutex
 	queue      []*rtmp.SrsRtmpMessage
 	consumer   *SrsConsumer
 	rtmp       *rtmp.SrsRtmpServer
@@ -36,7 +39,7 @@ type SrsQueueRecvThread struct {
 
 func NewSrsQueueRecvThread(c *SrsConsumer, s *rtmp.SrsRtmpServer) *SrsQueueRecvThread {
 	st := &SrsQueueRecvThread{
-		queue:    make([]*rtmp.SrsRtmpMessage, 1000),
+		queue:    make([]*rtmp.SrsRtmpMessage, 0, 1000),
 		consumer: c,
 		rtmp:     s,
 	}
@@ -57,28 +60,38 @@ func (this *SrsQueueRecvThread) CanHandle() bool {
 	return true
 }
 
+// the queue owns the msg, which is released by consumer when processed.
 func (this *SrsQueueRecvThread) Handle(msg *rtmp.SrsRtmpMessage) error {
 
 	//todo fix cid change
 	//todo nbmsg++
+	this.mtx.Lock()
 	this.queue = append(this.queue, msg)
+	this.mtx.Unlock()
+	// wakeup the consumer to process the control message, when no stream message.
+	this.consumer.queue.Wakeup()
 	return nil
 }
 
 func (this *SrsQueueRecvThread) Size() int {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
 	return len(this.queue)
 }
 
 func (this *SrsQueueRecvThread) Empty() bool {
-	return len(this.queue) == 0
+	return this.Size() == 0
 }
 
 func (this *SrsQueueRecvThread) GetMsg() *rtmp.SrsRtmpMessage {
-	if this.Empty() {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	if len(this.queue) == 0 {
 		return nil
 	}
 
 	m := this.queue[0]
+	this.queue[0] = nil
 	this.queue = this.queue[1:]
 	return m
 }
@@ -0,0 +1,167 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2019 GOSRS(gosrs)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package app
+
+import (
+	"crypto/tls"
+	log "github.com/sirupsen/logrus"
+	"go_srs/srs/app/config"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// the timeout for client to complete the tls handshake.
+	SRS_RTMPS_HANDSHAKE_TIMEOUT = 10 * time.Second
+	// the max delay to retry when accept got temporary error, for example, too many open files.
+	SRS_RTMPS_ACCEPT_MAX_DELAY = time.Second
+)
+
+/**
+* the certificate of rtmps, which is loaded from the cert and key file,
+* the reload only affects the new connections, the established ones keep their tls session.
+ */
+type SrsRtmpsCertificate struct {
+	mtx  sync.RWMutex
+	cert *tls.Certificate
+}
+
+func NewSrsRtmpsCertificate() *SrsRtmpsCertificate {
+	return &SrsRtmpsCertificate{}
+}
+
+/**
+* load the certificate from file, the previous one is kept when load failed,
+* so a broken cert or key never stops the server from accepting connections.
+ */
+func (this *SrsRtmpsCertificate) Load(certFile string, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.cert = &cert
+	return nil
+}
+
+func (this *SrsRtmpsCertificate) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	this.mtx.RLock()
+	defer this.mtx.RUnlock()
+	return this.cert, nil
+}
+
+/**
+* listen the rtmps port, the accepted conns are wrapped by tls,
+* then served as the rtmp conns.
+ */
+func (this *SrsServer) listenRtmps() error {
+	conf := config.GetInstance().Rtmps
+	this.rtmpsCert = NewSrsRtmpsCertificate()
+	if err := this.rtmpsCert.Load(conf.Cert, conf.Key); err != nil {
+		return err
+	}
+
+	ln, err := net.Listen("tcp", ":"+strconv.Itoa(int(conf.Listen)))
+	if err != nil {
+		return err
+	}
+
+	tlsConfig := &tls.Config{
+		GetCertificate: this.rtmpsCert.GetCertificate,
+	}
+	tlsLn := tls.NewListener(ln, tlsConfig)
+	log.Info("listen rtmps at ", conf.Listen)
+
+	go func() {
+		var delay time.Duration
+		for {
+			conn, err := tlsLn.Accept()
+			if err != nil {
+				// retry the temporary error with backoff, quit for others, for example, listener closed.
+				if ne, ok := err.(net.Error); ok && ne.Temporary() {
+					if delay == 0 {
+						delay = 5 * time.Millisecond
+					} else if delay *= 2; delay > SRS_RTMPS_ACCEPT_MAX_DELAY {
+						delay = SRS_RTMPS_ACCEPT_MAX_DELAY
+					}
+					log.Warn("rtmps accept failed, retry in ", delay, ", err=", err)
+					time.Sleep(delay)
+					continue
+				}
+				log.Error("rtmps accept failed, err=", err)
+				return
+			}
+			delay = 0
+			go this.handleRtmpsConnection(conn.(*tls.Conn))
+		}
+	}()
+
+	// reload the certificate when got SIGHUP, for example, the cert is renewed.
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGHUP)
+		for range signals {
+			if err := this.ReloadRtmpsCertificate(); err != nil {
+				log.Error("reload rtmps certificate failed, err=", err)
+			}
+		}
+	}()
+	return nil
+}
+
+/**
+* complete the tls handshake in timeout, so the client never sends ClientHello can't hold the conn,
+* then serve it as the rtmp conn.
+ */
+func (this *SrsServer) handleRtmpsConnection(conn *tls.Conn) {
+	conn.SetDeadline(time.Now().Add(SRS_RTMPS_HANDSHAKE_TIMEOUT))
+	if err := conn.Handshake(); err != nil {
+		log.Warn("rtmps handshake failed, client=", conn.RemoteAddr(), ", err=", err)
+		conn.Close()
+		return
+	}
+	conn.SetDeadline(time.Time{})
+
+	this.HandleConnection(conn)
+}
+
+// reload the rtmps certificate from the cert and key in config.
+func (this *SrsServer) ReloadRtmpsCertificate() error {
+	if this.rtmpsCert == nil {
+		return nil
+	}
+
+	conf := config.GetInstance().Rtmps
+	if err := this.rtmpsCert.Load(conf.Cert, conf.Key); err != nil {
+		return err
+	}
+	log.Info("reload rtmps certificate ok, cert=", conf.Cert)
+	return nil
+}
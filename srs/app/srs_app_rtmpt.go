@@ -0,0 +1,487 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2019 GOSRS(gosrs)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package app
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SRS_RTMPT_CONTENT_TYPE = "application/x-fcs"
+	// the session is closed when client not poll in this timeout.
+	SRS_RTMPT_SESSION_TIMEOUT = 30 * time.Second
+	// the time to wait for the response data of rtmp conn in send request.
+	SRS_RTMPT_SEND_WAIT = 50 * time.Millisecond
+	// the polling interval byte, from 0x01 to 0x21.
+	SRS_RTMPT_MIN_INTERVAL = 0x01
+	SRS_RTMPT_MAX_INTERVAL = 0x21
+	// the max bytes of data to client, the rtmp conn is blocked when exceed, until client polls.
+	SRS_RTMPT_MAX_OUT_BYTES = 4 * 1024 * 1024
+	// the max bytes of data from client, the body of send request and the data not read by rtmp conn.
+	SRS_RTMPT_MAX_IN_BYTES = 4 * 1024 * 1024
+)
+
+type srsRtmptAddr string
+
+func (this srsRtmptAddr) Network() string {
+	return "rtmpt"
+}
+
+func (this srsRtmptAddr) String() string {
+	return string(this)
+}
+
+/**
+* the virtual net.Conn of rtmpt session, the rtmp conn reads the data posted by send request,
+* and the data written by rtmp conn is responsed to client in the send or idle request.
+ */
+type SrsRtmptConn struct {
+	mtx  sync.Mutex
+	cond *sync.Cond
+	// the data from client, to read by rtmp conn.
+	in bytes.Buffer
+	// the data from rtmp conn, to response to client.
+	out      bytes.Buffer
+	outReady chan bool
+	closed   bool
+	remote   net.Addr
+	local    net.Addr
+	// the deadline of blocking read and write, zero for no deadline.
+	readDeadline  time.Time
+	writeDeadline time.Time
+	// the polling interval byte, increase when no data to client.
+	interval   byte
+	lastActive time.Time
+	/**
+	 * the seq of last request and its response, the request with the same seq is a retransmit,
+	 * which is responsed the same, the request skipped seq is rejected for the data is lost.
+	 */
+	seq      int64
+	response []byte
+}
+
+func NewSrsRtmptConn(r *http.Request) *SrsRtmptConn {
+	c := &SrsRtmptConn{
+		outReady:   make(chan bool, 1),
+		remote:     srsRtmptAddr(r.RemoteAddr),
+		local:      srsRtmptAddr(r.Host),
+		interval:   SRS_RTMPT_MIN_INTERVAL,
+		lastActive: time.Now(),
+		seq:        -1,
+	}
+	c.cond = sync.NewCond(&c.mtx)
+	return c
+}
+
+func (this *SrsRtmptConn) Read(b []byte) (int, error) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	for this.in.Len() == 0 && !this.closed {
+		if err := this.wait(this.readDeadline); err != nil {
+			return 0, err
+		}
+	}
+
+	if this.in.Len() == 0 {
+		return 0, io.EOF
+	}
+
+	// wakeup the blocking push.
+	this.cond.Broadcast()
+	return this.in.Read(b)
+}
+
+// block when the data to client exceed the max, until client polls, or the session closed.
+func (this *SrsRtmptConn) Write(b []byte) (int, error) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	for this.out.Len() >= SRS_RTMPT_MAX_OUT_BYTES && !this.closed {
+		if err := this.wait(this.writeDeadline); err != nil {
+			return 0, err
+		}
+	}
+
+	if this.closed {
+		return 0, errors.New("rtmpt session closed.")
+	}
+
+	this.out.Write(b)
+	select {
+	case this.outReady <- true:
+	default:
+	}
+	return len(b), nil
+}
+
+func (this *SrsRtmptConn) Close() error {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.closed = true
+	this.cond.Broadcast()
+	return nil
+}
+
+func (this *SrsRtmptConn) LocalAddr() net.Addr {
+	return this.local
+}
+
+func (this *SrsRtmptConn) RemoteAddr() net.Addr {
+	return this.remote
+}
+
+func (this *SrsRtmptConn) SetDeadline(t time.Time) error {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.readDeadline = t
+	this.writeDeadline = t
+	// wakeup the blocking read and write to check the new deadline.
+	this.cond.Broadcast()
+	return nil
+}
+
+func (this *SrsRtmptConn) SetReadDeadline(t time.Time) error {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.readDeadline = t
+	this.cond.Broadcast()
+	return nil
+}
+
+func (this *SrsRtmptConn) SetWriteDeadline(t time.Time) error {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.writeDeadline = t
+	this.cond.Broadcast()
+	return nil
+}
+
+/**
+* wait for the cond in the locked mtx, return the timeout error when deadline exceed,
+* the caller must check its condition again when return nil.
+ */
+func (this *SrsRtmptConn) wait(deadline time.Time) error {
+	if deadline.IsZero() {
+		this.cond.Wait()
+		return nil
+	}
+
+	d := time.Until(deadline)
+	if d <= 0 {
+		return os.ErrDeadlineExceeded
+	}
+
+	timer := time.AfterFunc(d, func() {
+		this.mtx.Lock()
+		defer this.mtx.Unlock()
+		this.cond.Broadcast()
+	})
+	this.cond.Wait()
+	timer.Stop()
+	return nil
+}
+
+/**
+* push the data of client to rtmp conn, block when the data not read exceed the max,
+* until the rtmp conn reads, or the session closed.
+ */
+func (this *SrsRtmptConn) push(data []byte) error {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	for this.in.Len() > 0 && this.in.Len()+len(data) > SRS_RTMPT_MAX_IN_BYTES && !this.closed {
+		this.cond.Wait()
+	}
+
+	if this.closed {
+		return errors.New("rtmpt session closed.")
+	}
+
+	this.lastActive = time.Now()
+	this.in.Write(data)
+	this.cond.Broadcast()
+	return nil
+}
+
+/**
+* pull the data of rtmp conn to client, wait for the data when no data yet,
+* the response is the polling interval byte followed by data.
+ */
+func (this *SrsRtmptConn) pull(wait time.Duration) []byte {
+	this.mtx.Lock()
+	empty := this.out.Len() == 0
+	this.mtx.Unlock()
+
+	if empty && wait > 0 {
+		select {
+		case <-this.outReady:
+		case <-time.After(wait):
+		}
+	}
+
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.lastActive = time.Now()
+
+	// reset the interval when got data, otherwise increase it as 1, 3, 5, 9, 17, 33.
+	if this.out.Len() > 0 {
+		this.interval = SRS_RTMPT_MIN_INTERVAL
+	} else if this.interval == SRS_RTMPT_MIN_INTERVAL {
+		this.interval = 3
+	} else if this.interval < SRS_RTMPT_MAX_INTERVAL {
+		this.interval = (this.interval-1)*2 + 1
+	}
+
+	res := make([]byte, 1+this.out.Len())
+	res[0] = this.interval
+	copy(res[1:], this.out.Bytes())
+	this.out.Reset()
+	// wakeup the blocking write.
+	this.cond.Broadcast()
+	return res
+}
+
+/**
+* check the seq of request, the first request starts the seq, and the next must increase by 1.
+* @return the response of last request when retransmit, nil for the new request.
+ */
+func (this *SrsRtmptConn) checkSeq(seq int64) ([]byte, error) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	if this.seq >= 0 && seq == this.seq {
+		// the last request is in progress, response no data.
+		if this.response == nil {
+			return []byte{this.interval}, nil
+		}
+		return this.response, nil
+	}
+	if this.seq >= 0 && seq != this.seq+1 {
+		return nil, fmt.Errorf("rtmpt seq %d not follow %d", seq, this.seq)
+	}
+
+	this.seq = seq
+	this.response = nil
+	return nil, nil
+}
+
+// save the response of request, for the retransmit of client.
+func (this *SrsRtmptConn) onResponse(seq int64, res []byte) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	if this.seq == seq {
+		this.response = res
+	}
+}
+
+func (this *SrsRtmptConn) expired(now time.Time) bool {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	return now.Sub(this.lastActive) > SRS_RTMPT_SESSION_TIMEOUT
+}
+
+/**
+* the rtmpt server, serve the http requests of rtmpt:
+*       POST /open/1, response the session id.
+*       POST /send/<id>/<seq>, post the rtmp data, response the interval and rtmp data.
+*       POST /idle/<id>/<seq>, poll for the interval and rtmp data.
+*       POST /close/<id>/<seq>, close the session.
+* each session is served by SrsRtmpConn over the virtual conn.
+ */
+type SrsRtmptServer struct {
+	server   *SrsServer
+	mtx      sync.Mutex
+	sessions map[string]*SrsRtmptConn
+}
+
+func NewSrsRtmptServer(s *SrsServer) *SrsRtmptServer {
+	rtmpt := &SrsRtmptServer{
+		server:   s,
+		sessions: make(map[string]*SrsRtmptConn),
+	}
+	go rtmpt.expireCycle()
+	return rtmpt
+}
+
+func (this *SrsRtmptServer) Mount(mux *http.ServeMux) {
+	for _, p := range []string{"/fcs/", "/open/", "/send/", "/idle/", "/close/"} {
+		mux.Handle(p, this)
+	}
+}
+
+func (this *SrsRtmptServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "rtmpt only support POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	p := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch p[0] {
+	case "open":
+		this.open(w, r)
+		return
+	case "send", "idle", "close":
+		if len(p) < 3 {
+			http.NotFound(w, r)
+			return
+		}
+	default:
+		// for example, the /fcs/ident2, which is not supported.
+		http.NotFound(w, r)
+		return
+	}
+
+	this.mtx.Lock()
+	c, ok := this.sessions[p[1]]
+	this.mtx.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	seq, err := strconv.ParseInt(p[2], 10, 64)
+	if err != nil || seq < 0 {
+		http.Error(w, "rtmpt invalid seq "+p[2], http.StatusBadRequest)
+		return
+	}
+
+	// read the data before the seq is checked, the request is rejected when body too large.
+	var data []byte
+	if p[0] == "send" {
+		if data, err = ioutil.ReadAll(http.MaxBytesReader(w, r.Body, SRS_RTMPT_MAX_IN_BYTES)); err != nil {
+			if _, ok := err.(*http.MaxBytesError); ok {
+				http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// the close is always accepted, for the client may not know the seq when error.
+	if p[0] != "close" {
+		res, err := c.checkSeq(seq)
+		if err != nil {
+			// the data is lost, the rtmp stream is broken.
+			log.Warn("rtmpt close session ", p[1], ", err=", err)
+			this.remove(p[1])
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if res != nil {
+			// the retransmit request, response the same and drop the data.
+			w.Header().Set("Content-Type", SRS_RTMPT_CONTENT_TYPE)
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Write(res)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", SRS_RTMPT_CONTENT_TYPE)
+	w.Header().Set("Cache-Control", "no-cache")
+	switch p[0] {
+	case "send":
+		if err := c.push(data); err != nil {
+			this.remove(p[1])
+			http.NotFound(w, r)
+			return
+		}
+		res := c.pull(SRS_RTMPT_SEND_WAIT)
+		c.onResponse(seq, res)
+		w.Write(res)
+	case "idle":
+		res := c.pull(0)
+		c.onResponse(seq, res)
+		w.Write(res)
+	case "close":
+		this.remove(p[1])
+		w.Write([]byte{0x00})
+	}
+}
+
+func (this *SrsRtmptServer) open(w http.ResponseWriter, r *http.Request) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	id := hex.EncodeToString(b)
+
+	c := NewSrsRtmptConn(r)
+	this.mtx.Lock()
+	this.sessions[id] = c
+	this.mtx.Unlock()
+	log.Info("rtmpt open session ", id, ", client=", r.RemoteAddr)
+
+	go this.server.HandleConnection(c)
+
+	w.Header().Set("Content-Type", SRS_RTMPT_CONTENT_TYPE)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write([]byte(id + "\n"))
+}
+
+func (this *SrsRtmptServer) remove(id string) {
+	this.mtx.Lock()
+	c, ok := this.sessions[id]
+	delete(this.sessions, id)
+	this.mtx.Unlock()
+
+	if ok {
+		c.Close()
+		log.Info("rtmpt close session ", id)
+	}
+}
+
+// close the sessions which client not poll in timeout.
+func (this *SrsRtmptServer) expireCycle() {
+	for {
+		time.Sleep(SRS_RTMPT_SESSION_TIMEOUT / 3)
+
+		now := time.Now()
+		expired := make([]string, 0)
+		this.mtx.Lock()
+		for id, c := range this.sessions {
+			if c.expired(now) {
+				expired = append(expired, id)
+			}
+		}
+		this.mtx.Unlock()
+
+		for _, id := range expired {
+			this.remove(id)
+		}
+	}
+}
@@ -0,0 +1,416 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2019 GOSRS(gosrs)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"go_srs/srs/app/config"
+	"go_srs/srs/global"
+	"go_srs/srs/protocol/amf0"
+	"go_srs/srs/protocol/packet"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var sharedObjectPoolMtx sync.Mutex
+var sharedObjectPool map[string]*SrsSharedObject = make(map[string]*SrsSharedObject)
+
+/**
+* the remote shared object of app, the properties are synchronized to all clients
+* which use the shared object, and the version is increased when properties changed.
+* the non-persistent shared object is disposed when the last client released,
+* while the persistent one is kept, and stored as json when persistence enabled for vhost.
+ */
+type SrsSharedObject struct {
+	key        string
+	name       string
+	persistent bool
+	// the json file to store the properties, empty if not stored.
+	path string
+
+	mtx        sync.Mutex
+	version    uint32
+	properties []amf0.SrsValuePair
+	clients    []*SrsRtmpConn
+	// the ticket of last update, increased under mtx.
+	ticket uint64
+
+	/**
+	 * the updates are flushed out of mtx, so the slow client never blocks the changes,
+	 * and flushed in the order of tickets, so clients got the changes in order.
+	 */
+	flushMtx  sync.Mutex
+	flushCond *sync.Cond
+	flushed   uint64
+}
+
+/**
+* the update of shared object, the messages to send and the json data to store,
+* which are built under lock, then flushed out of lock.
+ */
+type srsSharedObjectUpdate struct {
+	ticket uint64
+	msgs   []srsSharedObjectMessage
+	// the json data to store, nil if not changed or not stored.
+	data []byte
+}
+
+type srsSharedObjectMessage struct {
+	client *SrsRtmpConn
+	pkt    *packet.SrsSharedObjectPacket
+}
+
+func (this *srsSharedObjectUpdate) sendTo(c *SrsRtmpConn, pkt *packet.SrsSharedObjectPacket) {
+	this.msgs = append(this.msgs, srsSharedObjectMessage{client: c, pkt: pkt})
+}
+
+// the data of persistent shared object in json file.
+type srsSharedObjectData struct {
+	Version    uint32                 `json:"version"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+func sharedObjectKey(vhost string, app string, name string, persistent bool) string {
+	if persistent {
+		return vhost + "/" + app + "/" + name + "?persistent"
+	}
+	return vhost + "/" + app + "/" + name
+}
+
+/**
+* the client use the shared object of app, create it when not exists,
+* the client got the use success, clear and all properties of shared object.
+ */
+func UseSharedObject(c *SrsRtmpConn, vhost string, app string, name string, persistent bool) *SrsSharedObject {
+	sharedObjectPoolMtx.Lock()
+
+	key := sharedObjectKey(vhost, app, name, persistent)
+	so, ok := sharedObjectPool[key]
+	if !ok {
+		so = NewSrsSharedObject(key, name, persistent)
+		if persistent {
+			so.load(vhost, app)
+		}
+		sharedObjectPool[key] = so
+	}
+	u := so.use(c)
+	sharedObjectPoolMtx.Unlock()
+
+	so.flush(u)
+	return so
+}
+
+// the client release the shared object, which is disposed when no client and not persistent.
+func ReleaseSharedObject(c *SrsRtmpConn, so *SrsSharedObject) {
+	sharedObjectPoolMtx.Lock()
+	defer sharedObjectPoolMtx.Unlock()
+
+	if so.release(c) == 0 && !so.persistent {
+		delete(sharedObjectPool, so.key)
+	}
+}
+
+func NewSrsSharedObject(key string, name string, persistent bool) *SrsSharedObject {
+	so := &SrsSharedObject{
+		key:        key,
+		name:       name,
+		persistent: persistent,
+		properties: make([]amf0.SrsValuePair, 0),
+		clients:    make([]*SrsRtmpConn, 0),
+	}
+	so.flushCond = sync.NewCond(&so.flushMtx)
+	return so
+}
+
+// create the update under mtx, which must be flushed.
+func (this *SrsSharedObject) newUpdate() *srsSharedObjectUpdate {
+	this.ticket++
+	return &srsSharedObjectUpdate{ticket: this.ticket}
+}
+
+/**
+* send the messages and store the data of update out of mtx,
+* wait for the previous updates to keep the order of changes.
+* @remark the error of client is ignored, which is processed by the cycle of client.
+ */
+func (this *SrsSharedObject) flush(u *srsSharedObjectUpdate) {
+	this.flushMtx.Lock()
+	defer this.flushMtx.Unlock()
+	for this.flushed+1 != u.ticket {
+		this.flushCond.Wait()
+	}
+
+	for i := 0; i < len(u.msgs); i++ {
+		if err := u.msgs[i].client.rtmp.SendSharedObject(u.msgs[i].pkt); err != nil {
+			log.Warn("send shared object ", this.name, " failed, err=", err)
+		}
+	}
+
+	if u.data != nil {
+		if err := this.store(u.data); err != nil {
+			log.Warn("save shared object ", this.path, " failed, err=", err)
+		}
+	}
+
+	this.flushed = u.ticket
+	this.flushCond.Broadcast()
+}
+
+func (this *SrsSharedObject) use(c *SrsRtmpConn) *srsSharedObjectUpdate {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	u := this.newUpdate()
+	for i := 0; i < len(this.clients); i++ {
+		if this.clients[i] == c {
+			return u
+		}
+	}
+	this.clients = append(this.clients, c)
+
+	// sync all properties to the new client.
+	pkt := this.newPacket()
+	pkt.AddEvent(packet.NewSrsSharedObjectEvent(global.RTMP_SO_UseSuccess))
+	pkt.AddEvent(packet.NewSrsSharedObjectEvent(global.RTMP_SO_Clear))
+	for i := 0; i < len(this.properties); i++ {
+		event := packet.NewSrsSharedObjectEvent(global.RTMP_SO_Change)
+		event.Name, event.Value = this.properties[i].Name.Value, this.properties[i].Value
+		pkt.AddEvent(event)
+	}
+	u.sendTo(c, pkt)
+	return u
+}
+
+// remove the client, return the number of left clients.
+func (this *SrsSharedObject) release(c *SrsRtmpConn) int {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+
+	for i := 0; i < len(this.clients); i++ {
+		if this.clients[i] == c {
+			this.clients = append(this.clients[:i], this.clients[i+1:]...)
+			break
+		}
+	}
+	return len(this.clients)
+}
+
+/**
+* process the event of client, the request change and remove of property,
+* and the send message which is broadcast to all clients, including the sender.
+ */
+func (this *SrsSharedObject) OnEvent(c *SrsRtmpConn, event *packet.SrsSharedObjectEvent) {
+	this.mtx.Lock()
+	u := this.newUpdate()
+	switch event.Type {
+	case global.RTMP_SO_RequestChange:
+		this.setProperty(u, c, event.Name, event.Value)
+	case global.RTMP_SO_RequestRemove:
+		this.removeProperty(u, event.Name)
+	case global.RTMP_SO_SendMessage:
+		pkt := this.newPacket()
+		res := packet.NewSrsSharedObjectEvent(global.RTMP_SO_SendMessage)
+		res.Args = event.Args
+		pkt.AddEvent(res)
+		this.broadcast(u, pkt, nil)
+	default:
+		log.Warn("ignore shared object event ", event.Type, " of ", this.name)
+	}
+	this.mtx.Unlock()
+
+	this.flush(u)
+}
+
+// the requester got success, while others got the change.
+func (this *SrsSharedObject) setProperty(u *srsSharedObjectUpdate, c *SrsRtmpConn, name string, value amf0.ISrsAmf0Any) {
+	found := false
+	for i := 0; i < len(this.properties); i++ {
+		if this.properties[i].Name.Value == name {
+			this.properties[i].Value = value
+			found = true
+			break
+		}
+	}
+	if !found {
+		this.properties = append(this.properties, amf0.SrsValuePair{Name: amf0.SrsAmf0Utf8{Value: name}, Value: value})
+	}
+	this.version++
+
+	success := this.newPacket()
+	res := packet.NewSrsSharedObjectEvent(global.RTMP_SO_Success)
+	res.Name = name
+	success.AddEvent(res)
+	u.sendTo(c, success)
+
+	change := this.newPacket()
+	res = packet.NewSrsSharedObjectEvent(global.RTMP_SO_Change)
+	res.Name, res.Value = name, value
+	change.AddEvent(res)
+	this.broadcast(u, change, c)
+
+	u.data = this.snapshot()
+}
+
+// all clients got the remove, ignore when property not exists.
+func (this *SrsSharedObject) removeProperty(u *srsSharedObjectUpdate, name string) {
+	found := false
+	for i := 0; i < len(this.properties); i++ {
+		if this.properties[i].Name.Value == name {
+			this.properties = append(this.properties[:i], this.properties[i+1:]...)
+			found = true
+			break
+		}
+	}
+	if !found {
+		return
+	}
+	this.version++
+
+	pkt := this.newPacket()
+	res := packet.NewSrsSharedObjectEvent(global.RTMP_SO_Remove)
+	res.Name = name
+	pkt.AddEvent(res)
+	this.broadcast(u, pkt, nil)
+
+	u.data = this.snapshot()
+}
+
+func (this *SrsSharedObject) newPacket() *packet.SrsSharedObjectPacket {
+	return packet.NewSrsSharedObjectPacket(this.name, this.version, this.persistent)
+}
+
+// send the packet to all clients except the excluded one, nil to send to all.
+func (this *SrsSharedObject) broadcast(u *srsSharedObjectUpdate, pkt *packet.SrsSharedObjectPacket, exclude *SrsRtmpConn) {
+	for i := 0; i < len(this.clients); i++ {
+		if this.clients[i] != exclude {
+			u.sendTo(this.clients[i], pkt)
+		}
+	}
+}
+
+/**
+* the json file of persistent shared object, empty if persistence disabled,
+* or the name is not safe for path.
+ */
+func sharedObjectPath(vhost string, app string, name string) string {
+	path := config.GetSharedObjectPersistPath(vhost)
+	if path == "" {
+		return ""
+	}
+
+	if strings.Contains(name, "..") || strings.Contains(app, "..") {
+		log.Warn("ignore persistence of shared object ", app, "/", name)
+		return ""
+	}
+
+	path = strings.Replace(path, "[vhost]", vhost, -1)
+	path = strings.Replace(path, "[app]", app, -1)
+	path = strings.Replace(path, "[name]", name, -1)
+	return path
+}
+
+// load the properties from json file, ignore when file not exists.
+func (this *SrsSharedObject) load(vhost string, app string) {
+	if this.path = sharedObjectPath(vhost, app, this.name); this.path == "" {
+		return
+	}
+
+	b, err := ioutil.ReadFile(this.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warn("read shared object ", this.path, " failed, err=", err)
+		}
+		return
+	}
+
+	var data srsSharedObjectData
+	if err = json.Unmarshal(b, &data); err != nil {
+		log.Warn("parse shared object ", this.path, " failed, err=", err)
+		return
+	}
+
+	names := make([]string, 0, len(data.Properties))
+	for name := range data.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value, err := amf0.MarshalAny(data.Properties[name])
+		if err != nil {
+			log.Warn("ignore property ", name, " of shared object ", this.path, ", err=", err)
+			continue
+		}
+		this.properties = append(this.properties, amf0.SrsValuePair{Name: amf0.SrsAmf0Utf8{Value: name}, Value: value})
+	}
+	this.version = data.Version
+}
+
+// the json data of properties to store, nil if not stored.
+func (this *SrsSharedObject) snapshot() []byte {
+	if this.path == "" {
+		return nil
+	}
+
+	b, err := this.marshal()
+	if err != nil {
+		log.Warn("save shared object ", this.path, " failed, err=", err)
+		return nil
+	}
+	return b
+}
+
+func (this *SrsSharedObject) marshal() ([]byte, error) {
+	data := srsSharedObjectData{
+		Version:    this.version,
+		Properties: make(map[string]interface{}),
+	}
+	for i := 0; i < len(this.properties); i++ {
+		var value interface{}
+		if err := amf0.UnmarshalAny(this.properties[i].Value, &value); err != nil {
+			return nil, errors.New("property " + this.properties[i].Name.Value + " " + err.Error())
+		}
+		data.Properties[this.properties[i].Name.Value] = value
+	}
+	return json.Marshal(&data)
+}
+
+// store the json data to file, write to temp file then rename, to avoid the corrupt file.
+func (this *SrsSharedObject) store(b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(this.path), 0755); err != nil {
+		return err
+	}
+
+	tmp := this.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, this.path)
+}
@@ -25,12 +25,15 @@ package app
 
 import (
 	"errors"
+	log "github.com/sirupsen/logrus"
+	"go_srs/srs/app/config"
 	"go_srs/srs/codec/flv"
 	"go_srs/srs/global"
 	"go_srs/srs/protocol/packet"
 	"go_srs/srs/protocol/rtmp"
 	"go_srs/srs/utils"
 	"sync"
+	"time"
 )
 
 type ISrsSourceHandler interface {
@@ -45,12 +48,12 @@ type SrsSHRequester interface {
 type SrsSource struct {
 	source_id int64
 	handler   ISrsSourceHandler
-	conn      *SrsRtmpConn
-	rtmp      *rtmp.SrsRtmpServer
 	req       *SrsRequest
 
-	consumersMtx  sync.Mutex
-	consumers     []Consumer
+	consumersMtx sync.Mutex
+	consumers    []Consumer
+	// the caches are updated by publisher and dumped to new consumers.
+	cacheMtx      sync.Mutex
 	gopCache      *SrsGopCache
 	cacheSHVideo  *rtmp.SrsRtmpMessage
 	cacheSHAudio  *rtmp.SrsRtmpMessage
@@ -64,8 +67,34 @@ type SrsSource struct {
 	// TODO: FIXME: to support reload atc.
 	atc             bool
 	jitterAlgorithm *SrsRtmpJitterAlgorithm
+	// the forwarders to the destinations of vhost forward config.
+	forwarders []*SrsForwarder
+	// the edge to pull from and proxy publish to origin, nil if not edge vhost.
+	playEdge    *SrsPlayEdge
+	publishEdge *SrsPublishEdge
+
+	/**
+	* the source is not bound to any client, the player can wait for the publisher,
+	* and it's reaped when no publisher and player for the idle timeout of vhost.
+	 */
+	stateMtx   sync.Mutex
+	publishing bool
+	// the publisher of source, and notify the publisher takeover the stream when unpublished.
+	publisher   *SrsRtmpConn
+	unpublished chan bool
+	// the timer to reap the idle source, the seq is increased to cancel the fired timer.
+	idleTimer *time.Timer
+	idleSeq   int64
+	// the timer to disconnect the players when publisher not republish, the players are kept for reconnect.
+	republishTimer *time.Timer
+	republishSeq   int64
 }
 
+// the max time to wait for the old publisher unpublished, when takeover the stream.
+const SRS_PUBLISH_TAKEOVER_TIMEOUT = 10 * time.Second
+
+var errStreamBusy = errors.New("stream is busy, already publishing")
+
 var sourcePoolMtx sync.Mutex
 var sourcePool map[string]*SrsSource
 
@@ -73,34 +102,17 @@ func init() {
 	sourcePool = make(map[string]*SrsSource)
 }
 
-func NewSrsSource(c *SrsRtmpConn, r *SrsRequest, h ISrsSourceHandler) *SrsSource {
-	source := &SrsSource{
-		source_id: c.id,
-		req:       r,
-		conn:      c,
-		handler:   h,
-		rtmp:      c.rtmp,
-		gopCache:  NewSrsGopCache(),
-		atc:       false,
-	}
-
-	dvrConsumer := NewSrsDvrConsumer(source, r)
-	if dvrConsumer != nil {
-		source.AppendConsumer(dvrConsumer)
-		go func() {
-			dvrConsumer.ConsumeCycle()
-		}()
-	}
-
-	hlsConsumer := NewSrsHlsConsumer(source, r)
-	if dvrConsumer != nil {
-		source.AppendConsumer(hlsConsumer)
-		go func() {
-			hlsConsumer.ConsumeCycle()
-		}()
+func NewSrsSource(r *SrsRequest, h ISrsSourceHandler) *SrsSource {
+	// each consumer corrects the timestamp by the jitter algorithm of vhost.
+	jitterAlgorithm := parseJitterAlgorithm(config.GetTimeJitter(r.vhost))
+	return &SrsSource{
+		source_id:       utils.SrsGenerateId(),
+		req:             r.Copy(),
+		handler:         h,
+		gopCache:        NewSrsGopCache(),
+		atc:             false,
+		jitterAlgorithm: &jitterAlgorithm,
 	}
-
-	return source
 }
 
 func RemoveSrsSource(s *SrsSource) {
@@ -113,24 +125,24 @@ func RemoveSrsSource(s *SrsSource) {
 	}
 }
 
-func FetchOrCreate(c *SrsRtmpConn, r *SrsRequest, h ISrsSourceHandler) (*SrsSource, error) {
-	source := FetchSource(r)
-	if source != nil {
-		return source, nil
-	}
-
-	streamUrl := r.GetStreamUrl()
-	vhost := r.vhost
-	_ = vhost
+/**
+* fetch the source of stream, or create it for the publisher or player,
+* the idle timer of source is restarted, for the client to attach to it.
+ */
+func FetchOrCreate(r *SrsRequest, h ISrsSourceHandler) (*SrsSource, error) {
 	sourcePoolMtx.Lock()
 	defer sourcePoolMtx.Unlock()
-	if s, ok := sourcePool[streamUrl]; ok {
-		return s, errors.New("source already in pool")
+
+	streamUrl := r.GetStreamUrl()
+	source, ok := sourcePool[streamUrl]
+	if !ok {
+		source = NewSrsSource(r, h)
+		//todo fix return value
+		source.Initialize()
+		sourcePool[streamUrl] = source
 	}
-	source = NewSrsSource(c, r, h)
-	//todo fix return value
-	source.Initialize()
-	sourcePool[streamUrl] = source
+
+	source.checkIdle()
 	return source, nil
 }
 
@@ -164,6 +176,8 @@ func (this *SrsSource) OnRequestSH(requester SrsSHRequester) error {
 		return errors.New("missing video sh")
 	}
 
+	this.cacheMtx.Lock()
+	defer this.cacheMtx.Unlock()
 	requester.GetSH(this.cacheMetaData, this.cacheSHAudio, this.cacheSHVideo)
 	return nil
 }
@@ -191,8 +205,12 @@ func (this *SrsSource) on_dvr_request_sh() error {
 }
 
 func (this *SrsSource) onPublish() error {
-	for i := 0; i < len(this.consumers); i++ {
-		this.consumers[i].OnPublish()
+	this.createForwarders()
+	this.createDvrHls()
+
+	consumers := this.copyConsumers()
+	for i := 0; i < len(consumers); i++ {
+		consumers[i].OnPublish()
 	}
 
 	if this.handler != nil {
@@ -207,7 +225,362 @@ func (this *SrsSource) onPublish() error {
 	return nil
 }
 
+/**
+* create the forwarders for the destinations of vhost forward config,
+* the forwarders are consumers of source, stopped when unpublish.
+ */
+func (this *SrsSource) createForwarders() {
+	destinations := config.GetForward(this.req.vhost)
+	for i := 0; i < len(destinations); i++ {
+		forwarder := NewSrsForwarder(this, this.req, destinations[i])
+		this.consumersMtx.Lock()
+		this.forwarders = append(this.forwarders, forwarder)
+		this.consumersMtx.Unlock()
+		this.AppendConsumer(forwarder)
+		go func() {
+			forwarder.ConsumeCycle()
+		}()
+	}
+}
+
+/**
+* create the dvr and hls of publisher, which are consumers of source,
+* stopped when unpublish, the edge never dvr or hls, which is done by origin.
+ */
+func (this *SrsSource) createDvrHls() {
+	if config.GetVhostIsEdge(this.req.vhost) {
+		return
+	}
+
+	dvrConsumer := NewSrsDvrConsumer(this, this.req)
+	if dvrConsumer != nil {
+		this.AppendConsumer(dvrConsumer)
+		go func() {
+			dvrConsumer.ConsumeCycle()
+		}()
+	}
+
+	hlsConsumer := NewSrsHlsConsumer(this, this.req)
+	if hlsConsumer != nil {
+		this.AppendConsumer(hlsConsumer)
+		go func() {
+			hlsConsumer.ConsumeCycle()
+		}()
+	}
+}
+
+func (this *SrsSource) GetForwarderStats() []SrsForwarderStat {
+	this.consumersMtx.Lock()
+	defer this.consumersMtx.Unlock()
+
+	stats := make([]SrsForwarderStat, 0, len(this.forwarders))
+	for i := 0; i < len(this.forwarders); i++ {
+		stats = append(stats, this.forwarders[i].GetStat())
+	}
+	return stats
+}
+
+// get the merged write stats of players, to tune the mw_latency and mw_msgs.
+func (this *SrsSource) GetMergedWriteStats() []SrsMergedWriteStat {
+	this.consumersMtx.Lock()
+	defer this.consumersMtx.Unlock()
+
+	stats := make([]SrsMergedWriteStat, 0, len(this.consumers))
+	for i := 0; i < len(this.consumers); i++ {
+		if w, ok := this.consumers[i].(MergedWriter); ok {
+			stats = append(stats, w.GetMergedWriteStat())
+		}
+	}
+	return stats
+}
+
+// dump the metadata, sequence headers and gop cache to the queue.
+func (this *SrsSource) dumpCacheTo(queue *SrsMessageQueue) {
+	msgs := this.cachedMessages()
+	for i := 0; i < len(msgs); i++ {
+		queue.Enqueue(msgs[i])
+		msgs[i].Release()
+	}
+}
+
+/**
+* get the metadata, sequence headers and gop cache, which are retained,
+* user must release them when done.
+ */
+func (this *SrsSource) cachedMessages() []*rtmp.SrsRtmpMessage {
+	this.cacheMtx.Lock()
+	defer this.cacheMtx.Unlock()
+
+	msgs := make([]*rtmp.SrsRtmpMessage, 0, 3+len(this.gopCache.gopCache))
+	if this.cacheMetaData != nil {
+		msgs = append(msgs, this.cacheMetaData)
+	}
+
+	if this.cacheSHVideo != nil {
+		msgs = append(msgs, this.cacheSHVideo)
+	}
+
+	if this.cacheSHAudio != nil {
+		msgs = append(msgs, this.cacheSHAudio)
+	}
+
+	msgs = append(msgs, this.gopCache.gopCache...)
+	for i := 0; i < len(msgs); i++ {
+		msgs[i].Retain()
+	}
+	return msgs
+}
+
+// clear the gop cache, for example, the edge stop ingest.
+func (this *SrsSource) clearGopCache() {
+	this.cacheMtx.Lock()
+	defer this.cacheMtx.Unlock()
+	this.gopCache.clear()
+}
+
 func (this *SrsSource) Initialize() {
+	if config.GetVhostIsEdge(this.req.vhost) {
+		this.playEdge = NewSrsPlayEdge(this, this.req)
+		this.publishEdge = NewSrsPublishEdge(this.req)
+	}
+}
+
+// for edge, when player start play, pull the stream from origin.
+func (this *SrsSource) OnEdgeStartPlay() error {
+	if this.playEdge == nil {
+		return errors.New("source is not edge")
+	}
+	return this.playEdge.OnClientPlay()
+}
+
+// for edge, when client publish, proxy the stream to origin.
+func (this *SrsSource) OnEdgeStartPublish() error {
+	if this.publishEdge == nil {
+		return errors.New("source is not edge")
+	}
+
+	return this.publishEdge.OnClientPublish()
+}
+
+// for edge, proxy the audio, video and data message of publisher to origin.
+func (this *SrsSource) OnEdgeProxyPublish(msg *rtmp.SrsRtmpMessage) error {
+	header := msg.GetHeader()
+	if !header.IsAV() && !header.IsAmf0Data() && !header.IsAmf3Data() && !header.IsAggregate() {
+		return nil
+	}
+	return this.publishEdge.OnProxyPublish(msg)
+}
+
+// for edge, when publisher stop, stop proxy to origin.
+func (this *SrsSource) OnEdgeProxyUnpublish() {
+	if this.publishEdge != nil {
+		this.publishEdge.OnProxyUnpublish()
+	}
+}
+
+// for edge, notify the play edge when no player left.
+func (this *SrsSource) checkEdgePlayers() {
+	if this.playEdge == nil {
+		return
+	}
+
+	for i := 0; i < len(this.consumers); i++ {
+		if isSrsPlayer(this.consumers[i]) {
+			return
+		}
+	}
+	this.playEdge.OnAllClientStop()
+}
+
+// the consumers of source, the dvr, hls and forwarders are stopped when unpublish.
+func (this *SrsSource) copyConsumers() []Consumer {
+	this.consumersMtx.Lock()
+	defer this.consumersMtx.Unlock()
+
+	consumers := make([]Consumer, len(this.consumers))
+	copy(consumers, this.consumers)
+	return consumers
+}
+
+// whether the consumer is a player, for example, the rtmp or http flv client.
+func isSrsPlayer(consumer Consumer) bool {
+	switch consumer.(type) {
+	case *SrsConsumer, *SrsHttpFlvConsumer, *SrsHttpTsConsumer:
+		return true
+	}
+	return false
+}
+
+/**
+* test and set the publisher, the stream is busy when already publishing, or
+* when takeover is on, kick the old publisher and wait for it unpublished,
+* the idle timer is stopped when publishing.
+ */
+func (this *SrsSource) acquirePublisher(conn *SrsRtmpConn) error {
+	if this.tryPublisher(conn) {
+		return nil
+	}
+
+	this.stateMtx.Lock()
+	old, unpublished := this.publisher, this.unpublished
+	this.stateMtx.Unlock()
+	if old == nil || !config.GetPublishTakeover(this.req.vhost) {
+		return errStreamBusy
+	}
+
+	log.Info("source ", this.req.GetStreamUrl(), " takeover, kick the old publisher")
+	old.Close()
+	select {
+	case <-unpublished:
+	case <-time.After(SRS_PUBLISH_TAKEOVER_TIMEOUT):
+		return errors.New("takeover timeout, the old publisher not unpublished")
+	}
+
+	// another publisher may takeover it first.
+	if !this.tryPublisher(conn) {
+		return errStreamBusy
+	}
+	return nil
+}
+
+func (this *SrsSource) tryPublisher(conn *SrsRtmpConn) bool {
+	this.stateMtx.Lock()
+	defer this.stateMtx.Unlock()
+
+	if this.publishing {
+		return false
+	}
+
+	this.publishing = true
+	this.publisher = conn
+	this.unpublished = make(chan bool)
+	this.cancelIdle()
+	this.cancelRepublish()
+	return true
+}
+
+// when publisher stop, the publisher takeover the stream is notified, and the idle timer restarted.
+func (this *SrsSource) releasePublisher() {
+	this.stateMtx.Lock()
+	this.publishing = false
+	this.publisher = nil
+	if this.unpublished != nil {
+		close(this.unpublished)
+		this.unpublished = nil
+	}
+	this.stateMtx.Unlock()
+
+	this.checkIdle()
+}
+
+// whether the source has publisher or player, the idle source is reaped.
+func (this *SrsSource) isIdle() bool {
+	this.stateMtx.Lock()
+	publishing := this.publishing
+	this.stateMtx.Unlock()
+	if publishing {
+		return false
+	}
+
+	consumers := this.copyConsumers()
+	for i := 0; i < len(consumers); i++ {
+		if isSrsPlayer(consumers[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+/**
+* restart the idle timer, the source is reaped if it's idle when timer fired,
+* so it's safe to restart when client attach or leave.
+ */
+func (this *SrsSource) checkIdle() {
+	this.stateMtx.Lock()
+	defer this.stateMtx.Unlock()
+
+	this.cancelIdle()
+	seq := this.idleSeq
+	timeout := time.Duration(config.GetSourceIdleTimeout(this.req.vhost)) * time.Millisecond
+	this.idleTimer = time.AfterFunc(timeout, func() {
+		this.onIdle(seq)
+	})
+}
+
+func (this *SrsSource) cancelIdle() {
+	this.idleSeq++
+	if this.idleTimer != nil {
+		this.idleTimer.Stop()
+		this.idleTimer = nil
+	}
+}
+
+// reap the source when no publisher and player, the client fetch the source restart the timer.
+func (this *SrsSource) onIdle(seq int64) {
+	sourcePoolMtx.Lock()
+	this.stateMtx.Lock()
+	fired := seq == this.idleSeq
+	if fired {
+		this.idleTimer = nil
+	}
+	this.stateMtx.Unlock()
+
+	if !fired || !this.isIdle() {
+		sourcePoolMtx.Unlock()
+		return
+	}
+
+	streamUrl := this.req.GetStreamUrl()
+	if sourcePool[streamUrl] == this {
+		delete(sourcePool, streamUrl)
+	}
+	sourcePoolMtx.Unlock()
+
+	log.Info("source ", streamUrl, " reaped, no publisher and player for idle timeout")
+	if this.playEdge != nil {
+		this.playEdge.Stop()
+	}
+	this.RemoveConsumers()
+}
+
+/**
+* when publisher stop, the players are kept for the republish timeout of vhost,
+* for the encoder to reconnect, they are disconnected if no publisher in time.
+ */
+func (this *SrsSource) waitRepublish() {
+	this.stateMtx.Lock()
+	defer this.stateMtx.Unlock()
+
+	this.cancelRepublish()
+	seq := this.republishSeq
+	timeout := time.Duration(config.GetRepublishTimeout(this.req.vhost)) * time.Millisecond
+	this.republishTimer = time.AfterFunc(timeout, func() {
+		this.onRepublishTimeout(seq)
+	})
+}
+
+func (this *SrsSource) cancelRepublish() {
+	this.republishSeq++
+	if this.republishTimer != nil {
+		this.republishTimer.Stop()
+		this.republishTimer = nil
+	}
+}
+
+// disconnect the players when no publisher, the next publisher wait for the players stopped.
+func (this *SrsSource) onRepublishTimeout(seq int64) {
+	this.stateMtx.Lock()
+	fired := seq == this.republishSeq && !this.publishing
+	if fired {
+		this.republishTimer = nil
+		log.Info("source ", this.req.GetStreamUrl(), " not republish in timeout, stop the players")
+		this.removeConsumers(isSrsPlayer)
+	}
+	this.stateMtx.Unlock()
+
+	if fired {
+		this.checkIdle()
+	}
 }
 
 func (this *SrsSource) OnRecvError(err error) {
@@ -223,40 +596,81 @@ func (this *SrsSource) RemoveConsumers() {
 	}
 
 	this.consumers = this.consumers[0:0]
+	this.checkEdgePlayers()
 }
 
-func (this *SrsSource) OnAudio(msg *rtmp.SrsRtmpMessage) error {
-	isSequenceHeader := flvcodec.AudioIsSequenceHeader(msg.GetPayload())
-	if isSequenceHeader {
-		this.cacheSHAudio = msg
+// stop and remove the matched consumers, the others are kept.
+func (this *SrsSource) removeConsumers(match func(consumer Consumer) bool) {
+	this.consumersMtx.Lock()
+	defer this.consumersMtx.Unlock()
+
+	consumers := make([]Consumer, 0, len(this.consumers))
+	for i := 0; i < len(this.consumers); i++ {
+		if !match(this.consumers[i]) {
+			consumers = append(consumers, this.consumers[i])
+			continue
+		}
+		this.consumers[i].StopConsume()
 	}
 
+	this.consumers = consumers
+	this.checkEdgePlayers()
+}
+
+// deliver the msg to all consumers, which retain the msg in queue.
+func (this *SrsSource) deliver(msg *rtmp.SrsRtmpMessage) {
+	this.consumersMtx.Lock()
+	defer this.consumersMtx.Unlock()
+
 	for i := 0; i < len(this.consumers); i++ {
-		this.consumers[i].Enqueue(msg, false, this.jitterAlgorithm)
+		this.consumers[i].Enqueue(msg, this.atc, this.jitterAlgorithm)
 	}
+}
 
-	if err := this.gopCache.cache(msg); err != nil {
+// release the cached msg, nil is returned to reset the cache.
+func releaseCache(msg *rtmp.SrsRtmpMessage) *rtmp.SrsRtmpMessage {
+	if msg != nil {
+		msg.Release()
 	}
 	return nil
 }
 
-func (this *SrsSource) OnVideo(msg *rtmp.SrsRtmpMessage) error {
-	isSequenceHeader := flvcodec.VideoIsSequenceHeader(msg.GetPayload())
-	if isSequenceHeader {
-		this.cacheSHVideo = msg
+// retain the msg to cache, and release the previous cached one.
+func retainCache(prev *rtmp.SrsRtmpMessage, msg *rtmp.SrsRtmpMessage) *rtmp.SrsRtmpMessage {
+	msg.Retain()
+	if prev != nil {
+		prev.Release()
 	}
+	return msg
+}
 
-	for i := 0; i < len(this.consumers); i++ {
-		this.consumers[i].Enqueue(msg, false, this.jitterAlgorithm)
+func (this *SrsSource) OnAudio(msg *rtmp.SrsRtmpMessage) error {
+	isSequenceHeader := flvcodec.AudioIsSequenceHeader(msg.GetPayload())
+	this.cacheMtx.Lock()
+	if isSequenceHeader {
+		this.cacheSHAudio = retainCache(this.cacheSHAudio, msg)
 	}
-
 	if err := this.gopCache.cache(msg); err != nil {
-		return err
 	}
+	this.cacheMtx.Unlock()
 
+	this.deliver(msg)
 	return nil
 }
 
+func (this *SrsSource) OnVideo(msg *rtmp.SrsRtmpMessage) error {
+	isSequenceHeader := flvcodec.VideoIsSequenceHeader(msg.GetPayload())
+	this.cacheMtx.Lock()
+	if isSequenceHeader {
+		this.cacheSHVideo = retainCache(this.cacheSHVideo, msg)
+	}
+	err := this.gopCache.cache(msg)
+	this.cacheMtx.Unlock()
+
+	this.deliver(msg)
+	return err
+}
+
 func (this *SrsSource) OnMetaData(msg *rtmp.SrsRtmpMessage, pkt *packet.SrsOnMetaDataPacket) error {
 	// SrsAmf0Any* prop = NULL;
 
@@ -301,23 +715,16 @@ func (this *SrsSource) OnMetaData(msg *rtmp.SrsRtmpMessage, pkt *packet.SrsOnMet
 	//     }
 	// }
 
-	// encode the metadata to payload
-	d := make([]byte, 0)
-	stream := utils.NewSrsStream(d)
-	if err := pkt.Encode(stream); err != nil {
+	// encode the metadata to payload, the @setDataFrame is unwrapped.
+	metaData, err := newSrsDataMessage(msg, pkt)
+	if err != nil {
 		return err
 	}
 
-	//this.cacheMetaData = rtmp.NewSrsRtmpMessage()
-	//this.cacheMetaData.SetHeader(*(msg.GetHeader()))
-	//
-	//this.cacheMetaData.GetHeader().SetLength(int32(len(stream.Data())))
-	//this.cacheMetaData.GetHeader().Print()
-	//this.cacheMetaData.SetPayload(stream.Data())
-	this.cacheMetaData = msg
-	for i := 0; i < len(this.consumers); i++ {
-		this.consumers[i].Enqueue(msg, false, this.jitterAlgorithm)
-	}
+	this.cacheMtx.Lock()
+	this.cacheMetaData = retainCache(this.cacheMetaData, metaData)
+	this.cacheMtx.Unlock()
+	this.deliver(metaData)
 
 	//if err := this.dvr.OnMetaData(msg); err != nil {
 	//return err
@@ -325,6 +732,32 @@ func (this *SrsSource) OnMetaData(msg *rtmp.SrsRtmpMessage, pkt *packet.SrsOnMet
 	return nil
 }
 
+/**
+* the timed data of stream, for example, the onTextData and onCuePoint,
+* which is delivered to all consumers in order with audio and video, but not cached.
+ */
+func (this *SrsSource) OnData(msg *rtmp.SrsRtmpMessage, pkt *packet.SrsOnDataPacket) error {
+	data, err := newSrsDataMessage(msg, pkt)
+	if err != nil {
+		return err
+	}
+
+	this.deliver(data)
+	return nil
+}
+
+/**
+* encode the data packet to the amf0 data message with the timestamp of msg,
+* so the wrapped @setDataFrame and the amf3 data are delivered as plain amf0 data.
+ */
+func newSrsDataMessage(msg *rtmp.SrsRtmpMessage, pkt packet.SrsPacket) (*rtmp.SrsRtmpMessage, error) {
+	stream := utils.NewSrsStream([]byte{})
+	if err := pkt.Encode(stream); err != nil {
+		return nil, err
+	}
+	return rtmp.NewSrsRtmpMessageWithPayload(global.RTMP_MSG_AMF0DataMessage, msg.GetHeader().GetTimestamp(), stream.Data()), nil
+}
+
 //TODO
 func (this *SrsSource) SetCache(cache bool) {
 
@@ -338,60 +771,27 @@ func (this *SrsSource) SetCache(cache bool) {
 * @param dg, whether dumps the gop cache.
  */
 func (this *SrsSource) CreateConsumer(conn *SrsRtmpConn, ds bool, dm bool, db bool) Consumer {
-	this.consumersMtx.Lock()
 	consumer := NewSrsConsumer(this, conn)
-	this.consumers = append(this.consumers, consumer)
-	this.consumersMtx.Unlock()
-	//todo set queue size
-	//todo process atc
-	//todo copy meta data
-	//todo cppy sequence header
-	//todo copy gop to consumers queue
-	//many things todo
-	if this.cacheMetaData != nil {
-		consumer.Enqueue(this.cacheMetaData, false, this.jitterAlgorithm)
-	}
-
-	if this.cacheSHVideo != nil {
-		consumer.Enqueue(this.cacheSHVideo, false, this.jitterAlgorithm)
-	}
-
-	if this.cacheSHAudio != nil {
-		consumer.Enqueue(this.cacheSHAudio, false, this.jitterAlgorithm)
-	}
-
-	if err := this.gopCache.dump(consumer, false, this.jitterAlgorithm); err != nil {
-		return nil
-	}
-
+	this.AppendConsumer(consumer)
 	return consumer
 }
 
+/**
+* dumps the cache to consumer then append it, under the lock of consumers,
+* so the consumer never enqueue the cache and the delivered msg concurrently.
+ */
 func (this *SrsSource) AppendConsumer(consumer Consumer) error {
 	this.consumersMtx.Lock()
-	this.consumers = append(this.consumers, consumer)
-	this.consumersMtx.Unlock()
+	defer this.consumersMtx.Unlock()
 	//todo set queue size
 	//todo process atc
-	//todo copy meta data
-	//todo cppy sequence header
-	//todo copy gop to consumers queue
 	//many things todo
-	if this.cacheMetaData != nil {
-		consumer.Enqueue(this.cacheMetaData, false, this.jitterAlgorithm)
-	}
-
-	if this.cacheSHVideo != nil {
-		consumer.Enqueue(this.cacheSHVideo, false, this.jitterAlgorithm)
-	}
-
-	if this.cacheSHAudio != nil {
-		consumer.Enqueue(this.cacheSHAudio, false, this.jitterAlgorithm)
-	}
-
-	if err := this.gopCache.dump(consumer, false, this.jitterAlgorithm); err != nil {
-		return err
+	msgs := this.cachedMessages()
+	for i := 0; i < len(msgs); i++ {
+		consumer.Enqueue(msgs[i], this.atc, this.jitterAlgorithm)
+		msgs[i].Release()
 	}
+	this.consumers = append(this.consumers, consumer)
 	return nil
 }
 
@@ -401,23 +801,48 @@ func (this *SrsSource) OnConsumerError(consumer Consumer) {
 
 func (this *SrsSource) RemoveConsumer(consumer Consumer) {
 	this.consumersMtx.Lock()
-	defer this.consumersMtx.Unlock()
 	consumer.StopConsume()
 	for i := 0; i < len(this.consumers); i++ {
 		if this.consumers[i] == consumer {
 			this.consumers = append(this.consumers[:i], this.consumers[i+1:]...)
 		}
 	}
+	this.checkEdgePlayers()
+	this.consumersMtx.Unlock()
+
+	this.checkIdle()
 }
 
+/**
+* when publisher stop, stop the dvr, hls and forwarders of publisher,
+* the players are kept for the encoder to reconnect in the republish timeout,
+* the source is kept in pool for the next publisher, and reaped when idle.
+ */
 func (this *SrsSource) UnPublish() {
-	for i := 0; i < len(this.consumers); i++ {
-		this.consumers[i].OnUnpublish()
+	consumers := this.copyConsumers()
+	for i := 0; i < len(consumers); i++ {
+		consumers[i].OnUnpublish()
 	}
-	// remove all consumers
+	this.removeConsumers(func(consumer Consumer) bool {
+		return !isSrsPlayer(consumer)
+	})
 	this.consumersMtx.Lock()
-	defer this.consumersMtx.Unlock()
-	this.consumers = this.consumers[0:0]
+	this.forwarders = this.forwarders[0:0]
+	this.consumersMtx.Unlock()
+
+	// the caches of publisher is stale for the next publisher.
+	this.cacheMtx.Lock()
+	this.gopCache.clear()
+	this.cacheMetaData = releaseCache(this.cacheMetaData)
+	this.cacheSHVideo = releaseCache(this.cacheSHVideo)
+	this.cacheSHAudio = releaseCache(this.cacheSHAudio)
+	this.cacheMtx.Unlock()
+
+	this.waitRepublish()
+
+	if this.handler != nil {
+		this.handler.OnUnpublish(this, this.req)
+	}
 
 	stat := GetStatisticInstance()
 	stat.OnStreamClose(this.req, this.source_id)
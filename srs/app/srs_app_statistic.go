@@ -28,6 +28,7 @@ import (
 	"go_srs/srs/codec"
 	"go_srs/srs/utils"
 	"sync"
+	"time"
 )
 
 type SrsStatisticVhost struct {
@@ -88,6 +89,9 @@ type SrsStatisticStream struct {
 	nb_frames      uint64                   `json:"frames"`
 	video          *SrsStatisticStreamVideo `json:"video"`
 	audio          *SrsStatisticStreamAudio `json:"audio"`
+	// the merged write of players, the average merged messages is mw_msgs/mw_writes.
+	mw_writes int64
+	mw_msgs   int64
 }
 
 func NewSrsStatisticStream() *SrsStatisticStream {
@@ -114,8 +118,10 @@ func (this *SrsStatisticStream) Close() {
 
 type SrsStatisticClient struct {
 	stream *SrsStatisticStream
-	id     int
+	id     int64
 	create int64
+	// the rtt measured by the ping of connection.
+	rtt time.Duration
 }
 
 type SrsStatistic struct {
@@ -123,7 +129,9 @@ type SrsStatistic struct {
 	rvhosts  map[string]*SrsStatisticVhost
 	streams  map[int64]*SrsStatisticStream
 	rstreams map[string]*SrsStatisticStream
-	clients  map[int64]*SrsStatisticClient
+	// the clients is updated by the ping of each connection.
+	clientsMtx sync.Mutex
+	clients    map[int64]*SrsStatisticClient
 }
 
 func (this *SrsStatistic) FindVHost(vid int64) *SrsStatisticVhost {
@@ -143,6 +151,8 @@ func (this *SrsStatistic) FindStream(sid int64) *SrsStatisticStream {
 }
 
 func (this *SrsStatistic) FindClient(cid int64) *SrsStatisticClient {
+	this.clientsMtx.Lock()
+	defer this.clientsMtx.Unlock()
 	c, ok := this.clients[cid]
 	if !ok {
 		return nil
@@ -176,6 +186,18 @@ func (this *SrsStatistic) OnVideoFrames(req *SrsRequest, nb_frames uint64) error
 	return nil
 }
 
+func (this *SrsStatistic) OnMergedWrite(req *SrsRequest, stats []SrsMergedWriteStat) error {
+	vhost := this.createVHost(req)
+	stream := this.createStream(vhost, req)
+	stream.mw_writes, stream.mw_msgs = 0, 0
+	for i := 0; i < len(stats); i++ {
+		stream.mw_writes += stats[i].Writes
+		stream.mw_msgs += stats[i].Msgs
+	}
+	log.Info("mw_writes=", stream.mw_writes, ", mw_msgs=", stream.mw_msgs)
+	return nil
+}
+
 func (this *SrsStatistic) OnStreamPublish(req *SrsRequest, cid int64) error {
 	vhost := this.createVHost(req)
 	stream := this.createStream(vhost, req)
@@ -190,6 +212,36 @@ func (this *SrsStatistic) OnStreamClose(req *SrsRequest, cid int64) error {
 	return nil
 }
 
+func (this *SrsStatistic) OnClientRtt(cid int64, rtt time.Duration) {
+	this.clientsMtx.Lock()
+	defer this.clientsMtx.Unlock()
+	c, ok := this.clients[cid]
+	if !ok {
+		c = &SrsStatisticClient{
+			id:     cid,
+			create: time.Now().UnixNano() / int64(time.Millisecond),
+		}
+		this.clients[cid] = c
+	}
+	c.rtt = rtt
+}
+
+func (this *SrsStatistic) GetClientRtt(cid int64) time.Duration {
+	this.clientsMtx.Lock()
+	defer this.clientsMtx.Unlock()
+	c, ok := this.clients[cid]
+	if !ok {
+		return 0
+	}
+	return c.rtt
+}
+
+func (this *SrsStatistic) OnDisconnect(cid int64) {
+	this.clientsMtx.Lock()
+	defer this.clientsMtx.Unlock()
+	delete(this.clients, cid)
+}
+
 func (this *SrsStatistic) createVHost(req *SrsRequest) *SrsStatisticVhost {
 	v, ok := this.rvhosts[req.vhost]
 	if !ok {
@@ -219,7 +271,9 @@ func (this *SrsStatistic) createStream(vhost *SrsStatisticVhost, req *SrsRequest
 
 func (this *SrsStatistic) addDeltaToKbps(conn *SrsRtmpConn) {
 	id := conn.id
+	this.clientsMtx.Lock()
 	client, ok := this.clients[id]
+	this.clientsMtx.Unlock()
 	if !ok {
 		return
 	}
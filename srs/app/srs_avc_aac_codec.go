@@ -148,7 +148,8 @@ func (this *SrsAvcAacCodec) audioAACDemux(data []byte, sampler *SrsCodecSampler)
 
 	sampler.AacPacketType = codec.SrsCodecAudioType(aacPacketType)
 	if aacPacketType == codec.SrsCodecAudioTypeSequenceHeader {
-		this.aacExtraData = stream.ReadLeftBytes()
+		// copy the extra data, for the payload of message is recycled.
+		this.aacExtraData = stream.CopyLeftBytes()
 		if err := this.audio_aac_sequence_header_demux(this.aacExtraData); err != nil {
 			return err
 		}
@@ -409,10 +410,12 @@ func (this *SrsAvcAacCodec) avcDemuxSpsPps(stream *utils.SrsStream) error {
 	}
 
 	if this.sequenceParameterSetLength > 0 {
-		this.sequenceParameterSetNALUnit, err = stream.ReadBytes(uint32(this.sequenceParameterSetLength))
-		if err != nil {
+		var sps []byte
+		if sps, err = stream.ReadBytes(uint32(this.sequenceParameterSetLength)); err != nil {
 			return err
 		}
+		// copy the sps, for the payload of message is recycled.
+		this.sequenceParameterSetNALUnit = append([]byte(nil), sps...)
 	}
 
 	numOfPictureParameterSets, err7 := stream.ReadInt8()
@@ -429,10 +432,11 @@ func (this *SrsAvcAacCodec) avcDemuxSpsPps(stream *utils.SrsStream) error {
 	}
 
 	if this.pictureParameterSetLength > 0 {
-		this.pictureParameterSetNALUnit, err = stream.ReadBytes(uint32(this.pictureParameterSetLength))
-		if err != nil {
+		var pps []byte
+		if pps, err = stream.ReadBytes(uint32(this.pictureParameterSetLength)); err != nil {
 			return err
 		}
+		this.pictureParameterSetNALUnit = append([]byte(nil), pps...)
 	}
 
 	return this.avc_demux_sps()
@@ -34,3 +34,17 @@ type Consumer interface {
 	OnRecvError(err error)
 	Enqueue(msg *rtmp.SrsRtmpMessage, atc bool, jitterAlgorithm *SrsRtmpJitterAlgorithm)
 }
+
+/**
+* the stat of merged write of player, the average merged messages is Msgs/Writes,
+* which is used to tune the mw_latency and mw_msgs of vhost.
+ */
+type SrsMergedWriteStat struct {
+	Writes int64 `json:"writes"`
+	Msgs   int64 `json:"msgs"`
+}
+
+// the consumer which merged write the messages to player.
+type MergedWriter interface {
+	GetMergedWriteStat() SrsMergedWriteStat
+}
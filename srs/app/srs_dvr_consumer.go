@@ -23,7 +23,9 @@ CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
 package app
 
 import (
+	"go_srs/srs/protocol/amf0"
 	"go_srs/srs/protocol/rtmp"
+	"go_srs/srs/utils"
 )
 
 type SrsDvrConsumer struct {
@@ -31,6 +33,8 @@ type SrsDvrConsumer struct {
 	req    *SrsRequest
 	queue  *SrsMessageQueue
 	plan   SrsDvrPlan
+	// closed when consume cycle done, the segment is closed after it.
+	done chan bool
 }
 
 func NewSrsDvrConsumer(s *SrsSource, req *SrsRequest) *SrsDvrConsumer {
@@ -42,6 +46,7 @@ func NewSrsDvrConsumer(s *SrsSource, req *SrsRequest) *SrsDvrConsumer {
 		source: s,
 		plan:   p,
 		queue:  NewSrsMessageQueue(),
+		done:   make(chan bool),
 	}
 }
 
@@ -55,6 +60,10 @@ func (this *SrsDvrConsumer) OnPublish() error {
 }
 
 func (this *SrsDvrConsumer) OnUnpublish() error {
+	// stop the consume cycle, then close the segment which is not written any more.
+	this.StopConsume()
+	<-this.done
+
 	if this.plan != nil {
 		return this.plan.OnUnpublish()
 	}
@@ -62,6 +71,7 @@ func (this *SrsDvrConsumer) OnUnpublish() error {
 }
 
 func (this *SrsDvrConsumer) ConsumeCycle() error {
+	defer close(this.done)
 	for {
 		msg, err := this.queue.Wait()
 		if err != nil {
@@ -69,24 +79,37 @@ func (this *SrsDvrConsumer) ConsumeCycle() error {
 		}
 
 		if msg != nil {
-			if msg.GetHeader().IsVideo() {
-				if err := this.plan.OnVideo(msg); err != nil {
-					return err
-				}
-			} else if msg.GetHeader().IsAudio() {
-				if err := this.plan.OnAudio(msg); err != nil {
-					return err
-				}
-			} else {
-				if err := this.plan.OnMetaData(msg); err != nil {
-					return err
-				}
+			if err := this.process(msg); err != nil {
+				return err
 			}
 		}
 	}
 	return nil
 }
 
+// process the message and release it.
+func (this *SrsDvrConsumer) process(msg *rtmp.SrsRtmpMessage) error {
+	defer msg.Release()
+
+	if msg.GetHeader().IsVideo() {
+		return this.plan.OnVideo(msg)
+	} else if msg.GetHeader().IsAudio() {
+		return this.plan.OnAudio(msg)
+	} else if isSrsMetaDataMessage(msg) {
+		return this.plan.OnMetaData(msg)
+	}
+	return this.plan.OnData(msg)
+}
+
+// whether the data message is onMetaData, the others are timed data, for example, onCuePoint.
+func isSrsMetaDataMessage(msg *rtmp.SrsRtmpMessage) bool {
+	var name amf0.SrsAmf0String
+	if err := name.Decode(utils.NewSrsStream(msg.GetPayload())); err != nil {
+		return false
+	}
+	return name.Value.Value == amf0.SRS_CONSTS_RTMP_ON_METADATA || name.Value.Value == amf0.SRS_CONSTS_RTMP_SET_DATAFRAME
+}
+
 func (this *SrsDvrConsumer) StopConsume() error {
 	//send connection close to response writer
 	this.queue.Break()
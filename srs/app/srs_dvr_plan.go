@@ -32,6 +32,7 @@ type SrsDvrPlan interface {
 	OnPublish() error
 	OnUnpublish() error
 	OnMetaData(metaData *rtmp.SrsRtmpMessage) error
+	OnData(data *rtmp.SrsRtmpMessage) error
 	OnVideo(video *rtmp.SrsRtmpMessage) error
 	OnAudio(audio *rtmp.SrsRtmpMessage) error
 }
@@ -69,6 +70,10 @@ func (this *SrsAppendDvrPlan) OnMetaData(metaData *rtmp.SrsRtmpMessage) error {
 	return this.segment.WriteMetaData(metaData)
 }
 
+func (this *SrsAppendDvrPlan) OnData(data *rtmp.SrsRtmpMessage) error {
+	return this.segment.WriteData(data)
+}
+
 func (this *SrsAppendDvrPlan) OnVideo(video *rtmp.SrsRtmpMessage) error {
 	return this.segment.WriteVideo(video)
 }
@@ -106,6 +111,10 @@ func (this *SrsSessionDvrPlan) OnMetaData(metaData *rtmp.SrsRtmpMessage) error {
 	return this.segment.WriteMetaData(metaData)
 }
 
+func (this *SrsSessionDvrPlan) OnData(data *rtmp.SrsRtmpMessage) error {
+	return this.segment.WriteData(data)
+}
+
 func (this *SrsSessionDvrPlan) OnVideo(video *rtmp.SrsRtmpMessage) error {
 	return this.segment.WriteVideo(video)
 }
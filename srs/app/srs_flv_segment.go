@@ -26,7 +26,6 @@ package app
 import (
 	"encoding/binary"
 	"errors"
-	"fmt"
 	"go_srs/srs/app/config"
 	"go_srs/srs/codec/flv"
 	"go_srs/srs/global"
@@ -51,6 +50,8 @@ type SrsFlvSegment struct {
 	tmpFlvFile      string
 	hasKeyFrame     bool
 	jitter          *SrsRtmpJitter
+	// the time jitter algorithm of dvr config.
+	jitterAlgorithm SrsRtmpJitterAlgorithm
 	file            *os.File
 }
 
@@ -61,6 +62,7 @@ func NewSrsFlvSegment(r *SrsRequest) *SrsFlvSegment {
 		previousPktTime: -1,
 		duration:        0,
 		streamDuration:  0,
+		jitterAlgorithm: parseJitterAlgorithm(config.GetDvrTimeJitter(r.vhost)),
 	}
 }
 
@@ -169,16 +171,16 @@ func (this *SrsFlvSegment) Close() error {
 func (this *SrsFlvSegment) WriteMetaData(msg *rtmp.SrsRtmpMessage) error {
 	stream := utils.NewSrsStream(msg.GetPayload())
 
-	var command amf0.SrsAmf0String
-	if err := command.Decode(stream); err != nil {
-		fmt.Println("111111111111111111")
-		return err
-	}
-
+	// the @setDataFrame is unwrapped by source, but ignore it if exists.
 	var name amf0.SrsAmf0String
 	if err := name.Decode(stream); err != nil {
 		return err
 	}
+	if name.Value.Value == amf0.SRS_CONSTS_RTMP_SET_DATAFRAME {
+		if err := name.Decode(stream); err != nil {
+			return err
+		}
+	}
 
 	marker, err := stream.PeekByte()
 	if err != nil {
@@ -266,13 +268,29 @@ func (this *SrsFlvSegment) onUpdateDuration(msg *rtmp.SrsRtmpMessage) error {
 	return nil
 }
 
+func (this *SrsFlvSegment) WriteData(msg *rtmp.SrsRtmpMessage) error {
+	msg = this.jitter.CorrectCopy(msg, this.jitterAlgorithm)
+	defer msg.Release()
+
+	if _, err := this.flvEncoder.WriteData(uint32(msg.GetHeader().GetTimestamp()), msg.GetPayload()); err != nil {
+		return err
+	}
+	return this.onUpdateDuration(msg)
+}
+
 func (this *SrsFlvSegment) WriteAudio(msg *rtmp.SrsRtmpMessage) error {
+	msg = this.jitter.CorrectCopy(msg, this.jitterAlgorithm)
+	defer msg.Release()
+
 	this.flvEncoder.WriteAudio(uint32(msg.GetHeader().GetTimestamp()), msg.GetPayload())
 	this.onUpdateDuration(msg)
 	return nil
 }
 
 func (this *SrsFlvSegment) WriteVideo(msg *rtmp.SrsRtmpMessage) error {
+	msg = this.jitter.CorrectCopy(msg, this.jitterAlgorithm)
+	defer msg.Release()
+
 	this.flvEncoder.WriteVideo(uint32(msg.GetHeader().GetTimestamp()), msg.GetPayload())
 	this.onUpdateDuration(msg)
 	return nil
@@ -0,0 +1,319 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2019 GOSRS(gosrs)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package app
+
+import (
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"go_srs/srs/app/config"
+	"go_srs/srs/global"
+	"go_srs/srs/protocol/rtmp"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the timeout to connect to the destination.
+const SRS_FORWARDER_CONNECT_TIMEOUT = 3 * time.Second
+
+// the interval to reconnect, doubled after each failure until the max.
+const SRS_FORWARDER_RECONNECT_MIN = 1 * time.Second
+const SRS_FORWARDER_RECONNECT_MAX = 30 * time.Second
+
+// the stat of forwarder, one for each destination.
+type SrsForwarderStat struct {
+	Destination string `json:"destination"`
+	Connected   bool   `json:"connected"`
+	Reconnects  int64  `json:"reconnects"`
+	Msgs        int64  `json:"msgs"`
+	SendBytes   int64  `json:"send_bytes"`
+	LastError   string `json:"last_error"`
+}
+
+/**
+* the forwarder forward the stream of source to the destination rtmp server,
+* it's a consumer of source, when connected, the metadata, sequence headers
+* and gop cache are sent first, then the messages of source.
+* reconnect when error, until the source is unpublished.
+ */
+type SrsForwarder struct {
+	source      *SrsSource
+	req         *SrsRequest
+	destination string
+
+	// the queue of current connection, nil when disconnected.
+	mtx      sync.Mutex
+	queue    *SrsMessageQueue
+	client   *rtmp.SrsRtmpClient
+	streamId int
+
+	statMtx sync.Mutex
+	stat    SrsForwarderStat
+	// the bytes sent by the closed connections.
+	closedBytes int64
+
+	exit     chan bool
+	stopOnce sync.Once
+}
+
+func NewSrsForwarder(s *SrsSource, req *SrsRequest, destination string) *SrsForwarder {
+	url := srsForwardUrl(destination, req)
+	return &SrsForwarder{
+		source:      s,
+		req:         req,
+		destination: url,
+		stat:        SrsForwarderStat{Destination: url},
+		exit:        make(chan bool),
+	}
+}
+
+/**
+* build the rtmp url of destination, the destination of config is:
+*       host[:port], forward to rtmp://host:port/app/stream?vhost=vhost
+*       rtmp url, forward to the url, the stream of request is used if no stream in url.
+ */
+func srsForwardUrl(destination string, req *SrsRequest) string {
+	if strings.Contains(destination, "://") {
+		if _, _, stream, err := rtmp.ParseRtmpUrl(destination); err == nil && stream == "" {
+			pos := strings.Index(destination, "?")
+			if pos < 0 {
+				return strings.TrimRight(destination, "/") + "/" + req.stream
+			}
+			return strings.TrimRight(destination[:pos], "/") + "/" + req.stream + destination[pos:]
+		}
+		return destination
+	}
+
+	host := destination
+	if _, _, err := net.SplitHostPort(destination); err != nil {
+		host = net.JoinHostPort(destination, global.SRS_CONSTS_RTMP_DEFAULT_PORT)
+	}
+	return "rtmp://" + host + "/" + req.app + "/" + req.stream + "?vhost=" + req.vhost
+}
+
+func (this *SrsForwarder) GetStat() SrsForwarderStat {
+	this.statMtx.Lock()
+	defer this.statMtx.Unlock()
+
+	stat := this.stat
+	stat.SendBytes = this.closedBytes
+	this.mtx.Lock()
+	if this.client != nil {
+		stat.SendBytes += this.client.GetSendBytes()
+	}
+	this.mtx.Unlock()
+	return stat
+}
+
+func (this *SrsForwarder) OnPublish() error {
+	return nil
+}
+
+func (this *SrsForwarder) OnUnpublish() error {
+	return this.StopConsume()
+}
+
+/**
+* the cycle to connect and forward, reconnect with backoff when error.
+* @return when the forwarder is stopped.
+ */
+func (this *SrsForwarder) ConsumeCycle() error {
+	interval := SRS_FORWARDER_RECONNECT_MIN
+	for {
+		err := this.connect()
+		if err == nil {
+			interval = SRS_FORWARDER_RECONNECT_MIN
+			err = this.forward()
+		}
+		this.disconnect(err)
+
+		select {
+		case <-this.exit:
+			return nil
+		default:
+		}
+
+		log.Error("forward to ", this.destination, " failed, retry after ", interval, ", err=", err)
+		select {
+		case <-this.exit:
+			return nil
+		case <-time.After(interval):
+		}
+
+		this.statMtx.Lock()
+		this.stat.Reconnects++
+		this.statMtx.Unlock()
+
+		if interval *= 2; interval > SRS_FORWARDER_RECONNECT_MAX {
+			interval = SRS_FORWARDER_RECONNECT_MAX
+		}
+	}
+}
+
+func (this *SrsForwarder) connect() error {
+	client, err := rtmp.DialSrsRtmpClient(this.destination, SRS_FORWARDER_CONNECT_TIMEOUT)
+	if err != nil {
+		return err
+	}
+
+	if err = client.SetChunkSize(config.GetInstance().GetChunkSize(this.req.vhost)); err != nil {
+		client.Close()
+		return err
+	}
+
+	streamId, err := client.FmlePublish(client.Stream())
+	if err != nil {
+		client.Close()
+		return err
+	}
+
+	// dump the cache of source to the new queue, then the messages enqueued by source.
+	this.mtx.Lock()
+	select {
+	case <-this.exit:
+		this.mtx.Unlock()
+		client.Close()
+		return errors.New("forwarder stopped")
+	default:
+	}
+	// the queue is bounded by duration, to drop the messages when destination is slow.
+	this.queue = NewSrsMessageQueue()
+	this.queue.SetQueueSize(float64(config.GetQueueLength(this.req.vhost)))
+	this.source.dumpCacheTo(this.queue)
+	this.client = client
+	this.streamId = streamId
+	this.mtx.Unlock()
+
+	this.statMtx.Lock()
+	this.stat.Connected = true
+	this.statMtx.Unlock()
+
+	log.Info("forward to ", this.destination, " connected, stream_id=", streamId)
+	go this.recvCycle(client)
+	return nil
+}
+
+/**
+* read the messages from destination, for the protocol control messages, for example, the ack,
+* when error, close the client to quit the forward.
+ */
+func (this *SrsForwarder) recvCycle(client *rtmp.SrsRtmpClient) {
+	for {
+		if _, err := client.RecvMessage(); err != nil {
+			client.Close()
+			this.mtx.Lock()
+			if this.client == client && this.queue != nil {
+				this.queue.Break()
+				this.queue = nil
+			}
+			this.mtx.Unlock()
+			return
+		}
+	}
+}
+
+func (this *SrsForwarder) forward() error {
+	this.mtx.Lock()
+	client, queue, streamId := this.client, this.queue, this.streamId
+	this.mtx.Unlock()
+
+	if queue == nil {
+		return errors.New("forwarder disconnected")
+	}
+
+	for {
+		msg, err := queue.Wait()
+		if err != nil {
+			return err
+		}
+
+		if msg == nil {
+			continue
+		}
+
+		err = client.SendMsg(msg, streamId)
+		msg.Release()
+		if err != nil {
+			return err
+		}
+
+		this.statMtx.Lock()
+		this.stat.Msgs++
+		this.statMtx.Unlock()
+	}
+}
+
+func (this *SrsForwarder) disconnect(err error) {
+	this.mtx.Lock()
+	client := this.client
+	if this.queue != nil {
+		this.queue.Break()
+	}
+	this.client = nil
+	this.queue = nil
+	this.mtx.Unlock()
+
+	this.statMtx.Lock()
+	defer this.statMtx.Unlock()
+	if client != nil {
+		client.Close()
+		this.closedBytes += client.GetSendBytes()
+	}
+	this.stat.Connected = false
+	if err != nil {
+		this.stat.LastError = err.Error()
+	}
+}
+
+func (this *SrsForwarder) StopConsume() error {
+	this.stopOnce.Do(func() {
+		close(this.exit)
+		this.mtx.Lock()
+		defer this.mtx.Unlock()
+		if this.queue != nil {
+			this.queue.Break()
+			this.queue = nil
+		}
+		if this.client != nil {
+			this.client.Close()
+		}
+	})
+	return nil
+}
+
+func (this *SrsForwarder) OnRecvError(err error) {
+	this.source.OnConsumerError(this)
+}
+
+/**
+* drop the message when disconnected, the cache is dumped when connected,
+* the queue never blocks the source, which shrinks when destination is slow.
+ */
+func (this *SrsForwarder) Enqueue(msg *rtmp.SrsRtmpMessage, atc bool, jitterAlgorithm *SrsRtmpJitterAlgorithm) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	if this.queue != nil {
+		this.queue.Enqueue(msg)
+	}
+}
@@ -88,21 +88,26 @@ func (this *SrsHlsConsumer) ConsumeCycle() error {
 		}
 
 		if msg != nil {
-			if msg.GetHeader().IsVideo() {
-				if err := this.onVideo(msg); err != nil {
-					return err
-				}
-			} else if msg.GetHeader().IsAudio() {
-				if err := this.onAudio(msg); err != nil {
-					return err
-				}
-			} else {
+			if err := this.process(msg); err != nil {
+				return err
 			}
 		}
 	}
 	return nil
 }
 
+// process the message and release it.
+func (this *SrsHlsConsumer) process(msg *rtmp.SrsRtmpMessage) error {
+	defer msg.Release()
+
+	if msg.GetHeader().IsVideo() {
+		return this.onVideo(msg)
+	} else if msg.GetHeader().IsAudio() {
+		return this.onAudio(msg)
+	}
+	return nil
+}
+
 func (this *SrsHlsConsumer) onVideo(video *rtmp.SrsRtmpMessage) error {
 	this.lastUpdateTime = utils.GetCurrentMs()
 
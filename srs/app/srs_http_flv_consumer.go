@@ -23,10 +23,12 @@ CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
 package app
 
 import (
-	"fmt"
+	"go_srs/srs/app/config"
 	"go_srs/srs/codec/flv"
 	"go_srs/srs/protocol/rtmp"
 	"net/http"
+	"sync/atomic"
+	"time"
 )
 
 type SrsHttpFlvConsumer struct {
@@ -35,6 +37,12 @@ type SrsHttpFlvConsumer struct {
 	StreamId   int
 	writer     http.ResponseWriter
 	flvEncoder *flvcodec.SrsFlvEncoder
+	// merge the messages to send in a writev.
+	mwLatency time.Duration
+	mwMsgs    int
+	mwStat    SrsMergedWriteStat
+	// correct the timestamp by the jitter algorithm of vhost, monotonic when publisher republish.
+	jitter *SrsRtmpJitter
 }
 
 func NewSrsHttpFlvConsumer(s *SrsSource, w http.ResponseWriter, r *http.Request) *SrsHttpFlvConsumer {
@@ -44,6 +52,9 @@ func NewSrsHttpFlvConsumer(s *SrsSource, w http.ResponseWriter, r *http.Request)
 		queue:      NewSrsMessageQueue(),
 		StreamId:   0,
 		flvEncoder: flvcodec.NewSrsFlvEncoder(w),
+		mwLatency:  time.Millisecond * time.Duration(config.GetMwLatency(s.req.vhost)),
+		mwMsgs:     int(config.GetMwMsgs(s.req.vhost)),
+		jitter:     NewSrsRtmpJitter(),
 	}
 }
 
@@ -60,41 +71,53 @@ func (this *SrsHttpFlvConsumer) ConsumeCycle() error {
 	go func() {
 		notify := this.writer.(http.CloseNotifier).CloseNotify()
 		<-notify
-		this.StopConsume()
+		this.source.RemoveConsumer(this)
 	}()
 	this.writer.Header().Set("Content-Type", "video/x-flv")
 	for {
-		msg, err := this.queue.Wait()
+		msgs, err := this.queue.WaitBatch(this.mwMsgs, this.mwLatency)
 		if err != nil {
 			return err
 		}
 
-		if msg != nil {
-			if msg.GetHeader().IsVideo() {
-				if flvcodec.VideoIsKeyFrame(msg.GetPayload()) {
-					fmt.Println("send key frame")
-				}
-				this.flvEncoder.WriteVideo(uint32(msg.GetHeader().GetTimestamp()), msg.GetPayload())
-			} else if msg.GetHeader().IsAudio() {
-				this.flvEncoder.WriteAudio(uint32(msg.GetHeader().GetTimestamp()), msg.GetPayload())
-			} else {
-				this.flvEncoder.WriteMetaData(msg.GetPayload())
-			}
+		if len(msgs) == 0 {
+			continue
 		}
+
+		err = this.flvEncoder.WriteTags(msgs)
+		for i := 0; i < len(msgs); i++ {
+			msgs[i].Release()
+		}
+		if err != nil {
+			return err
+		}
+		if flusher, ok := this.writer.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		atomic.AddInt64(&this.mwStat.Writes, 1)
+		atomic.AddInt64(&this.mwStat.Msgs, int64(len(msgs)))
+	}
+}
+
+func (this *SrsHttpFlvConsumer) GetMergedWriteStat() SrsMergedWriteStat {
+	return SrsMergedWriteStat{
+		Writes: atomic.LoadInt64(&this.mwStat.Writes),
+		Msgs:   atomic.LoadInt64(&this.mwStat.Msgs),
 	}
 }
 
 func (this *SrsHttpFlvConsumer) StopConsume() error {
-	this.source.RemoveConsumer(this)
 	//send connection close to response writer
 	this.queue.Break()
 	return nil
 }
 
 func (this *SrsHttpFlvConsumer) OnRecvError(err error) {
-	this.StopConsume()
+	this.source.OnConsumerError(this)
 }
 
 func (this *SrsHttpFlvConsumer) Enqueue(msg *rtmp.SrsRtmpMessage, atc bool, jitterAlgorithm *SrsRtmpJitterAlgorithm) {
+	msg = this.jitter.CorrectCopy(msg, consumerJitterAlgorithm(atc, jitterAlgorithm))
 	this.queue.Enqueue(msg)
+	msg.Release()
 }
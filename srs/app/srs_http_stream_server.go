@@ -23,9 +23,11 @@ CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
 package app
 
 import (
+	"errors"
 	"fmt"
+	"go_srs/srs/app/config"
+	"go_srs/srs/global"
 	"net/http"
-	"net/url"
 	"strings"
 )
 
@@ -64,39 +66,59 @@ func (this *SrsHttpStreamServer) CreateTsConsumer(s *SrsSource, w http.ResponseW
 func (this *SrsHttpStreamServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	fmt.Println("url=", r.URL.Path)
 	if strings.HasSuffix(r.URL.Path, ".ts") {
-		s := strings.Replace(r.URL.Path, ".ts", "", -1)
-		m, _ := url.ParseQuery(r.URL.RawQuery)
-		vHostParams, ok := m["vhost"]
-		vhost := "__defaultVhost__"
-		if ok {
-			vhost = vHostParams[0]
-		}
-		source, ok := sourcePool[vhost+s]
-		if !ok {
+		source, err := this.fetchSource(r, ".ts")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
 			return
 		}
 		fmt.Println("Create Ts Consumer)")
 		consumer := this.CreateTsConsumer(source, w, r)
-		err := consumer.ConsumeCycle()
+		defer source.RemoveConsumer(consumer)
+		err = consumer.ConsumeCycle()
 		_ = err
 		return
 	} else if strings.HasSuffix(r.URL.Path, ".flv") {
-		s := strings.Replace(r.URL.Path, ".flv", "", -1)
-		m, _ := url.ParseQuery(r.URL.RawQuery)
-		vHostParams, ok := m["vhost"]
-		vhost := "__defaultVhost__"
-		if ok {
-			vhost = vHostParams[0]
-		}
-
-		source, ok := sourcePool[vhost+s]
-		if !ok {
+		source, err := this.fetchSource(r, ".flv")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
 			return
 		}
 		fmt.Println("Create flv Consumer)")
 		consumer := this.CreateFlvConsumer(source, w, r)
-		err := consumer.ConsumeCycle()
+		defer source.RemoveConsumer(consumer)
+		err = consumer.ConsumeCycle()
 		_ = err
 		return
 	}
 }
+
+/**
+* fetch the source of http stream, for example, the /live/livestream.flv?vhost=xxx,
+* the source is created when not published, the player wait for the publisher,
+* for edge, pull the stream from origin as the rtmp player does.
+ */
+func (this *SrsHttpStreamServer) fetchSource(r *http.Request, ext string) (*SrsSource, error) {
+	req := NewSrsRequest()
+	req.vhost = global.SRS_CONSTS_RTMP_DEFAULT_VHOST
+	if vhost := r.URL.Query().Get("vhost"); vhost != "" {
+		req.vhost = vhost
+	}
+
+	path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/"), ext)
+	i := strings.LastIndex(path, "/")
+	if i <= 0 || i == len(path)-1 {
+		return nil, errors.New("invalid http stream " + r.URL.Path)
+	}
+	req.app, req.stream = path[:i], path[i+1:]
+	source, err := FetchOrCreate(req, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.GetVhostIsEdge(req.vhost) {
+		if err = source.OnEdgeStartPlay(); err != nil {
+			return nil, err
+		}
+	}
+	return source, nil
+}
@@ -33,6 +33,8 @@ type SrsHttpTsConsumer struct {
 	StreamId  int
 	writer    http.ResponseWriter
 	tsEncoder *SrsTsEncoder
+	// correct the timestamp by the jitter algorithm of vhost, monotonic when publisher republish.
+	jitter *SrsRtmpJitter
 }
 
 func NewSrsHttpTsConsumer(s *SrsSource, w http.ResponseWriter, r *http.Request) *SrsHttpTsConsumer {
@@ -42,6 +44,7 @@ func NewSrsHttpTsConsumer(s *SrsSource, w http.ResponseWriter, r *http.Request)
 		queue:     NewSrsMessageQueue(),
 		StreamId:  0,
 		tsEncoder: NewSrsTsEncoder(w),
+		jitter:    NewSrsRtmpJitter(),
 	}
 }
 
@@ -58,7 +61,7 @@ func (this *SrsHttpTsConsumer) ConsumeCycle() error {
 	go func() {
 		notify := this.writer.(http.CloseNotifier).CloseNotify()
 		<-notify
-		this.StopConsume()
+		this.source.RemoveConsumer(this)
 	}()
 	this.writer.Header().Set("Content-Type", "video/MP2T")
 	for {
@@ -74,21 +77,23 @@ func (this *SrsHttpTsConsumer) ConsumeCycle() error {
 				this.tsEncoder.WriteAudio(uint32(msg.GetHeader().GetTimestamp()), msg.GetPayload())
 			} else {
 			}
+			msg.Release()
 		}
 	}
 }
 
 func (this *SrsHttpTsConsumer) StopConsume() error {
-	this.source.RemoveConsumer(this)
 	//send connection close to response writer
 	this.queue.Break()
 	return nil
 }
 
 func (this *SrsHttpTsConsumer) OnRecvError(err error) {
-	this.StopConsume()
+	this.source.OnConsumerError(this)
 }
 
 func (this *SrsHttpTsConsumer) Enqueue(msg *rtmp.SrsRtmpMessage, atc bool, jitterAlgorithm *SrsRtmpJitterAlgorithm) {
+	msg = this.jitter.CorrectCopy(msg, consumerJitterAlgorithm(atc, jitterAlgorithm))
 	this.queue.Enqueue(msg)
+	msg.Release()
 }
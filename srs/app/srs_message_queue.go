@@ -24,50 +24,92 @@ package app
 
 import (
 	"errors"
-	"fmt"
 	log "github.com/sirupsen/logrus"
 	"go_srs/srs/codec/flv"
 	"go_srs/srs/protocol/rtmp"
+	"sync"
+	"time"
 )
 
+/**
+* the max messages in queue, the queue is shrinked when exceed,
+* so the source which enqueues the messages is never blocked by a slow consumer.
+ */
+const SRS_MESSAGE_QUEUE_MAX_MSGS = 10000
+
 type SrsMessageQueue struct {
 	ignoreShrink bool
 	avStartTime  int64
 	avEndTime    int64
 	queueSizeMs  int
 
+	// the msgs is appended by source and taken by consumer.
+	mtx      sync.Mutex
 	msgs     []*rtmp.SrsRtmpMessage
 	msgCount chan int
 	exit     chan bool
+	// the exit is closed once, the consumer may be stopped by both source and client.
+	exitOnce sync.Once
 }
 
 func NewSrsMessageQueue() *SrsMessageQueue {
 	return &SrsMessageQueue{
 		ignoreShrink: true,
-		avStartTime:  0,
-		avEndTime:    0,
+		avStartTime:  -1,
+		avEndTime:    -1,
 		queueSizeMs:  0,
 		msgs:         make([]*rtmp.SrsRtmpMessage, 0),
-		msgCount:     make(chan int, 10000),
+		msgCount:     make(chan int, SRS_MESSAGE_QUEUE_MAX_MSGS),
 		exit:         make(chan bool),
 	}
 }
 
+/**
+* enqueue the message, which is retained by queue,
+* the consumer must release the message when done.
+* the queue is shrinked when the messages exceed the max or the duration exceed the queue size,
+* so it never blocks.
+ */
 func (this *SrsMessageQueue) Enqueue(msg *rtmp.SrsRtmpMessage) {
-	// if msg == nil {
-	// 	fmt.Println("enque nil*******************")
-	// } else {
-	// 	fmt.Println("enqueue no nil*************")
-	// }
+	msg.Retain()
+	this.mtx.Lock()
+	if len(this.msgs) >= SRS_MESSAGE_QUEUE_MAX_MSGS {
+		log.Warnf("queue shrink for %d msgs exceed the max", len(this.msgs))
+		this.shrink()
+	}
+
 	this.msgs = append(this.msgs, msg)
-	this.msgCount <- len(this.msgs)
+	if header := msg.GetHeader(); header.IsAV() {
+		if this.avStartTime < 0 {
+			this.avStartTime = header.GetTimestamp()
+		}
+		this.avEndTime = header.GetTimestamp()
+	}
+
+	if this.queueSizeMs > 0 && this.avEndTime-this.avStartTime > int64(this.queueSizeMs) {
+		log.Warnf("queue shrink for duration %dms exceed %dms", this.avEndTime-this.avStartTime, this.queueSizeMs)
+		this.shrink()
+	}
+	count := len(this.msgs)
+	this.mtx.Unlock()
+
+	// the count is at most the max messages, so there's always a signal for each message,
+	// the signal is dropped when full, for the messages are shrinked.
+	select {
+	case this.msgCount <- count:
+	default:
+	}
 }
 
 func (this *SrsMessageQueue) Size() int {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
 	return len(this.msgs)
 }
 
 func (this *SrsMessageQueue) Duration() int64 {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
 	return this.avEndTime - this.avStartTime
 }
 
@@ -76,27 +118,28 @@ func (this *SrsMessageQueue) SetQueueSize(queueSize float64) {
 }
 
 func (this *SrsMessageQueue) Empty() bool {
-	return len(this.msgs) == 0
+	return this.Size() == 0
+}
+
+// wakeup the Wait without message, which returns nil message.
+func (this *SrsMessageQueue) Wakeup() {
+	select {
+	case this.msgCount <- this.Size():
+	default:
+	}
 }
 
 func (this *SrsMessageQueue) Break() {
-	close(this.exit)
+	this.exitOnce.Do(func() {
+		close(this.exit)
+	})
 }
 
 func (this *SrsMessageQueue) Wait() (*rtmp.SrsRtmpMessage, error) {
 	select {
 	case <-this.msgCount:
 		{
-			if len(this.msgs) <= 0 {
-				return nil, nil
-			}
-
-			msg := this.msgs[0]
-			this.msgs = this.msgs[1:]
-			if msg == nil {
-				fmt.Println("msg is nil")
-			}
-			return msg, nil
+			return this.pop(), nil
 		}
 	case <-this.exit:
 		{
@@ -106,6 +149,56 @@ func (this *SrsMessageQueue) Wait() (*rtmp.SrsRtmpMessage, error) {
 	}
 }
 
+/**
+* wait for the messages to merged write, return when got the max messages,
+* or the latency exceed since got the first message.
+* @remark return empty messages when wakeup without message, to process the control messages.
+ */
+func (this *SrsMessageQueue) WaitBatch(max int, latency time.Duration) ([]*rtmp.SrsRtmpMessage, error) {
+	msg, err := this.Wait()
+	if err != nil || msg == nil {
+		return nil, err
+	}
+
+	msgs := make([]*rtmp.SrsRtmpMessage, 1, max)
+	msgs[0] = msg
+	if len(msgs) >= max {
+		return msgs, nil
+	}
+
+	timer := time.NewTimer(latency)
+	defer timer.Stop()
+	for len(msgs) < max {
+		select {
+		case <-this.msgCount:
+			msg := this.pop()
+			if msg == nil {
+				return msgs, nil
+			}
+			msgs = append(msgs, msg)
+		case <-timer.C:
+			return msgs, nil
+		case <-this.exit:
+			return nil, errors.New("queue break")
+		}
+	}
+	return msgs, nil
+}
+
+// take the first message, nil if empty.
+func (this *SrsMessageQueue) pop() *rtmp.SrsRtmpMessage {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	if len(this.msgs) <= 0 {
+		return nil
+	}
+
+	msg := this.msgs[0]
+	this.msgs[0] = nil
+	this.msgs = this.msgs[1:]
+	return msg
+}
+
 //todo dump packets with jitter algorithm
 
 /**
@@ -113,6 +206,12 @@ func (this *SrsMessageQueue) Wait() (*rtmp.SrsRtmpMessage, error) {
 * if no iframe found, clear it.
  */
 func (this *SrsMessageQueue) Shrink() {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.shrink()
+}
+
+func (this *SrsMessageQueue) shrink() {
 	var videoSH *rtmp.SrsRtmpMessage
 	var audioSH *rtmp.SrsRtmpMessage
 	for i := 0; i < len(this.msgs); i++ {
@@ -125,8 +224,17 @@ func (this *SrsMessageQueue) Shrink() {
 			audioSH = this.msgs[i]
 		}
 	}
+
+	// the message is shared, copy the sequence header to update the timestamp.
+	if videoSH != nil {
+		videoSH = videoSH.Copy()
+	}
+	if audioSH != nil {
+		audioSH = audioSH.Copy()
+	}
+
 	//clear
-	this.msgs = this.msgs[0:0]
+	this.releaseAll()
 
 	this.avStartTime = this.avEndTime
 	if videoSH != nil {
@@ -141,7 +249,18 @@ func (this *SrsMessageQueue) Shrink() {
 }
 
 func (this *SrsMessageQueue) Clear() {
-	this.msgs = this.msgs[0:0]
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.releaseAll()
 	this.avStartTime = -1
 	this.avEndTime = -1
 }
+
+// release and remove all messages.
+func (this *SrsMessageQueue) releaseAll() {
+	for i := 0; i < len(this.msgs); i++ {
+		this.msgs[i].Release()
+		this.msgs[i] = nil
+	}
+	this.msgs = this.msgs[0:0]
+}
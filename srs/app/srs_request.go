@@ -48,6 +48,12 @@ func NewSrsRequest() *SrsRequest {
 	return &SrsRequest{}
 }
 
+// copy the request, for example, the source keeps the request of the client which creates it.
+func (this *SrsRequest) Copy() *SrsRequest {
+	r := *this
+	return &r
+}
+
 func (this SrsRequest) GetStreamUrl() string {
 	return utils.SrsGenerateStreamUrl(this.vhost, this.app, this.stream)
 }
@@ -26,6 +26,7 @@ import (
 	"errors"
 	log "github.com/sirupsen/logrus"
 	"go_srs/srs/app/config"
+	"go_srs/srs/global"
 	"go_srs/srs/protocol/kbps"
 	"go_srs/srs/protocol/packet"
 	"go_srs/srs/protocol/rtmp"
@@ -34,6 +35,7 @@ import (
 	"net"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -46,50 +48,76 @@ type SrsRtmpConn struct {
 	source      *SrsSource
 	kbps        *kbps.SrsKbps
 	clientType  rtmp.SrsRtmpConnType
+	isEdge      bool
 	recvThread  *SrsRecvThread
 	exitMonitor chan bool
+	exitPing    chan bool
 	//to allow extern http api to expire the source
 	expire       chan bool
 	nb_msgs      int64
 	video_frames int64
 	audio_frames int64
+	// the shared objects used by client, key is the name and persistence.
+	soMtx         sync.Mutex
+	sharedObjects map[string]*SrsSharedObject
 }
 
 func NewSrsRtmpConn(c net.Conn, s *SrsServer) *SrsRtmpConn {
 	io := skt.NewSrsIOReadWriter(c)
 	rtmpConn := &SrsRtmpConn{
-		id:          utils.SrsGenerateId(),
-		req:         NewSrsRequest(),
-		res:         NewSrsResponse(1),
-		server:      s,
-		kbps:        kbps.NewSrsKbps(),
-		exitMonitor: make(chan bool),
-		expire:      make(chan bool),
+		id:            utils.SrsGenerateId(),
+		req:           NewSrsRequest(),
+		res:           NewSrsResponse(1),
+		server:        s,
+		kbps:          kbps.NewSrsKbps(),
+		exitMonitor:   make(chan bool),
+		expire:        make(chan bool),
+		sharedObjects: make(map[string]*SrsSharedObject),
 	}
 	rtmpConn.kbps.SetIO(io, io)
 	rtmpConn.rtmp = rtmp.NewSrsRtmpServer(io, rtmpConn)
+	rtmpConn.rtmp.SetSharedObjectHandler(rtmpConn)
 	return rtmpConn
 }
 
 func (this *SrsRtmpConn) ServiceLoop() error {
+	defer this.releaseSharedObjects()
 	return this.doCycle()
 }
 
 func (this *SrsRtmpConn) Handle(msg *rtmp.SrsRtmpMessage) error {
+	// the message is retained by the consumers and caches of source.
+	defer msg.Release()
+
+	if msg.GetHeader().IsAmf0SharedObject() {
+		pkt, err := this.rtmp.DecodeMessage(msg)
+		if err != nil {
+			return err
+		}
+		return this.OnSharedObject(pkt.(*packet.SrsSharedObjectPacket))
+	}
+
 	if msg.GetHeader().IsAmf0Command() || msg.GetHeader().IsAmf3Command() {
 		pkt, err := this.rtmp.DecodeMessage(msg)
 		if err != nil {
 			return err
 		}
-		_ = pkt
+
 		//todo is_fmle process
+		if call, ok := pkt.(*packet.SrsCallPacket); ok {
+			return this.rtmp.OnCall(call, int(msg.GetHeader().GetStreamId()))
+		}
 	}
 	this.nb_msgs++
 	return this.processPublishMessage(msg)
 }
 
 func (this *SrsRtmpConn) processPublishMessage(msg *rtmp.SrsRtmpMessage) error {
-	//todo fix edge process
+	// for edge, directly proxy message to origin.
+	if this.isEdge {
+		return this.source.OnEdgeProxyPublish(msg)
+	}
+
 	if msg.GetHeader().IsAudio() {
 		this.audio_frames++
 		if err := this.source.OnAudio(msg); err != nil {
@@ -103,10 +131,23 @@ func (this *SrsRtmpConn) processPublishMessage(msg *rtmp.SrsRtmpMessage) error {
 
 		}
 	}
-	//todo fix aggregate message
-	//todo fix amf0 or amf3 data
+	// process aggregate message, split to audio/video/data messages.
+	if msg.GetHeader().IsAggregate() {
+		msgs, err := msg.DemuxAggregate()
+		if err != nil {
+			return err
+		}
 
-	// process onMetaData
+		for i := 0; i < len(msgs); i++ {
+			err = this.processPublishMessage(msgs[i])
+			msgs[i].Release()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	// process onMetaData, onTextData and onCuePoint
 	if msg.GetHeader().IsAmf0Data() || msg.GetHeader().IsAmf3Data() {
 		pkt, err := this.rtmp.DecodeMessage(msg)
 		if err != nil {
@@ -121,6 +162,12 @@ func (this *SrsRtmpConn) processPublishMessage(msg *rtmp.SrsRtmpMessage) error {
 					return err
 				}
 			}
+		case *packet.SrsOnDataPacket:
+			{
+				if err := this.source.OnData(msg, pkt.(*packet.SrsOnDataPacket)); err != nil {
+					return err
+				}
+			}
 		}
 	}
 	return nil
@@ -131,10 +178,6 @@ func (this *SrsRtmpConn) Stop() {
 		this.recvThread.Stop()
 	}
 	this.rtmp.Close()
-	if this.req.typ == rtmp.SrsRtmpConnFMLEPublish || this.req.typ == rtmp.SrsRtmpConnFlashPublish || this.req.typ == rtmp.SrsRtmpConnHaivisionPublish {
-		this.source.RemoveConsumers()
-		RemoveSrsSource(this.source)
-	}
 }
 
 /*
@@ -181,8 +224,7 @@ func (this *SrsRtmpConn) doCycle() error {
 		this.req.vhost = vhost[0]
 	}
 
-	this.serviceCycle()
-	return nil
+	return this.serviceCycle()
 }
 
 func (this *SrsRtmpConn) serviceCycle() error {
@@ -239,14 +281,14 @@ func (this *SrsRtmpConn) streamServiceCycle() error {
 	if err != nil {
 		return errors.New("srs_discovery_tc_url failed")
 	}
-	//todo check edge vhost
+	this.isEdge = config.GetVhostIsEdge(this.req.vhost)
 	//todo security check
 
 	if this.req.stream == "" {
 		return errors.New("RTMP: Empty stream name not allowed")
 	}
 
-	this.source, err = FetchOrCreate(this, this.req, this.server)
+	this.source, err = FetchOrCreate(this.req, this.server)
 	if err != nil {
 		return err
 	}
@@ -274,18 +316,14 @@ func (this *SrsRtmpConn) streamServiceCycle() error {
 
 			return nil
 		}
-	case rtmp.SrsRtmpConnFMLEPublish:
+	case rtmp.SrsRtmpConnFMLEPublish, rtmp.SrsRtmpConnHaivisionPublish, rtmp.SrsRtmpConnFlashPublish:
 		{
-			if err := this.rtmp.StartFmlePublish(0); err != nil {
-				return err
-			}
 			return this.publishing(this.source)
 		}
 	default:
 		{
 			return errors.New("invalid client type")
 		}
-		//todo SrsRtmpConnHaivisionPublish,SrsRtmpConnFlashPublish
 	}
 	return nil
 }
@@ -319,8 +357,20 @@ func (this *SrsRtmpConn) httpHooksOnStop() error {
 }
 
 func (this *SrsRtmpConn) playing(source *SrsSource) error {
+	// for edge, pull stream from origin when play.
+	if this.isEdge {
+		if err := source.OnEdgeStartPlay(); err != nil {
+			return err
+		}
+	}
+
 	consumer := source.CreateConsumer(this, true, true, true)
-	return this.doPlaying(source, consumer)
+	defer source.RemoveConsumer(consumer)
+
+	this.startPing()
+	err := this.doPlaying(source, consumer)
+	this.stopPing()
+	return err
 }
 
 func (this *SrsRtmpConn) RemoveSelf() {
@@ -344,26 +394,53 @@ func (this *SrsRtmpConn) doPlaying(source *SrsSource, consumer Consumer) error {
 }
 
 func (this *SrsRtmpConn) publishing(s *SrsSource) error {
+	// reject the publisher when stream is busy, or takeover it.
+	if err := s.acquirePublisher(this); err != nil {
+		_ = this.rtmp.RejectPublish(this.req.typ, this.publishStreamId())
+		return err
+	}
+	defer s.releasePublisher()
+
+	if err := this.startPublish(); err != nil {
+		return err
+	}
+
 	//TODO
 	//refer.check
 	if err := this.httpHooksOnPublish(); err != nil {
 		return err
 	}
-	//judge edge host
-	if err := this.acquirePublish(s, false); err != nil {
+	if err := this.acquirePublish(s, this.isEdge); err != nil {
 		return err
 	}
 
 	err := this.doPublishing(s)
 
-	this.source.UnPublish()
-	//todo release publish
+	this.releasePublish(s, this.isEdge)
 	if err := this.httpHooksOnUnpublish(); err != nil {
 		return err
 	}
 	return err
 }
 
+// response the publish of client, the stream id of fmle publish is 0.
+func (this *SrsRtmpConn) startPublish() error {
+	switch this.req.typ {
+	case rtmp.SrsRtmpConnFMLEPublish:
+		return this.rtmp.StartFmlePublish(this.publishStreamId())
+	case rtmp.SrsRtmpConnHaivisionPublish:
+		return this.rtmp.StartHaivisionPublish(this.publishStreamId())
+	}
+	return this.rtmp.StartFlashPublish(this.publishStreamId())
+}
+
+func (this *SrsRtmpConn) publishStreamId() int {
+	if this.req.typ == rtmp.SrsRtmpConnFMLEPublish {
+		return 0
+	}
+	return this.res.StreamId
+}
+
 func (this *SrsRtmpConn) httpHooksOnPublish() error {
 	vhost := config.GetInstance().GetVHost(this.req.vhost)
 	if vhost == nil {
@@ -393,7 +470,10 @@ func (this *SrsRtmpConn) httpHooksOnUnpublish() error {
 }
 
 func (this *SrsRtmpConn) acquirePublish(source *SrsSource, isEdge bool) error {
-	//TODO edge process
+	// for edge, proxy the publish to origin.
+	if isEdge {
+		return source.OnEdgeStartPublish()
+	}
 
 	err := this.source.onPublish()
 	if err != nil {
@@ -402,12 +482,23 @@ func (this *SrsRtmpConn) acquirePublish(source *SrsSource, isEdge bool) error {
 	return nil
 }
 
+func (this *SrsRtmpConn) releasePublish(source *SrsSource, isEdge bool) {
+	if isEdge {
+		source.OnEdgeProxyUnpublish()
+		return
+	}
+
+	source.UnPublish()
+}
+
 func (this *SrsRtmpConn) doPublishing(source *SrsSource) error {
 	this.recvThread = NewSrsRecvThread(this.rtmp, this, 1000)
 	this.recvThread.Start()
 	//这里需要定时检查收到的信息，实现SrsRtmpConn::do_publishing的功能
 	this.startMonitor()
+	this.startPing()
 	this.recvThread.Join()
+	this.stopPing()
 	this.stopMonitor()
 	return nil
 }
@@ -447,6 +538,7 @@ func (this *SrsRtmpConn) startMonitor() error {
 			stat := GetStatisticInstance()
 			stat.OnVideoFrames(this.req, uint64(this.video_frames-last_video_frames))
 			last_video_frames = this.video_frames
+			stat.OnMergedWrite(this.req, this.source.GetMergedWriteStats())
 			//todo first need use kbps to get info
 		}
 		log.Info("monitor thread exit")
@@ -459,6 +551,69 @@ func (this *SrsRtmpConn) stopMonitor() error {
 	return nil
 }
 
+/**
+* send PingRequest to peer at the interval and record the rtt,
+* close the connection when peer not response in the ping timeout.
+ */
+func (this *SrsRtmpConn) startPing() {
+	this.exitPing = make(chan bool)
+	go func(exit chan bool) {
+		interval := time.Millisecond * time.Duration(config.GetInstance().GetPingInterval())
+		timeout := time.Millisecond * time.Duration(config.GetInstance().GetPingTimeout())
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		// the peer is alive when got bytes from it, for example, the publisher which sends media,
+		// and the ping response is used only when the peer is silent.
+		lastActive := time.Now()
+		recvBytes := this.rtmp.GetRecvBytes()
+		for {
+			select {
+			case <-exit:
+				return
+			case <-ticker.C:
+			}
+
+			if bytes := this.rtmp.GetRecvBytes(); bytes != recvBytes {
+				recvBytes = bytes
+				lastActive = time.Now()
+			}
+
+			// the peer is dead when no bytes and no response since last active or response.
+			last := this.rtmp.GetLastPingResponse()
+			if last.Before(lastActive) {
+				last = lastActive
+			}
+			if time.Since(last) > timeout {
+				log.Error("peer silent and not response ping in ", timeout, ", close connection, id=", this.id)
+				this.Close()
+				return
+			}
+
+			if rtt := this.rtmp.GetRtt(); rtt > 0 {
+				GetStatisticInstance().OnClientRtt(this.id, rtt)
+			}
+
+			if err := this.rtmp.SendPingRequest(); err != nil {
+				log.Error("send ping request failed, err=", err)
+				return
+			}
+		}
+	}(this.exitPing)
+}
+
+func (this *SrsRtmpConn) stopPing() {
+	if this.exitPing != nil {
+		close(this.exitPing)
+		this.exitPing = nil
+	}
+}
+
+// the rtt measured by ping, 0 if peer never response the ping.
+func (this *SrsRtmpConn) GetRtt() time.Duration {
+	return this.rtmp.GetRtt()
+}
+
 func (this *SrsRtmpConn) resample() {
 	this.kbps.Resample()
 }
@@ -466,3 +621,55 @@ func (this *SrsRtmpConn) resample() {
 func (this *SrsRtmpConn) Playing(source *SrsSource) {
 	//todo
 }
+
+/**
+* process the shared object message of client, the client must use the shared object
+* before change it, and the shared objects are released when client closed.
+ */
+func (this *SrsRtmpConn) OnSharedObject(pkt *packet.SrsSharedObjectPacket) error {
+	key := sharedObjectKey(this.req.vhost, this.req.app, pkt.Name, pkt.Persistent)
+	for _, event := range pkt.Events {
+		switch event.Type {
+		case global.RTMP_SO_Use:
+			this.soMtx.Lock()
+			if _, ok := this.sharedObjects[key]; !ok {
+				this.sharedObjects[key] = UseSharedObject(this, this.req.vhost, this.req.app, pkt.Name, pkt.Persistent)
+			}
+			this.soMtx.Unlock()
+		case global.RTMP_SO_Release:
+			this.soMtx.Lock()
+			if so, ok := this.sharedObjects[key]; ok {
+				delete(this.sharedObjects, key)
+				ReleaseSharedObject(this, so)
+			}
+			this.soMtx.Unlock()
+		default:
+			this.soMtx.Lock()
+			so := this.sharedObjects[key]
+			this.soMtx.Unlock()
+
+			if so == nil {
+				log.Warn("ignore event ", event.Type, " of shared object ", pkt.Name, " not used")
+				continue
+			}
+			so.OnEvent(this, event)
+		}
+	}
+	return nil
+}
+
+func (this *SrsRtmpConn) UsingSharedObjects() bool {
+	this.soMtx.Lock()
+	defer this.soMtx.Unlock()
+	return len(this.sharedObjects) > 0
+}
+
+func (this *SrsRtmpConn) releaseSharedObjects() {
+	this.soMtx.Lock()
+	defer this.soMtx.Unlock()
+
+	for key, so := range this.sharedObjects {
+		ReleaseSharedObject(this, so)
+		delete(this.sharedObjects, key)
+	}
+}
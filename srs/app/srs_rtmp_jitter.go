@@ -29,6 +29,34 @@ func NewSrsRtmpJitter() *SrsRtmpJitter {
 	}
 }
 
+/**
+* parse the time jitter algorithm of config, full, zero or off,
+* full is used if not specified.
+ */
+func parseJitterAlgorithm(jitter string) SrsRtmpJitterAlgorithm {
+	switch jitter {
+	case "zero":
+		return SrsRtmpJitterAlgorithmZERO
+	case "off":
+		return SrsRtmpJitterAlgorithmOFF
+	}
+	return SrsRtmpJitterAlgorithmFULL
+}
+
+/**
+* the jitter algorithm of consumer, the time is not corrected for atc,
+* full is used if not specified.
+ */
+func consumerJitterAlgorithm(atc bool, ag *SrsRtmpJitterAlgorithm) SrsRtmpJitterAlgorithm {
+	if atc {
+		return SrsRtmpJitterAlgorithmOFF
+	}
+	if ag == nil {
+		return SrsRtmpJitterAlgorithmFULL
+	}
+	return *ag
+}
+
 func (this *SrsRtmpJitter) Correct(msg *rtmp.SrsRtmpMessage, ag SrsRtmpJitterAlgorithm) error {
 	if ag != SrsRtmpJitterAlgorithmFULL {
 		if ag == SrsRtmpJitterAlgorithmOFF {
@@ -45,36 +73,48 @@ func (this *SrsRtmpJitter) Correct(msg *rtmp.SrsRtmpMessage, ag SrsRtmpJitterAlg
 		return nil
 	}
 
-	if msg.GetHeader().IsAV() {
-		msg.GetHeader().SetTimestamp(0)
-		return nil
-	}
-
 	/**
 	* we use a very simple time jitter detect/correct algorithm:
 	* 1. delta: ensure the delta is positive and valid,
 	*     we set the delta to DEFAULT_FRAME_TIME_MS,
-	*     if the delta of time is nagative or greater than CONST_MAX_JITTER_MS.
+	*     if the delta of time is nagative or greater than CONST_MAX_JITTER_MS,
+	*     for the little nagative delta, for instance, the audio and video interleaved,
+	*     the delta is zero and the last_pkt_time is kept, so the time never drift.
 	* 2. last_pkt_time: specifies the original packet time,
 	*     is used to detect next jitter.
-	* 3. last_pkt_correct_time: simply add the positive delta,
+	* 3. last_pkt_correct_time: starts at zero, simply add the positive delta,
 	*     and enforce the time monotonically.
 	 */
 
 	timestamp := msg.GetHeader().GetTimestamp()
-	delta := timestamp - this.lastPktTime
-	if delta < CONST_MAX_JITTER_MS_NEG || delta > CONST_MAX_JITTER_MS {
-		delta = DEFAULT_FRAME_TIME_MS
-	}
-
-	if this.lastPktCorrectTime+delta > 0 {
+	if this.lastPktCorrectTime == -1 {
+		this.lastPktCorrectTime = 0
+	} else {
+		delta := timestamp - this.lastPktTime
+		if delta < CONST_MAX_JITTER_MS_NEG || delta > CONST_MAX_JITTER_MS {
+			delta = DEFAULT_FRAME_TIME_MS
+		} else if delta < 0 {
+			delta = 0
+			timestamp = this.lastPktTime
+		}
 		this.lastPktCorrectTime = this.lastPktCorrectTime + delta
 	}
+
 	msg.GetHeader().SetTimestamp(this.lastPktCorrectTime)
 	this.lastPktTime = timestamp
 	return nil
 }
 
+/**
+* the msg is shared by all consumers, so correct the copy of msg for consumer,
+* user must release the returned msg when done.
+ */
+func (this *SrsRtmpJitter) CorrectCopy(msg *rtmp.SrsRtmpMessage, ag SrsRtmpJitterAlgorithm) *rtmp.SrsRtmpMessage {
+	msg = msg.Copy()
+	_ = this.Correct(msg, ag)
+	return msg
+}
+
 func (this *SrsRtmpJitter) GetTime() int64 {
 	return this.lastPktCorrectTime
 }
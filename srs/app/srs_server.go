@@ -26,11 +26,9 @@ import (
 	"fmt"
 	log "github.com/sirupsen/logrus"
 	"go_srs/srs/app/config"
-	"go_srs/srs/utils"
 	_ "log"
 	"net"
 	"net/http"
-	"runtime"
 	"strconv"
 	"sync"
 	"time"
@@ -40,6 +38,7 @@ type SrsServer struct {
 	conns     []*SrsRtmpConn
 	flvServer *SrsHttpStreamServer
 	connsMtx  sync.Mutex
+	rtmpsCert *SrsRtmpsCertificate
 }
 
 func NewSrsServer() *SrsServer {
@@ -62,6 +61,7 @@ func (this *SrsServer) RemoveConn(c *SrsRtmpConn) {
 			break
 		}
 	}
+	GetStatisticInstance().OnDisconnect(c.id)
 }
 
 func (this *SrsServer) AddConn(c *SrsRtmpConn) error {
@@ -95,20 +95,19 @@ func (this *SrsServer) StartProcess(port uint32) error {
 		return err
 	}
 
+	if config.GetInstance().GetRtmpsEnabled() {
+		if err := this.listenRtmps(); err != nil {
+			return err
+		}
+	}
+
 	go func() {
+		NewSrsRtmptServer(this).Mount(http.DefaultServeMux)
 		http.Handle("/", this.flvServer)
 		http.Handle("/hls/", http.StripPrefix("/hls/", http.FileServer(http.Dir("./html"))))
 		http.ListenAndServe(":8080", nil)
 	}()
 
-	go func() {
-		for {
-			time.Sleep(time.Second * 2)
-			runtime.GC()
-			utils.TraceMemStats()
-		}
-	}()
-
 	log.Info("starting server succeed")
 	// start network rtmp server resolution times
 	go func() {
@@ -133,7 +132,11 @@ func (this *SrsServer) HandleConnection(conn net.Conn) {
 		conn.Close()
 		return
 	}
-	err = rtmpConn.ServiceLoop()
+	if err = rtmpConn.ServiceLoop(); err != nil {
+		log.Info("rtmp connection done, err=", err)
+	}
+	// close the connection when service done or failed, for example, the client not response in time.
+	rtmpConn.Close()
 	this.RemoveConn(rtmpConn)
 }
 
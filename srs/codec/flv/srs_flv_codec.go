@@ -28,6 +28,7 @@ import (
 	"go_srs/srs/protocol/rtmp"
 	"go_srs/srs/utils"
 	"io"
+	"net"
 )
 
 func VideoIsKeyFrame(data []byte) bool {
@@ -107,7 +108,7 @@ func VideoIsAcceptable(data []byte) bool {
 const (
 	AudioTagType    = 0x08
 	VideoTagType    = 0x09
-	MetaDataTagType = 0x18
+	MetaDataTagType = 0x12
 )
 
 const (
@@ -183,6 +184,9 @@ type SrsFlvEncoder struct {
 	header   *SrsFlvHeader
 	writer   io.Writer
 	tagCount int32
+	// the reusable tag headers and previous-tag-sizes of merged write.
+	tagHeaders []byte
+	iovs       [][]byte
 }
 
 func NewSrsFlvEncoder(w io.Writer) *SrsFlvEncoder {
@@ -211,6 +215,12 @@ func (this *SrsFlvEncoder) WriteMetaData(data []byte) (uint32, error) {
 	return this.writeTag(header, data)
 }
 
+// write the timed script data tag, for example, the onTextData and onCuePoint.
+func (this *SrsFlvEncoder) WriteData(timestamp uint32, data []byte) (uint32, error) {
+	header := NewTagHeader(MetaDataTagType, timestamp, int32(len(data)))
+	return this.writeTag(header, data)
+}
+
 func (this *SrsFlvEncoder) WriteAudio(timestamp uint32, data []byte) (uint32, error) {
 	header := NewTagHeader(AudioTagType, timestamp, int32(len(data)))
 	return this.writeTag(header, data)
@@ -234,15 +244,35 @@ func (this *SrsFlvEncoder) writeTag(header *TagHeader, data []byte) (uint32, err
 	return uint32(len(d)), err
 }
 
+/**
+* write the tags in one writev, the tag headers and previous-tag-sizes are built into the
+* reusable buffer, and the iovecs reference the payloads without copy.
+ */
 func (this *SrsFlvEncoder) WriteTags(msgs []*rtmp.SrsRtmpMessage) error {
+	// each tag uses 11bytes header and 4bytes previous-tag-size.
+	this.tagHeaders = this.tagHeaders[:0]
 	for i := 0; i < len(msgs); i++ {
+		// the data tag keeps its timestamp, for example, the onCuePoint in stream.
+		var typ byte = MetaDataTagType
+		timestamp := uint32(msgs[i].GetHeader().GetTimestamp())
 		if msgs[i].GetHeader().IsAudio() {
-			_, _ = this.WriteAudio(uint32(msgs[i].GetHeader().GetTimestamp()), msgs[i].GetPayload())
+			typ = AudioTagType
 		} else if msgs[i].GetHeader().IsVideo() {
-			_, _ = this.WriteVideo(uint32(msgs[i].GetHeader().GetTimestamp()), msgs[i].GetPayload())
-		} else {
-			_, _ = this.WriteMetaData(msgs[i].GetPayload())
+			typ = VideoTagType
 		}
+		size := int32(len(msgs[i].GetPayload()))
+		this.tagHeaders = append(this.tagHeaders, NewTagHeader(typ, timestamp, size).Data()...)
+		this.tagHeaders = append(this.tagHeaders, utils.Int32ToBytes(size+11, binary.BigEndian)...)
+		this.tagCount++
 	}
-	return nil
+
+	this.iovs = this.iovs[:0]
+	for i := 0; i < len(msgs); i++ {
+		header := this.tagHeaders[i*15 : i*15+15]
+		this.iovs = append(this.iovs, header[:11], msgs[i].GetPayload(), header[11:])
+	}
+
+	bufs := net.Buffers(this.iovs)
+	_, err := bufs.WriteTo(this.writer)
+	return err
 }
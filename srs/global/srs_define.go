@@ -24,6 +24,11 @@ package global
 
 const SRS_CONSTS_RTMP_PROTOCOL_CHUNK_SIZE = 128
 
+// the chunk size set by peer must be in [min, max], for the chunk size of flash is 128,
+// and the max chunk size is 65536 in the rtmp specification.
+const SRS_CONSTS_RTMP_MIN_CHUNK_SIZE = 128
+const SRS_CONSTS_RTMP_MAX_CHUNK_SIZE = 65536
+
 const (
 	RTMP_MSG_SetChunkSize               = 0x01
 	RTMP_MSG_AbortMessage               = 0x02
@@ -160,6 +165,7 @@ const SRS_CONSTS_RTMP_DEFAULT_PORT = "1935"
 
 const RTMP_SIG_FMS_VER = "3,5,3,888"
 const RTMP_SIG_AMF0_VER = 0
+const RTMP_SIG_AMF3_VER = 3
 const RTMP_SIG_CLIENT_ID = "ASAICiss"
 
 // FMLE
@@ -182,11 +188,14 @@ const (
 	// code value
 	StatusCodeConnectSuccess   = "NetConnection.Connect.Success"
 	StatusCodeConnectRejected  = "NetConnection.Connect.Rejected"
+	StatusCodeCallFailed       = "NetConnection.Call.Failed"
 	StatusCodeStreamReset      = "NetStream.Play.Reset"
 	StatusCodeStreamStart      = "NetStream.Play.Start"
 	StatusCodeStreamPause      = "NetStream.Pause.Notify"
 	StatusCodeStreamUnpause    = "NetStream.Unpause.Notify"
+	StatusCodeStreamSeek       = "NetStream.Seek.Notify"
 	StatusCodePublishStart     = "NetStream.Publish.Start"
+	StatusCodePublishBadName   = "NetStream.Publish.BadName"
 	StatusCodeDataStart        = "NetStream.Data.Start"
 	StatusCodeUnpublishSuccess = "NetStream.Unpublish.Success"
 )
@@ -298,3 +307,35 @@ const (
 	 */
 	SrsPCUCFmsEvent0 = 0x1a
 )
+
+// 3.3. Shared object message, the event types.
+const (
+	// the client sends this event to inform the server about the creation of a named shared object.
+	RTMP_SO_Use = 0x01
+	// the client sends this event to the server when the shared object is deleted on the client side.
+	RTMP_SO_Release = 0x02
+	// the client sends this event to request that the change the value associated with a named parameter.
+	RTMP_SO_RequestChange = 0x03
+	// the server sends this event to notify all clients, except the client originating the request,
+	// of a change in the value of a named parameter.
+	RTMP_SO_Change = 0x04
+	// the server sends this event to the requesting client, if the request to change is accepted.
+	RTMP_SO_Success = 0x05
+	// the client sends this event to the server to broadcast a message,
+	// on receiving this event, the server broadcasts a message to all the clients, including the sender.
+	RTMP_SO_SendMessage = 0x06
+	// the server sends this event to notify clients about error conditions.
+	RTMP_SO_Status = 0x07
+	// the server sends this event to the client to clear a shared object,
+	// also sent to the client in response to Use event on successful connection.
+	RTMP_SO_Clear = 0x08
+	// the server sends this event to have the client delete a slot.
+	RTMP_SO_Remove = 0x09
+	// the client sends this event to have the server delete a slot.
+	RTMP_SO_RequestRemove = 0x0A
+	// the server sends this event to the client on a successful connection.
+	RTMP_SO_UseSuccess = 0x0B
+)
+
+// the flags of shared object message, the persistent shared object is stored by server.
+const RTMP_SO_FLAG_PERSISTENT = 0x02
@@ -49,6 +49,20 @@ func GenerateSrsAmf0Any(marker byte) ISrsAmf0Any {
 		return &SrsAmf0Undefined{}
 	case RTMP_AMF0_EcmaArray:
 		return &SrsAmf0EcmaArray{}
+	case RTMP_AMF0_StrictArray:
+		return &SrsAmf0StrictArray{}
+	case RTMP_AMF0_Date:
+		return &SrsAmf0Date{}
+	case RTMP_AMF0_LongString:
+		return &SrsAmf0LongString{}
+	case RTMP_AMF0_Reference:
+		return &SrsAmf0Reference{}
+	case RTMP_AMF0_TypedObject:
+		return &SrsAmf0TypedObject{}
+	case RTMP_AMF0_XmlDocument:
+		return &SrsAmf0XmlDocument{}
+	case RTMP_AMF0_UnSupported:
+		return &SrsAmf0Unsupported{}
 	default:
 		return nil
 	}
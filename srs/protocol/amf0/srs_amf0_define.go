@@ -57,6 +57,7 @@ const (
 	RTMP_AMF0_COMMAND_CLOSE_STREAM   = "closeStream"
 	RTMP_AMF0_COMMAND_PLAY           = "play"
 	RTMP_AMF0_COMMAND_PAUSE          = "pause"
+	RTMP_AMF0_COMMAND_SEEK           = "seek"
 	RTMP_AMF0_COMMAND_ON_BW_DONE     = "onBWDone"
 	RTMP_AMF0_COMMAND_ON_STATUS      = "onStatus"
 	RTMP_AMF0_COMMAND_RESULT         = "_result"
@@ -68,6 +69,14 @@ const (
 	RTMP_AMF0_DATA_SAMPLE_ACCESS     = "|RtmpSampleAccess"
 )
 
+/**
+ * the commands of generic call, response by the call handler.
+ */
+const (
+	RTMP_AMF0_COMMAND_GET_STREAM_LENGTH = "getStreamLength"
+	RTMP_AMF0_COMMAND_CHECK_BW          = "_checkbw"
+)
+
 type SrsValuePair struct {
 	Name  SrsAmf0Utf8
 	Value ISrsAmf0Any
@@ -75,3 +84,5 @@ type SrsValuePair struct {
 
 const SRS_CONSTS_RTMP_SET_DATAFRAME = "@setDataFrame"
 const SRS_CONSTS_RTMP_ON_METADATA = "onMetaData"
+const SRS_CONSTS_RTMP_ON_TEXTDATA = "onTextData"
+const SRS_CONSTS_RTMP_ON_CUEPOINT = "onCuePoint"
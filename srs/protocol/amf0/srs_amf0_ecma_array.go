@@ -103,39 +103,11 @@ func (this *SrsAmf0EcmaArray) Decode(stream *utils.SrsStream) error {
 			return err
 		}
 
-		var v ISrsAmf0Any
-		switch marker {
-		case RTMP_AMF0_Number:
-			{
-				v = &SrsAmf0Number{}
-				err = v.Decode(stream)
-			}
-		case RTMP_AMF0_Boolean:
-			{
-				v = &SrsAmf0Boolean{}
-				err = v.Decode(stream)
-			}
-		case RTMP_AMF0_String:
-			{
-				v = &SrsAmf0String{}
-				err = v.Decode(stream)
-			}
-		case RTMP_AMF0_Object:
-			{
-				v = &SrsAmf0Object{}
-				err = v.Decode(stream)
-			}
-		case RTMP_AMF0_Null:
-			{
-				v = &SrsAmf0Null{}
-				err = v.Decode(stream)
-			}
-		case RTMP_AMF0_Undefined:
-			{
-				v = &SrsAmf0Undefined{}
-				err = v.Decode(stream)
-			}
+		v := GenerateSrsAmf0Any(marker)
+		if v == nil {
+			return errors.New("amf0 unknown property marker.")
 		}
+		err = v.Decode(stream)
 
 		if err != nil {
 			return err
@@ -200,6 +172,13 @@ func (this *SrsAmf0EcmaArray) Set(name string, value interface{}) {
 			Name:  SrsAmf0Utf8{Value: name},
 			Value: &SrsAmf0Number{Value: value.(float64)},
 		}
+	case ISrsAmf0Any:
+		p = &SrsValuePair{
+			Name:  SrsAmf0Utf8{Value: name},
+			Value: value.(ISrsAmf0Any),
+		}
+	default:
+		return
 	}
 	this.Properties = append(this.Properties, *p)
 }
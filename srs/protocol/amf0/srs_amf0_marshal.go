@@ -0,0 +1,538 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2019 GOSRS(gosrs)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package amf0
+
+import (
+	"errors"
+	"fmt"
+	"go_srs/srs/utils"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/**
+* the amf0 marshal and unmarshal of go values, like encoding/json:
+*     bool                        <=> boolean
+*     int, uint, float            <=> number
+*     string                      <=> string, long string if longer than 65535 bytes
+*     time.Time                   <=> date
+*     struct                      <=> object, the properties are the exported fields
+*     map[string]T                <=> ecma array, the keys are sorted when marshal
+*     slice, array                <=> strict array
+*     nil pointer, slice, map     <=> null, so is the nil interface
+*     ISrsAmf0Any                 <=> itself, to keep the raw amf0 value
+* the object and typed object can also unmarshal to map, the ecma array to struct.
+* the unmarshal of interface{} is float64, bool, string, time.Time, nil,
+* map[string]interface{} or []interface{}.
+*
+* the struct field is mapped by the tag like `amf0:"name,omitempty"`,
+*     name, the property name, the field name if empty.
+*     omitempty, ignore the field when marshal if it's zero value.
+* the field with tag "-" is ignored, and the fields of anonymous struct are
+* promoted to the outer object.
+ */
+
+// the property of object mapped to the field of struct.
+type amf0Field struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+var amf0AnyType = reflect.TypeOf((*ISrsAmf0Any)(nil)).Elem()
+var amf0Utf8Type = reflect.TypeOf(SrsAmf0Utf8{})
+var timeType = reflect.TypeOf(time.Time{})
+
+// the fields of struct type, key is reflect.Type, value is []amf0Field.
+var amf0FieldsCache sync.Map
+
+/**
+* encode the v to amf0 bytes, for example, an object for struct.
+ */
+func Marshal(v interface{}) ([]byte, error) {
+	any, err := MarshalAny(v)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := utils.NewSrsStream([]byte{})
+	if err = any.Encode(stream); err != nil {
+		return nil, err
+	}
+	return stream.Data(), nil
+}
+
+/**
+* decode the amf0 value in data to v, which must be a non-nil pointer.
+* @remark the data must be exactly one amf0 value.
+ */
+func Unmarshal(data []byte, v interface{}) error {
+	stream := utils.NewSrsStream(data)
+	marker, err := stream.PeekByte()
+	if err != nil {
+		return err
+	}
+
+	any := GenerateSrsAmf0Any(marker)
+	if any == nil {
+		return fmt.Errorf("amf0 unmarshal unknown marker %#x", marker)
+	}
+
+	if err = any.Decode(stream); err != nil {
+		return err
+	}
+
+	if !stream.Empty() {
+		return errors.New("amf0 unmarshal got extra data after value")
+	}
+	return UnmarshalAny(any, v)
+}
+
+/**
+* convert the v to amf0 value, which can be set to the packet,
+* for example, the CommandObj of connect packet.
+ */
+func MarshalAny(v interface{}) (ISrsAmf0Any, error) {
+	return marshalValue(reflect.ValueOf(v))
+}
+
+/**
+* convert the decoded amf0 value to v, which must be a non-nil pointer,
+* for example, the CommandObj of connect packet to a typed struct.
+ */
+func UnmarshalAny(any ISrsAmf0Any, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("amf0 unmarshal need non-nil pointer")
+	}
+
+	if any == nil {
+		return errors.New("amf0 unmarshal nil value")
+	}
+	return unmarshalValue(any, rv.Elem())
+}
+
+func marshalValue(v reflect.Value) (ISrsAmf0Any, error) {
+	if !v.IsValid() {
+		return NewSrsAmf0Null(), nil
+	}
+
+	t := v.Type()
+	// the utf8 is the string without marker, it's not a value.
+	if t == amf0Utf8Type {
+		return marshalString(v.Field(0).String()), nil
+	}
+
+	if t.Implements(amf0AnyType) {
+		if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+			return NewSrsAmf0Null(), nil
+		}
+		return v.Interface().(ISrsAmf0Any), nil
+	}
+
+	// for example, the amf0.SrsAmf0Number of packet.
+	if t.Kind() != reflect.Ptr && reflect.PtrTo(t).Implements(amf0AnyType) {
+		p := reflect.New(t)
+		p.Elem().Set(v)
+		return p.Interface().(ISrsAmf0Any), nil
+	}
+
+	if t == timeType {
+		ms := v.Interface().(time.Time).UnixNano() / int64(time.Millisecond)
+		return NewSrsAmf0Date(float64(ms)), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return NewSrsAmf0Null(), nil
+		}
+		return marshalValue(v.Elem())
+	case reflect.Bool:
+		return NewSrsAmf0Boolean(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return NewSrsAmf0Number(float64(v.Int())), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return NewSrsAmf0Number(float64(v.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return NewSrsAmf0Number(v.Float()), nil
+	case reflect.String:
+		return marshalString(v.String()), nil
+	case reflect.Struct:
+		return marshalStruct(v)
+	case reflect.Map:
+		return marshalMap(v)
+	case reflect.Slice:
+		if v.IsNil() {
+			return NewSrsAmf0Null(), nil
+		}
+		return marshalArray(v)
+	case reflect.Array:
+		return marshalArray(v)
+	}
+	return nil, fmt.Errorf("amf0 marshal unsupported type %v", t)
+}
+
+func marshalString(str string) ISrsAmf0Any {
+	if len(str) > math.MaxUint16 {
+		return NewSrsAmf0LongString(str)
+	}
+	return NewSrsAmf0String(str)
+}
+
+func marshalStruct(v reflect.Value) (ISrsAmf0Any, error) {
+	obj := NewSrsAmf0Object()
+	fields := cachedFields(v.Type())
+	for i := 0; i < len(fields); i++ {
+		fv := v.FieldByIndex(fields[i].index)
+		if fields[i].omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+
+		value, err := marshalValue(fv)
+		if err != nil {
+			return nil, err
+		}
+		obj.Properties = append(obj.Properties, SrsValuePair{Name: SrsAmf0Utf8{Value: fields[i].name}, Value: value})
+	}
+	return obj, nil
+}
+
+func marshalMap(v reflect.Value) (ISrsAmf0Any, error) {
+	if v.Type().Key().Kind() != reflect.String {
+		return nil, fmt.Errorf("amf0 marshal unsupported map key type %v", v.Type().Key())
+	}
+
+	if v.IsNil() {
+		return NewSrsAmf0Null(), nil
+	}
+
+	keys := make([]string, 0, v.Len())
+	for _, k := range v.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+
+	arr := NewSrsAmf0EcmaArray()
+	for i := 0; i < len(keys); i++ {
+		value, err := marshalValue(v.MapIndex(reflect.ValueOf(keys[i]).Convert(v.Type().Key())))
+		if err != nil {
+			return nil, err
+		}
+		arr.Properties = append(arr.Properties, SrsValuePair{Name: SrsAmf0Utf8{Value: keys[i]}, Value: value})
+	}
+	return arr, nil
+}
+
+func marshalArray(v reflect.Value) (ISrsAmf0Any, error) {
+	arr := NewSrsAmf0StrictArray()
+	for i := 0; i < v.Len(); i++ {
+		value, err := marshalValue(v.Index(i))
+		if err != nil {
+			return nil, err
+		}
+		arr.Append(value)
+	}
+	return arr, nil
+}
+
+func unmarshalValue(any ISrsAmf0Any, v reflect.Value) error {
+	// keep the raw amf0 value, for example, the ISrsAmf0Any or *SrsAmf0Object,
+	// while the interface{} is unmarshaled to go value.
+	if t := reflect.TypeOf(any); v.Kind() != reflect.Interface || v.NumMethod() > 0 {
+		if t.AssignableTo(v.Type()) {
+			v.Set(reflect.ValueOf(any))
+			return nil
+		}
+		// for example, the amf0.SrsAmf0Number of packet.
+		if t.Elem() == v.Type() {
+			v.Set(reflect.ValueOf(any).Elem())
+			return nil
+		}
+	}
+
+	switch any.(type) {
+	case *SrsAmf0Null, *SrsAmf0Undefined:
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return unmarshalValue(any, v.Elem())
+	}
+
+	if v.Kind() == reflect.Interface && v.NumMethod() == 0 {
+		value, err := anyToInterface(any)
+		if err != nil {
+			return err
+		}
+		if value != nil {
+			v.Set(reflect.ValueOf(value))
+		} else {
+			v.Set(reflect.Zero(v.Type()))
+		}
+		return nil
+	}
+
+	switch a := any.(type) {
+	case *SrsAmf0Number:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if n := int64(a.Value); float64(n) == a.Value && !v.OverflowInt(n) {
+				v.SetInt(n)
+				return nil
+			}
+			return fmt.Errorf("amf0 unmarshal number %v overflow %v", a.Value, v.Type())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if n := uint64(a.Value); a.Value >= 0 && float64(n) == a.Value && !v.OverflowUint(n) {
+				v.SetUint(n)
+				return nil
+			}
+			return fmt.Errorf("amf0 unmarshal number %v overflow %v", a.Value, v.Type())
+		case reflect.Float32, reflect.Float64:
+			v.SetFloat(a.Value)
+			return nil
+		}
+	case *SrsAmf0Boolean:
+		if v.Kind() == reflect.Bool {
+			v.SetBool(a.Value)
+			return nil
+		}
+	case *SrsAmf0String:
+		if v.Kind() == reflect.String {
+			v.SetString(a.Value.Value)
+			return nil
+		}
+	case *SrsAmf0LongString:
+		if v.Kind() == reflect.String {
+			v.SetString(a.Value)
+			return nil
+		}
+	case *SrsAmf0XmlDocument:
+		if v.Kind() == reflect.String {
+			v.SetString(a.Value)
+			return nil
+		}
+	case *SrsAmf0Date:
+		if v.Type() == timeType {
+			v.Set(reflect.ValueOf(time.Unix(0, int64(a.Value)*int64(time.Millisecond))))
+			return nil
+		}
+		if v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64 {
+			v.SetFloat(a.Value)
+			return nil
+		}
+	case *SrsAmf0Object:
+		return unmarshalProperties(a.Properties, v)
+	case *SrsAmf0EcmaArray:
+		return unmarshalProperties(a.Properties, v)
+	case *SrsAmf0TypedObject:
+		return unmarshalProperties(a.Properties, v)
+	case *SrsAmf0StrictArray:
+		return unmarshalElems(a.Elems, v)
+	}
+	return fmt.Errorf("amf0 unmarshal %T into %v", any, v.Type())
+}
+
+func unmarshalProperties(props []SrsValuePair, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Struct:
+		fields := cachedFields(v.Type())
+		for i := 0; i < len(props); i++ {
+			// ignore the property not in struct.
+			if f := findField(fields, props[i].Name.Value); f != nil {
+				if err := unmarshalValue(props[i].Value, v.FieldByIndex(f.index)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	case reflect.Map:
+		t := v.Type()
+		if t.Key().Kind() != reflect.String {
+			return fmt.Errorf("amf0 unmarshal unsupported map key type %v", t.Key())
+		}
+
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(t))
+		}
+		for i := 0; i < len(props); i++ {
+			elem := reflect.New(t.Elem()).Elem()
+			if err := unmarshalValue(props[i].Value, elem); err != nil {
+				return err
+			}
+			v.SetMapIndex(reflect.ValueOf(props[i].Name.Value).Convert(t.Key()), elem)
+		}
+		return nil
+	}
+	return fmt.Errorf("amf0 unmarshal object into %v", v.Type())
+}
+
+func unmarshalElems(elems []ISrsAmf0Any, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), len(elems), len(elems)))
+	case reflect.Array:
+		// the left elements of array are zero.
+		v.Set(reflect.Zero(v.Type()))
+	default:
+		return fmt.Errorf("amf0 unmarshal strict array into %v", v.Type())
+	}
+
+	for i := 0; i < len(elems) && i < v.Len(); i++ {
+		if err := unmarshalValue(elems[i], v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// convert the amf0 value to the go value of interface{}.
+func anyToInterface(any ISrsAmf0Any) (interface{}, error) {
+	switch a := any.(type) {
+	case *SrsAmf0Null, *SrsAmf0Undefined:
+		return nil, nil
+	case *SrsAmf0Number:
+		return a.Value, nil
+	case *SrsAmf0Boolean:
+		return a.Value, nil
+	case *SrsAmf0String:
+		return a.Value.Value, nil
+	case *SrsAmf0LongString:
+		return a.Value, nil
+	case *SrsAmf0XmlDocument:
+		return a.Value, nil
+	case *SrsAmf0Date:
+		return time.Unix(0, int64(a.Value)*int64(time.Millisecond)), nil
+	case *SrsAmf0Object, *SrsAmf0EcmaArray, *SrsAmf0TypedObject:
+		m := make(map[string]interface{})
+		if err := unmarshalValue(any, reflect.ValueOf(&m).Elem()); err != nil {
+			return nil, err
+		}
+		return m, nil
+	case *SrsAmf0StrictArray:
+		arr := make([]interface{}, 0, len(a.Elems))
+		for i := 0; i < len(a.Elems); i++ {
+			elem, err := anyToInterface(a.Elems[i])
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, elem)
+		}
+		return arr, nil
+	}
+	return nil, fmt.Errorf("amf0 unmarshal %T into interface", any)
+}
+
+// find the field by name, or the case-insensitive name if no exactly one.
+func findField(fields []amf0Field, name string) *amf0Field {
+	for i := 0; i < len(fields); i++ {
+		if fields[i].name == name {
+			return &fields[i]
+		}
+	}
+
+	for i := 0; i < len(fields); i++ {
+		if strings.EqualFold(fields[i].name, name) {
+			return &fields[i]
+		}
+	}
+	return nil
+}
+
+func cachedFields(t reflect.Type) []amf0Field {
+	if fields, ok := amf0FieldsCache.Load(t); ok {
+		return fields.([]amf0Field)
+	}
+
+	fields := make([]amf0Field, 0, t.NumField())
+	typeFields(t, nil, &fields)
+	amf0FieldsCache.Store(t, fields)
+	return fields
+}
+
+func typeFields(t reflect.Type, index []int, fields *[]amf0Field) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("amf0")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts := tag, ""
+		if pos := strings.Index(tag, ","); pos >= 0 {
+			name, opts = tag[:pos], tag[pos+1:]
+		}
+
+		fieldIndex := make([]int, len(index)+1)
+		copy(fieldIndex, index)
+		fieldIndex[len(index)] = i
+
+		// promote the fields of anonymous struct, when no name specified.
+		if sf.Anonymous && name == "" && sf.Type.Kind() == reflect.Struct {
+			typeFields(sf.Type, fieldIndex, fields)
+			continue
+		}
+
+		// ignore the unexported fields.
+		if sf.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+
+		*fields = append(*fields, amf0Field{
+			name:      name,
+			index:     fieldIndex,
+			omitEmpty: strings.Contains(","+opts+",", ",omitempty,"),
+		})
+	}
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2019 GOSRS(gosrs)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package amf0
+
+import (
+	"reflect"
+	"testing"
+)
+
+type marshalInner struct {
+	Name  string             `amf0:"name"`
+	Tags  []string           `amf0:"tags"`
+	Props map[string]float64 `amf0:"props"`
+}
+
+type marshalOuter struct {
+	Raw    ISrsAmf0Any               `amf0:"raw"`
+	Any    interface{}               `amf0:"any"`
+	Inner  *marshalInner             `amf0:"inner"`
+	Nested map[string][]marshalInner `amf0:"nested"`
+	Grid   [][]int                   `amf0:"grid"`
+	Opt    string                    `amf0:"opt,omitempty"`
+	OptPtr *marshalInner             `amf0:"optPtr,omitempty"`
+	Skip   int                       `amf0:"-"`
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	cases := []struct {
+		name  string
+		in    marshalOuter
+		out   marshalOuter
+		props []string
+	}{
+		{
+			name: "nil fields",
+			in:   marshalOuter{},
+			// the nil ISrsAmf0Any is null, which is kept as the raw amf0 value.
+			out:   marshalOuter{Raw: NewSrsAmf0Null()},
+			props: []string{"raw", "any", "inner", "nested", "grid"},
+		},
+		{
+			name: "nested map and slice",
+			in: marshalOuter{
+				Raw:   NewSrsAmf0Number(1),
+				Any:   "live",
+				Inner: &marshalInner{Name: "a", Tags: []string{"x", "y"}, Props: map[string]float64{"w": 1, "h": 2}},
+				Nested: map[string][]marshalInner{
+					"k": {{Name: "b", Tags: []string{}}, {Name: "c", Props: map[string]float64{}}},
+				},
+				Grid: [][]int{{1, 2}, {}, nil},
+			},
+			out: marshalOuter{
+				Raw:   NewSrsAmf0Number(1),
+				Any:   "live",
+				Inner: &marshalInner{Name: "a", Tags: []string{"x", "y"}, Props: map[string]float64{"w": 1, "h": 2}},
+				Nested: map[string][]marshalInner{
+					"k": {{Name: "b", Tags: []string{}}, {Name: "c", Props: map[string]float64{}}},
+				},
+				Grid: [][]int{{1, 2}, {}, nil},
+			},
+			props: []string{"raw", "any", "inner", "nested", "grid"},
+		},
+		{
+			name:  "omitempty",
+			in:    marshalOuter{Raw: NewSrsAmf0Boolean(true), Opt: "v", OptPtr: &marshalInner{Name: "d"}, Skip: 1},
+			out:   marshalOuter{Raw: NewSrsAmf0Boolean(true), Opt: "v", OptPtr: &marshalInner{Name: "d"}},
+			props: []string{"raw", "any", "inner", "nested", "grid", "opt", "optPtr"},
+		},
+	}
+
+	for _, c := range cases {
+		data, err := Marshal(c.in)
+		if err != nil {
+			t.Fatalf("%s: marshal failed, err=%v", c.name, err)
+		}
+
+		any, err := MarshalAny(c.in)
+		if err != nil {
+			t.Fatalf("%s: marshal any failed, err=%v", c.name, err)
+		}
+		obj, ok := any.(*SrsAmf0Object)
+		if !ok {
+			t.Fatalf("%s: marshal to %T, want object", c.name, any)
+		}
+		names := make([]string, 0, len(obj.Properties))
+		for i := 0; i < len(obj.Properties); i++ {
+			names = append(names, obj.Properties[i].Name.Value)
+		}
+		if !reflect.DeepEqual(names, c.props) {
+			t.Errorf("%s: properties %v, want %v", c.name, names, c.props)
+		}
+
+		var out marshalOuter
+		if err := Unmarshal(data, &out); err != nil {
+			t.Fatalf("%s: unmarshal failed, err=%v", c.name, err)
+		}
+		if !reflect.DeepEqual(out, c.out) {
+			t.Errorf("%s: unmarshal %+v, want %+v", c.name, out, c.out)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2019 GOSRS(gosrs)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package amf0
+
+import (
+	"encoding/binary"
+	"errors"
+	"go_srs/srs/utils"
+)
+
+/**
+* the reference to the complex object already sent, the index is 2bytes,
+* we keep the index to encode as is, the resolve is up to the user.
+ */
+type SrsAmf0Reference struct {
+	Index uint16
+}
+
+func NewSrsAmf0Reference(index uint16) *SrsAmf0Reference {
+	return &SrsAmf0Reference{
+		Index: index,
+	}
+}
+
+func (this *SrsAmf0Reference) Decode(stream *utils.SrsStream) error {
+	marker, err := stream.ReadByte()
+	if err != nil {
+		return err
+	}
+
+	if marker != RTMP_AMF0_Reference {
+		err := errors.New("amf0 check reference marker failed.")
+		return err
+	}
+
+	index, err := stream.ReadInt16(binary.BigEndian)
+	if err != nil {
+		return err
+	}
+	this.Index = uint16(index)
+	return nil
+}
+
+func (this *SrsAmf0Reference) Encode(stream *utils.SrsStream) error {
+	stream.WriteByte(RTMP_AMF0_Reference)
+	stream.WriteInt16(int16(this.Index), binary.BigEndian)
+	return nil
+}
+
+func (this *SrsAmf0Reference) IsMyType(stream *utils.SrsStream) (bool, error) {
+	marker, err := stream.PeekByte()
+	if err != nil {
+		return false, err
+	}
+
+	if marker == RTMP_AMF0_Reference {
+		return true, nil
+	}
+	return false, nil
+}
+
+func (this *SrsAmf0Reference) GetValue() interface{} {
+	return this.Index
+}
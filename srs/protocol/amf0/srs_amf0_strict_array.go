@@ -0,0 +1,121 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2019 GOSRS(gosrs)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package amf0
+
+import (
+	"encoding/binary"
+	"errors"
+	"go_srs/srs/utils"
+)
+
+/**
+* the strict array, the count of elements is 4bytes, then the values without name,
+* for example, the keyframes filepositions and times of onMetaData.
+ */
+type SrsAmf0StrictArray struct {
+	Elems []ISrsAmf0Any
+}
+
+func NewSrsAmf0StrictArray() *SrsAmf0StrictArray {
+	return &SrsAmf0StrictArray{
+		Elems: make([]ISrsAmf0Any, 0),
+	}
+}
+
+func (this *SrsAmf0StrictArray) Count() int {
+	return len(this.Elems)
+}
+
+func (this *SrsAmf0StrictArray) At(i int) ISrsAmf0Any {
+	if i < len(this.Elems) {
+		return this.Elems[i]
+	}
+	return nil
+}
+
+func (this *SrsAmf0StrictArray) Append(value ISrsAmf0Any) {
+	this.Elems = append(this.Elems, value)
+}
+
+func (this *SrsAmf0StrictArray) Decode(stream *utils.SrsStream) error {
+	marker, err := stream.ReadByte()
+	if err != nil {
+		return err
+	}
+
+	if marker != RTMP_AMF0_StrictArray {
+		err = errors.New("amf0 check strict array marker failed. ")
+		return err
+	}
+
+	count, err := stream.ReadInt32(binary.BigEndian)
+	if err != nil {
+		return err
+	}
+
+	this.Elems = make([]ISrsAmf0Any, 0)
+	for i := int32(0); i < count; i++ {
+		marker, err := stream.PeekByte()
+		if err != nil {
+			return err
+		}
+
+		v := GenerateSrsAmf0Any(marker)
+		if v == nil {
+			return errors.New("amf0 unknown strict array element marker.")
+		}
+
+		if err = v.Decode(stream); err != nil {
+			return err
+		}
+		this.Elems = append(this.Elems, v)
+	}
+	return nil
+}
+
+func (this *SrsAmf0StrictArray) Encode(stream *utils.SrsStream) error {
+	stream.WriteByte(RTMP_AMF0_StrictArray)
+	stream.WriteInt32(int32(len(this.Elems)), binary.BigEndian)
+	for i := 0; i < len(this.Elems); i++ {
+		if err := this.Elems[i].Encode(stream); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (this *SrsAmf0StrictArray) IsMyType(stream *utils.SrsStream) (bool, error) {
+	marker, err := stream.PeekByte()
+	if err != nil {
+		return false, err
+	}
+
+	if marker == RTMP_AMF0_StrictArray {
+		return true, nil
+	}
+	return false, nil
+}
+
+func (this *SrsAmf0StrictArray) GetValue() interface{} {
+	return this.Elems
+}
@@ -0,0 +1,130 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2019 GOSRS(gosrs)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package amf0
+
+import (
+	"errors"
+	"go_srs/srs/utils"
+)
+
+/**
+* the typed object, which is the object with class name,
+* the class name is utf8, then the properties and the object end.
+ */
+type SrsAmf0TypedObject struct {
+	ClassName  SrsAmf0Utf8
+	Properties []SrsValuePair
+	eof        *SrsAmf0ObjectEOF
+}
+
+func NewSrsAmf0TypedObject(className string) *SrsAmf0TypedObject {
+	return &SrsAmf0TypedObject{
+		ClassName:  SrsAmf0Utf8{Value: className},
+		Properties: make([]SrsValuePair, 0),
+		eof:        &SrsAmf0ObjectEOF{},
+	}
+}
+
+func (this *SrsAmf0TypedObject) Decode(stream *utils.SrsStream) error {
+	marker, err := stream.ReadByte()
+	if err != nil {
+		return err
+	}
+
+	if marker != RTMP_AMF0_TypedObject {
+		err = errors.New("amf0 check typed object marker failed. ")
+		return err
+	}
+
+	if err = this.ClassName.Decode(stream); err != nil {
+		return err
+	}
+
+	for {
+		var is_eof bool
+		if is_eof, err = this.eof.IsMyType(stream); err != nil {
+			return err
+		}
+
+		if is_eof {
+			return this.eof.Decode(stream)
+		}
+
+		var pname SrsAmf0Utf8 = SrsAmf0Utf8{}
+		if err = pname.Decode(stream); err != nil {
+			return err
+		}
+
+		marker, err := stream.PeekByte()
+		if err != nil {
+			return err
+		}
+
+		v := GenerateSrsAmf0Any(marker)
+		if v == nil {
+			return errors.New("amf0 unknown property marker.")
+		}
+
+		if err = v.Decode(stream); err != nil {
+			return err
+		}
+
+		this.Properties = append(this.Properties, SrsValuePair{Name: pname, Value: v})
+	}
+}
+
+func (this *SrsAmf0TypedObject) Encode(stream *utils.SrsStream) error {
+	stream.WriteByte(RTMP_AMF0_TypedObject)
+	_ = this.ClassName.Encode(stream)
+	for i := 0; i < len(this.Properties); i++ {
+		_ = this.Properties[i].Name.Encode(stream)
+		_ = this.Properties[i].Value.Encode(stream)
+	}
+	_ = this.eof.Encode(stream)
+	return nil
+}
+
+func (this *SrsAmf0TypedObject) IsMyType(stream *utils.SrsStream) (bool, error) {
+	marker, err := stream.PeekByte()
+	if err != nil {
+		return false, err
+	}
+
+	if marker == RTMP_AMF0_TypedObject {
+		return true, nil
+	}
+	return false, nil
+}
+
+func (this *SrsAmf0TypedObject) Get(name string) ISrsAmf0Any {
+	for i := 0; i < len(this.Properties); i++ {
+		if this.Properties[i].Name.Value == name {
+			return this.Properties[i].Value
+		}
+	}
+	return nil
+}
+
+func (this *SrsAmf0TypedObject) GetValue() interface{} {
+	return this.Properties
+}
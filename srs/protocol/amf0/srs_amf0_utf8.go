@@ -24,7 +24,6 @@ package amf0
 
 import (
 	"encoding/binary"
-	"errors"
 	"go_srs/srs/utils"
 )
 
@@ -44,12 +43,8 @@ func (this *SrsAmf0Utf8) Decode(stream *utils.SrsStream) error {
 		return err
 	}
 
-	if len <= 0 {
-		err = errors.New("amf0 read empty string.")
-		return err
-	}
-
-	this.Value, err = stream.ReadString(uint32(len))
+	// the empty string is allowed, for example, the empty value of amf3 string.
+	this.Value, err = stream.ReadString(uint32(uint16(len)))
 	return err
 }
 
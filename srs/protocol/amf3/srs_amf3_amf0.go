@@ -0,0 +1,231 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2019 GOSRS(gosrs)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package amf3
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"go_srs/srs/protocol/amf0"
+	"go_srs/srs/utils"
+	"strconv"
+)
+
+/**
+* convert the payload of amf3 command/data message to the pure amf0 stream,
+* the amf3 message is amf0 values, any value maybe switched to amf3 by the AVM+ marker(0x11),
+* so the packets which decode amf0 can decode the amf3 message without change.
+ */
+func ConvertToAmf0(payload []byte) ([]byte, error) {
+	stream := utils.NewSrsStream(payload)
+	out := utils.NewSrsStream([]byte{})
+
+	for !stream.Empty() {
+		if err := convertAmf0Value(stream, out); err != nil {
+			return nil, err
+		}
+	}
+	return out.Data(), nil
+}
+
+// copy the amf0 value from stream to out, the AVM+ value is converted to amf0.
+func convertAmf0Value(stream *utils.SrsStream, out *utils.SrsStream) error {
+	marker, err := stream.PeekByte()
+	if err != nil {
+		return err
+	}
+
+	switch marker {
+	case amf0.RTMP_AMF0_AVMplusObject:
+		stream.Skip(1)
+		// each AVM+ value has its own reference tables.
+		v, err := NewSrsAmf3Context().ReadAny(stream)
+		if err != nil {
+			return err
+		}
+		return ToAmf0(v).Encode(out)
+	case amf0.RTMP_AMF0_Object, amf0.RTMP_AMF0_EcmaArray:
+		stream.Skip(1)
+		out.WriteByte(marker)
+		if marker == amf0.RTMP_AMF0_EcmaArray {
+			count, err := stream.ReadInt32(binary.BigEndian)
+			if err != nil {
+				return err
+			}
+			out.WriteInt32(count, binary.BigEndian)
+		}
+
+		// copy the properties until the object end 0x00 0x00 0x09.
+		for {
+			length, err := stream.ReadInt16(binary.BigEndian)
+			if err != nil {
+				return err
+			}
+
+			name, err := stream.ReadBytes(uint32(uint16(length)))
+			if err != nil {
+				return err
+			}
+			out.WriteInt16(length, binary.BigEndian)
+			out.WriteBytes(name)
+
+			if length == 0 {
+				if end, err := stream.PeekByte(); err == nil && end == amf0.RTMP_AMF0_ObjectEnd {
+					stream.Skip(1)
+					out.WriteByte(amf0.RTMP_AMF0_ObjectEnd)
+					return nil
+				}
+			}
+
+			if err := convertAmf0Value(stream, out); err != nil {
+				return err
+			}
+		}
+	}
+
+	v := amf0.GenerateSrsAmf0Any(marker)
+	if v == nil {
+		return errors.New("amf0 unknown marker " + strconv.Itoa(int(marker)))
+	}
+
+	if err := v.Decode(stream); err != nil {
+		return err
+	}
+	return v.Encode(out)
+}
+
+/**
+* convert the amf3 value to the amf0 value, the integer is number, the xml is xml document,
+* the dense array and vector are strict array, the array with associative part and dictionary are ecma array,
+* the value which references its ancestor is converted to null.
+ */
+func ToAmf0(v ISrsAmf3Any) amf0.ISrsAmf0Any {
+	return toAmf0(v, make(map[ISrsAmf3Any]bool))
+}
+
+func toAmf0(v ISrsAmf3Any, parents map[ISrsAmf3Any]bool) amf0.ISrsAmf0Any {
+	if v == nil || parents[v] {
+		return amf0.NewSrsAmf0Null()
+	}
+
+	switch t := v.(type) {
+	case *SrsAmf3Undefined:
+		return &amf0.SrsAmf0Undefined{}
+	case *SrsAmf3Null:
+		return amf0.NewSrsAmf0Null()
+	case *SrsAmf3Boolean:
+		return amf0.NewSrsAmf0Boolean(t.Value)
+	case *SrsAmf3Integer:
+		return amf0.NewSrsAmf0Number(float64(t.Value))
+	case *SrsAmf3Double:
+		return amf0.NewSrsAmf0Number(t.Value)
+	case *SrsAmf3Date:
+		return amf0.NewSrsAmf0Date(t.Value)
+	case *SrsAmf3String:
+		return amf0.NewSrsAmf0String(t.Value)
+	case *SrsAmf3Xml:
+		return amf0.NewSrsAmf0XmlDocument(t.Value)
+	case *SrsAmf3ByteArray:
+		return amf0.NewSrsAmf0String(string(t.Value))
+	}
+
+	parents[v] = true
+	defer delete(parents, v)
+
+	switch t := v.(type) {
+	case *SrsAmf3Object:
+		if t.Traits != nil && t.Traits.Externalizable {
+			return toAmf0(t.External, parents)
+		}
+
+		obj := amf0.NewSrsAmf0Object()
+		if t.Traits != nil {
+			for i := 0; i < len(t.Traits.Members) && i < len(t.Sealed); i++ {
+				obj.Properties = append(obj.Properties, newAmf0Pair(t.Traits.Members[i], toAmf0(t.Sealed[i], parents)))
+			}
+		}
+		for i := 0; i < len(t.Dynamic); i++ {
+			obj.Properties = append(obj.Properties, newAmf0Pair(t.Dynamic[i].Name, toAmf0(t.Dynamic[i].Value, parents)))
+		}
+		return obj
+	case *SrsAmf3Array:
+		if len(t.Assoc) == 0 {
+			arr := amf0.NewSrsAmf0StrictArray()
+			for i := 0; i < len(t.Dense); i++ {
+				arr.Append(toAmf0(t.Dense[i], parents))
+			}
+			return arr
+		}
+
+		arr := amf0.NewSrsAmf0EcmaArray()
+		for i := 0; i < len(t.Dense); i++ {
+			arr.Properties = append(arr.Properties, newAmf0Pair(strconv.Itoa(i), toAmf0(t.Dense[i], parents)))
+		}
+		for i := 0; i < len(t.Assoc); i++ {
+			arr.Properties = append(arr.Properties, newAmf0Pair(t.Assoc[i].Name, toAmf0(t.Assoc[i].Value, parents)))
+		}
+		return arr
+	case *SrsAmf3VectorInt:
+		arr := amf0.NewSrsAmf0StrictArray()
+		for i := 0; i < len(t.Value); i++ {
+			arr.Append(amf0.NewSrsAmf0Number(float64(t.Value[i])))
+		}
+		return arr
+	case *SrsAmf3VectorUInt:
+		arr := amf0.NewSrsAmf0StrictArray()
+		for i := 0; i < len(t.Value); i++ {
+			arr.Append(amf0.NewSrsAmf0Number(float64(t.Value[i])))
+		}
+		return arr
+	case *SrsAmf3VectorDouble:
+		arr := amf0.NewSrsAmf0StrictArray()
+		for i := 0; i < len(t.Value); i++ {
+			arr.Append(amf0.NewSrsAmf0Number(t.Value[i]))
+		}
+		return arr
+	case *SrsAmf3VectorObject:
+		arr := amf0.NewSrsAmf0StrictArray()
+		for i := 0; i < len(t.Value); i++ {
+			arr.Append(toAmf0(t.Value[i], parents))
+		}
+		return arr
+	case *SrsAmf3Dictionary:
+		arr := amf0.NewSrsAmf0EcmaArray()
+		for i := 0; i < len(t.Entries); i++ {
+			var key string
+			if t.Entries[i].Key != nil {
+				key = fmt.Sprint(t.Entries[i].Key.GetValue())
+			}
+			arr.Properties = append(arr.Properties, newAmf0Pair(key, toAmf0(t.Entries[i].Value, parents)))
+		}
+		return arr
+	}
+	return amf0.NewSrsAmf0Null()
+}
+
+func newAmf0Pair(name string, value amf0.ISrsAmf0Any) amf0.SrsValuePair {
+	return amf0.SrsValuePair{
+		Name:  amf0.SrsAmf0Utf8{Value: name},
+		Value: value,
+	}
+}
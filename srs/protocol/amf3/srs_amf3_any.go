@@ -0,0 +1,87 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2019 GOSRS(gosrs)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package amf3
+
+import (
+	"go_srs/srs/utils"
+)
+
+type ISrsAmf3Any interface {
+	Decode(ctx *SrsAmf3Context, stream *utils.SrsStream) error
+	Encode(ctx *SrsAmf3Context, stream *utils.SrsStream) error
+	IsMyType(stream *utils.SrsStream) (bool, error)
+	GetValue() interface{}
+}
+
+func GenerateSrsAmf3Any(marker byte) ISrsAmf3Any {
+	switch marker {
+	case RTMP_AMF3_Undefined:
+		return &SrsAmf3Undefined{}
+	case RTMP_AMF3_Null:
+		return &SrsAmf3Null{}
+	case RTMP_AMF3_False, RTMP_AMF3_True:
+		return &SrsAmf3Boolean{}
+	case RTMP_AMF3_Integer:
+		return &SrsAmf3Integer{}
+	case RTMP_AMF3_Double:
+		return &SrsAmf3Double{}
+	case RTMP_AMF3_String:
+		return &SrsAmf3String{}
+	case RTMP_AMF3_XmlDocument, RTMP_AMF3_Xml:
+		return &SrsAmf3Xml{Marker: marker}
+	case RTMP_AMF3_Date:
+		return &SrsAmf3Date{}
+	case RTMP_AMF3_Array:
+		return NewSrsAmf3Array()
+	case RTMP_AMF3_Object:
+		return &SrsAmf3Object{}
+	case RTMP_AMF3_ByteArray:
+		return &SrsAmf3ByteArray{}
+	case RTMP_AMF3_VectorInt:
+		return &SrsAmf3VectorInt{}
+	case RTMP_AMF3_VectorUInt:
+		return &SrsAmf3VectorUInt{}
+	case RTMP_AMF3_VectorDouble:
+		return &SrsAmf3VectorDouble{}
+	case RTMP_AMF3_VectorObject:
+		return &SrsAmf3VectorObject{}
+	case RTMP_AMF3_Dictionary:
+		return &SrsAmf3Dictionary{}
+	default:
+		return nil
+	}
+}
+
+func isMyMarker(stream *utils.SrsStream, markers ...byte) (bool, error) {
+	marker, err := stream.PeekByte()
+	if err != nil {
+		return false, err
+	}
+
+	for i := 0; i < len(markers); i++ {
+		if marker == markers[i] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
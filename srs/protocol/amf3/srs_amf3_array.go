@@ -0,0 +1,127 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2019 GOSRS(gosrs)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package amf3
+
+import (
+	"errors"
+	"go_srs/srs/utils"
+)
+
+/**
+* the amf3 array, which contains the associative part and the dense part,
+* the associative part is name-value pairs terminated by the empty string,
+* the dense part is the values indexed by number.
+ */
+type SrsAmf3Array struct {
+	Dense []ISrsAmf3Any
+	Assoc []SrsAmf3ValuePair
+}
+
+func NewSrsAmf3Array() *SrsAmf3Array {
+	return &SrsAmf3Array{
+		Dense: make([]ISrsAmf3Any, 0),
+		Assoc: make([]SrsAmf3ValuePair, 0),
+	}
+}
+
+func (this *SrsAmf3Array) Decode(ctx *SrsAmf3Context, stream *utils.SrsStream) error {
+	ref, count, err := ctx.readComplexHeader(stream, RTMP_AMF3_Array, this)
+	if err != nil {
+		return err
+	}
+
+	if ref != nil {
+		v, ok := ref.(*SrsAmf3Array)
+		if !ok {
+			return errors.New("amf3 array reference type mismatch.")
+		}
+		*this = *v
+		return nil
+	}
+
+	for {
+		name, err := ctx.ReadUtf8Vr(stream)
+		if err != nil {
+			return err
+		}
+
+		if name == "" {
+			break
+		}
+
+		value, err := ctx.ReadAny(stream)
+		if err != nil {
+			return err
+		}
+		this.Assoc = append(this.Assoc, SrsAmf3ValuePair{Name: name, Value: value})
+	}
+
+	for i := uint32(0); i < count; i++ {
+		value, err := ctx.ReadAny(stream)
+		if err != nil {
+			return err
+		}
+		this.Dense = append(this.Dense, value)
+	}
+	return nil
+}
+
+func (this *SrsAmf3Array) Encode(ctx *SrsAmf3Context, stream *utils.SrsStream) error {
+	isRef, err := ctx.writeComplexHeader(stream, RTMP_AMF3_Array, this)
+	if err != nil || isRef {
+		return err
+	}
+
+	if err := ctx.WriteU29(stream, uint32(len(this.Dense))<<1|0x01); err != nil {
+		return err
+	}
+
+	for i := 0; i < len(this.Assoc); i++ {
+		if err := ctx.WriteUtf8Vr(stream, this.Assoc[i].Name); err != nil {
+			return err
+		}
+
+		if err := ctx.WriteAny(stream, this.Assoc[i].Value); err != nil {
+			return err
+		}
+	}
+
+	if err := ctx.WriteUtf8Vr(stream, ""); err != nil {
+		return err
+	}
+
+	for i := 0; i < len(this.Dense); i++ {
+		if err := ctx.WriteAny(stream, this.Dense[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (this *SrsAmf3Array) IsMyType(stream *utils.SrsStream) (bool, error) {
+	return isMyMarker(stream, RTMP_AMF3_Array)
+}
+
+func (this *SrsAmf3Array) GetValue() interface{} {
+	return this
+}
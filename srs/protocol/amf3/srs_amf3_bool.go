@@ -0,0 +1,73 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2019 GOSRS(gosrs)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package amf3
+
+import (
+	"errors"
+	"go_srs/srs/utils"
+)
+
+// the amf3 boolean has no value, the marker false(0x02) or true(0x03) is the value.
+type SrsAmf3Boolean struct {
+	Value bool
+}
+
+func NewSrsAmf3Boolean(data bool) *SrsAmf3Boolean {
+	return &SrsAmf3Boolean{
+		Value: data,
+	}
+}
+
+func (this *SrsAmf3Boolean) Decode(ctx *SrsAmf3Context, stream *utils.SrsStream) error {
+	marker, err := stream.ReadByte()
+	if err != nil {
+		return err
+	}
+
+	switch marker {
+	case RTMP_AMF3_False:
+		this.Value = false
+	case RTMP_AMF3_True:
+		this.Value = true
+	default:
+		return errors.New("amf3 check bool marker failed.")
+	}
+	return nil
+}
+
+func (this *SrsAmf3Boolean) Encode(ctx *SrsAmf3Context, stream *utils.SrsStream) error {
+	if this.Value {
+		stream.WriteByte(RTMP_AMF3_True)
+	} else {
+		stream.WriteByte(RTMP_AMF3_False)
+	}
+	return nil
+}
+
+func (this *SrsAmf3Boolean) IsMyType(stream *utils.SrsStream) (bool, error) {
+	return isMyMarker(stream, RTMP_AMF3_False, RTMP_AMF3_True)
+}
+
+func (this *SrsAmf3Boolean) GetValue() interface{} {
+	return this.Value
+}
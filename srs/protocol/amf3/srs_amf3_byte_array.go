@@ -0,0 +1,83 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2019 GOSRS(gosrs)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package amf3
+
+import (
+	"errors"
+	"go_srs/srs/utils"
+)
+
+type SrsAmf3ByteArray struct {
+	Value []byte
+}
+
+func NewSrsAmf3ByteArray(data []byte) *SrsAmf3ByteArray {
+	return &SrsAmf3ByteArray{
+		Value: data,
+	}
+}
+
+func (this *SrsAmf3ByteArray) Decode(ctx *SrsAmf3Context, stream *utils.SrsStream) error {
+	ref, length, err := ctx.readComplexHeader(stream, RTMP_AMF3_ByteArray, this)
+	if err != nil {
+		return err
+	}
+
+	if ref != nil {
+		v, ok := ref.(*SrsAmf3ByteArray)
+		if !ok {
+			return errors.New("amf3 byte array reference type mismatch.")
+		}
+		*this = *v
+		return nil
+	}
+
+	b, err := stream.ReadBytes(length)
+	if err != nil {
+		return err
+	}
+	this.Value = make([]byte, len(b))
+	copy(this.Value, b)
+	return nil
+}
+
+func (this *SrsAmf3ByteArray) Encode(ctx *SrsAmf3Context, stream *utils.SrsStream) error {
+	isRef, err := ctx.writeComplexHeader(stream, RTMP_AMF3_ByteArray, this)
+	if err != nil || isRef {
+		return err
+	}
+
+	if err := ctx.WriteU29(stream, uint32(len(this.Value))<<1|0x01); err != nil {
+		return err
+	}
+	stream.WriteBytes(this.Value)
+	return nil
+}
+
+func (this *SrsAmf3ByteArray) IsMyType(stream *utils.SrsStream) (bool, error) {
+	return isMyMarker(stream, RTMP_AMF3_ByteArray)
+}
+
+func (this *SrsAmf3ByteArray) GetValue() interface{} {
+	return this.Value
+}
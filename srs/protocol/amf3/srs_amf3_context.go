@@ -0,0 +1,281 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2019 GOSRS(gosrs)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package amf3
+
+import (
+	"errors"
+	"go_srs/srs/utils"
+)
+
+/**
+* the amf3 reference tables of strings, complex objects and traits.
+* a context must be used for a whole amf3 value graph,
+* for the references are indexes of values already read or written.
+ */
+type SrsAmf3Context struct {
+	strings []string
+	objects []ISrsAmf3Any
+	traits  []*SrsAmf3Traits
+
+	stringRefs map[string]int
+	objectRefs map[ISrsAmf3Any]int
+	traitsRefs map[*SrsAmf3Traits]int
+}
+
+func NewSrsAmf3Context() *SrsAmf3Context {
+	return &SrsAmf3Context{
+		strings:    make([]string, 0),
+		objects:    make([]ISrsAmf3Any, 0),
+		traits:     make([]*SrsAmf3Traits, 0),
+		stringRefs: make(map[string]int),
+		objectRefs: make(map[ISrsAmf3Any]int),
+		traitsRefs: make(map[*SrsAmf3Traits]int),
+	}
+}
+
+/**
+* read the U29, the variable length unsigned 29bits integer,
+* the first 3bytes use the high bit to indicate whether more bytes follow,
+* the 4th byte use all 8bits.
+ */
+func (this *SrsAmf3Context) ReadU29(stream *utils.SrsStream) (uint32, error) {
+	var v uint32 = 0
+	for i := 0; i < 3; i++ {
+		b, err := stream.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+
+		if b&0x80 == 0 {
+			return v<<7 | uint32(b), nil
+		}
+		v = v<<7 | uint32(b&0x7F)
+	}
+
+	b, err := stream.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	return v<<8 | uint32(b), nil
+}
+
+func (this *SrsAmf3Context) WriteU29(stream *utils.SrsStream, v uint32) error {
+	if v > SRS_AMF3_U29_MAX {
+		return errors.New("amf3 U29 overflow")
+	}
+
+	if v < 0x80 {
+		stream.WriteByte(byte(v))
+	} else if v < 0x4000 {
+		stream.WriteByte(byte(v>>7) | 0x80)
+		stream.WriteByte(byte(v & 0x7F))
+	} else if v < 0x200000 {
+		stream.WriteByte(byte(v>>14) | 0x80)
+		stream.WriteByte(byte(v>>7) | 0x80)
+		stream.WriteByte(byte(v & 0x7F))
+	} else {
+		stream.WriteByte(byte(v>>22) | 0x80)
+		stream.WriteByte(byte(v>>15) | 0x80)
+		stream.WriteByte(byte(v>>8) | 0x80)
+		stream.WriteByte(byte(v))
+	}
+	return nil
+}
+
+// parse the U29 from bytes without consume, return the U29 and the bytes used.
+func peekU29(b []byte) (uint32, int, bool) {
+	var v uint32 = 0
+	for i := 0; i < 4 && i < len(b); i++ {
+		if i == 3 {
+			return v<<8 | uint32(b[i]), 4, true
+		}
+
+		if b[i]&0x80 == 0 {
+			return v<<7 | uint32(b[i]), i + 1, true
+		}
+		v = v<<7 | uint32(b[i]&0x7F)
+	}
+	return 0, 0, false
+}
+
+/**
+* read the UTF-8-vr, the string maybe a reference to the string table,
+* the empty string is never sent by reference.
+ */
+func (this *SrsAmf3Context) ReadUtf8Vr(stream *utils.SrsStream) (string, error) {
+	u29, err := this.ReadU29(stream)
+	if err != nil {
+		return "", err
+	}
+
+	if u29&0x01 == 0 {
+		index := int(u29 >> 1)
+		if index >= len(this.strings) {
+			return "", errors.New("amf3 invalid string reference")
+		}
+		return this.strings[index], nil
+	}
+
+	str, err := stream.ReadString(u29 >> 1)
+	if err != nil {
+		return "", err
+	}
+
+	if str != "" {
+		this.stringRefs[str] = len(this.strings)
+		this.strings = append(this.strings, str)
+	}
+	return str, nil
+}
+
+func (this *SrsAmf3Context) WriteUtf8Vr(stream *utils.SrsStream, str string) error {
+	if str == "" {
+		return this.WriteU29(stream, 0x01)
+	}
+
+	if index, ok := this.stringRefs[str]; ok {
+		return this.WriteU29(stream, uint32(index)<<1)
+	}
+
+	this.stringRefs[str] = len(this.strings)
+	this.strings = append(this.strings, str)
+	if err := this.WriteU29(stream, uint32(len(str))<<1|0x01); err != nil {
+		return err
+	}
+	stream.WriteString(str)
+	return nil
+}
+
+func (this *SrsAmf3Context) addObject(v ISrsAmf3Any) {
+	this.objectRefs[v] = len(this.objects)
+	this.objects = append(this.objects, v)
+}
+
+func (this *SrsAmf3Context) getObject(index uint32) (ISrsAmf3Any, error) {
+	if int(index) >= len(this.objects) {
+		return nil, errors.New("amf3 invalid object reference")
+	}
+	return this.objects[index], nil
+}
+
+func (this *SrsAmf3Context) addTraits(t *SrsAmf3Traits) {
+	this.traitsRefs[t] = len(this.traits)
+	this.traits = append(this.traits, t)
+}
+
+func (this *SrsAmf3Context) getTraits(index uint32) (*SrsAmf3Traits, error) {
+	if int(index) >= len(this.traits) {
+		return nil, errors.New("amf3 invalid traits reference")
+	}
+	return this.traits[index], nil
+}
+
+/**
+* read the header of complex type, which is the marker and U29.
+* @return the referenced object if the U29 is a reference,
+*       otherwise, the U29 value and register the v to the object table.
+ */
+func (this *SrsAmf3Context) readComplexHeader(stream *utils.SrsStream, marker byte, v ISrsAmf3Any) (ISrsAmf3Any, uint32, error) {
+	m, err := stream.ReadByte()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if m != marker {
+		return nil, 0, errors.New("amf3 check complex marker failed.")
+	}
+
+	u29, err := this.ReadU29(stream)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if u29&0x01 == 0 {
+		ref, err := this.getObject(u29 >> 1)
+		return ref, 0, err
+	}
+
+	this.addObject(v)
+	return nil, u29 >> 1, nil
+}
+
+/**
+* the count of elements is specified by peer, each element is at least elemSize bytes,
+* so reject the count larger than the left bytes, never allocate for the count of wire.
+ */
+func requireElements(stream *utils.SrsStream, count uint32, elemSize uint32) error {
+	if uint64(count)*uint64(elemSize) > uint64(len(stream.PeekLeftBytes())) {
+		return errors.New("amf3 elements count exceed the left bytes")
+	}
+	return nil
+}
+
+/**
+* write the marker of complex type, and the reference if the v is already written.
+* @return true if written as reference.
+ */
+func (this *SrsAmf3Context) writeComplexHeader(stream *utils.SrsStream, marker byte, v ISrsAmf3Any) (bool, error) {
+	stream.WriteByte(marker)
+	if index, ok := this.objectRefs[v]; ok {
+		return true, this.WriteU29(stream, uint32(index)<<1)
+	}
+
+	this.addObject(v)
+	return false, nil
+}
+
+/**
+* read any amf3 value from stream,
+* the reference of complex type is returned as the same object.
+ */
+func (this *SrsAmf3Context) ReadAny(stream *utils.SrsStream) (ISrsAmf3Any, error) {
+	marker, err := stream.PeekByte()
+	if err != nil {
+		return nil, err
+	}
+
+	if isComplexMarker(marker) {
+		b := stream.PeekLeftBytes()
+		if u29, n, ok := peekU29(b[1:]); ok && u29&0x01 == 0 {
+			stream.Skip(uint32(1 + n))
+			return this.getObject(u29 >> 1)
+		}
+	}
+
+	v := GenerateSrsAmf3Any(marker)
+	if v == nil {
+		return nil, errors.New("amf3 unknown marker")
+	}
+
+	if err := v.Decode(this, stream); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (this *SrsAmf3Context) WriteAny(stream *utils.SrsStream, v ISrsAmf3Any) error {
+	if v == nil {
+		return NewSrsAmf3Null().Encode(this, stream)
+	}
+	return v.Encode(this, stream)
+}
@@ -0,0 +1,72 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2019 GOSRS(gosrs)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package amf3
+
+const (
+	RTMP_AMF3_Undefined    = 0x00
+	RTMP_AMF3_Null         = 0x01
+	RTMP_AMF3_False        = 0x02
+	RTMP_AMF3_True         = 0x03
+	RTMP_AMF3_Integer      = 0x04
+	RTMP_AMF3_Double       = 0x05
+	RTMP_AMF3_String       = 0x06
+	RTMP_AMF3_XmlDocument  = 0x07
+	RTMP_AMF3_Date         = 0x08
+	RTMP_AMF3_Array        = 0x09
+	RTMP_AMF3_Object       = 0x0A
+	RTMP_AMF3_Xml          = 0x0B
+	RTMP_AMF3_ByteArray    = 0x0C
+	RTMP_AMF3_VectorInt    = 0x0D
+	RTMP_AMF3_VectorUInt   = 0x0E
+	RTMP_AMF3_VectorDouble = 0x0F
+	RTMP_AMF3_VectorObject = 0x10
+	RTMP_AMF3_Dictionary   = 0x11
+)
+
+/**
+* the integer in amf3 is 29bits signed integer,
+* the value out of range must be encoded as double.
+ */
+const (
+	SRS_AMF3_INTEGER_MAX = 0x0FFFFFFF
+	SRS_AMF3_INTEGER_MIN = -0x10000000
+)
+
+// the U29 max value.
+const SRS_AMF3_U29_MAX = 0x1FFFFFFF
+
+type SrsAmf3ValuePair struct {
+	Name  string
+	Value ISrsAmf3Any
+}
+
+// whether the value of marker is complex type, which use the object reference table.
+func isComplexMarker(marker byte) bool {
+	switch marker {
+	case RTMP_AMF3_XmlDocument, RTMP_AMF3_Date, RTMP_AMF3_Array, RTMP_AMF3_Object, RTMP_AMF3_Xml,
+		RTMP_AMF3_ByteArray, RTMP_AMF3_VectorInt, RTMP_AMF3_VectorUInt, RTMP_AMF3_VectorDouble,
+		RTMP_AMF3_VectorObject, RTMP_AMF3_Dictionary:
+		return true
+	}
+	return false
+}
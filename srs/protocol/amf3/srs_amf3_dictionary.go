@@ -0,0 +1,115 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2019 GOSRS(gosrs)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package amf3
+
+import (
+	"errors"
+	"go_srs/srs/utils"
+)
+
+type SrsAmf3DictionaryEntry struct {
+	Key   ISrsAmf3Any
+	Value ISrsAmf3Any
+}
+
+// the dictionary, whose key can be any amf3 value.
+type SrsAmf3Dictionary struct {
+	WeakKeys bool
+	Entries  []SrsAmf3DictionaryEntry
+}
+
+func NewSrsAmf3Dictionary() *SrsAmf3Dictionary {
+	return &SrsAmf3Dictionary{
+		Entries: make([]SrsAmf3DictionaryEntry, 0),
+	}
+}
+
+func (this *SrsAmf3Dictionary) Decode(ctx *SrsAmf3Context, stream *utils.SrsStream) error {
+	ref, count, err := ctx.readComplexHeader(stream, RTMP_AMF3_Dictionary, this)
+	if err != nil {
+		return err
+	}
+
+	if ref != nil {
+		v, ok := ref.(*SrsAmf3Dictionary)
+		if !ok {
+			return errors.New("amf3 dictionary reference type mismatch.")
+		}
+		*this = *v
+		return nil
+	}
+
+	if this.WeakKeys, err = stream.ReadBool(); err != nil {
+		return err
+	}
+
+	// each entry is a key and a value.
+	if err = requireElements(stream, count, 2); err != nil {
+		return err
+	}
+	this.Entries = make([]SrsAmf3DictionaryEntry, 0, count)
+	for i := uint32(0); i < count; i++ {
+		key, err := ctx.ReadAny(stream)
+		if err != nil {
+			return err
+		}
+
+		value, err := ctx.ReadAny(stream)
+		if err != nil {
+			return err
+		}
+		this.Entries = append(this.Entries, SrsAmf3DictionaryEntry{Key: key, Value: value})
+	}
+	return nil
+}
+
+func (this *SrsAmf3Dictionary) Encode(ctx *SrsAmf3Context, stream *utils.SrsStream) error {
+	isRef, err := ctx.writeComplexHeader(stream, RTMP_AMF3_Dictionary, this)
+	if err != nil || isRef {
+		return err
+	}
+
+	if err := ctx.WriteU29(stream, uint32(len(this.Entries))<<1|0x01); err != nil {
+		return err
+	}
+	stream.WriteBool(this.WeakKeys)
+
+	for i := 0; i < len(this.Entries); i++ {
+		if err := ctx.WriteAny(stream, this.Entries[i].Key); err != nil {
+			return err
+		}
+
+		if err := ctx.WriteAny(stream, this.Entries[i].Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (this *SrsAmf3Dictionary) IsMyType(stream *utils.SrsStream) (bool, error) {
+	return isMyMarker(stream, RTMP_AMF3_Dictionary)
+}
+
+func (this *SrsAmf3Dictionary) GetValue() interface{} {
+	return this
+}
@@ -0,0 +1,287 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2019 GOSRS(gosrs)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package amf3
+
+import (
+	"errors"
+	"go_srs/srs/utils"
+)
+
+/**
+* the traits of object, which describe the class name and the sealed members,
+* the traits maybe sent by reference when the object of same class is sent again.
+ */
+type SrsAmf3Traits struct {
+	ClassName      string
+	Dynamic        bool
+	Externalizable bool
+	Members        []string
+}
+
+func NewSrsAmf3Traits(className string, dynamic bool) *SrsAmf3Traits {
+	return &SrsAmf3Traits{
+		ClassName: className,
+		Dynamic:   dynamic,
+		Members:   make([]string, 0),
+	}
+}
+
+// the externalizable classes we can decode, whose body is a single amf3 value.
+var srsAmf3KnownExternals = map[string]bool{
+	"flex.messaging.io.ArrayCollection": true,
+	"flex.messaging.io.ObjectProxy":     true,
+}
+
+/**
+* the amf3 object, the sealed values are matched to the traits members by index,
+* the dynamic values are name-value pairs terminated by the empty string.
+ */
+type SrsAmf3Object struct {
+	Traits   *SrsAmf3Traits
+	Sealed   []ISrsAmf3Any
+	Dynamic  []SrsAmf3ValuePair
+	External ISrsAmf3Any
+}
+
+// create the anonymous dynamic object.
+func NewSrsAmf3Object() *SrsAmf3Object {
+	return &SrsAmf3Object{
+		Traits:  NewSrsAmf3Traits("", true),
+		Sealed:  make([]ISrsAmf3Any, 0),
+		Dynamic: make([]SrsAmf3ValuePair, 0),
+	}
+}
+
+// set the property, the sealed member is updated if exists, otherwise set the dynamic member.
+func (this *SrsAmf3Object) Set(name string, value ISrsAmf3Any) {
+	if this.Traits != nil {
+		for i := 0; i < len(this.Traits.Members) && i < len(this.Sealed); i++ {
+			if this.Traits.Members[i] == name {
+				this.Sealed[i] = value
+				return
+			}
+		}
+	}
+
+	for i := 0; i < len(this.Dynamic); i++ {
+		if this.Dynamic[i].Name == name {
+			this.Dynamic[i].Value = value
+			return
+		}
+	}
+	this.Dynamic = append(this.Dynamic, SrsAmf3ValuePair{Name: name, Value: value})
+}
+
+func (this *SrsAmf3Object) Get(name string) ISrsAmf3Any {
+	if this.Traits != nil {
+		for i := 0; i < len(this.Traits.Members) && i < len(this.Sealed); i++ {
+			if this.Traits.Members[i] == name {
+				return this.Sealed[i]
+			}
+		}
+	}
+
+	for i := 0; i < len(this.Dynamic); i++ {
+		if this.Dynamic[i].Name == name {
+			return this.Dynamic[i].Value
+		}
+	}
+	return nil
+}
+
+/**
+* read the traits by the U29O-traits, the low bit of object U29 is already shifted out.
+* bit0 0: traits reference, the left is the index of traits table.
+* bit0 1: traits inline, bit1 is externalizable, bit2 is dynamic and the left is member count.
+ */
+func (this *SrsAmf3Object) readTraits(ctx *SrsAmf3Context, stream *utils.SrsStream, u29 uint32) (*SrsAmf3Traits, error) {
+	if u29&0x01 == 0 {
+		return ctx.getTraits(u29 >> 1)
+	}
+
+	className, err := ctx.ReadUtf8Vr(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	traits := NewSrsAmf3Traits(className, false)
+	if u29&0x02 != 0 {
+		traits.Externalizable = true
+		ctx.addTraits(traits)
+		return traits, nil
+	}
+
+	traits.Dynamic = u29&0x04 != 0
+	count := u29 >> 3
+	for i := uint32(0); i < count; i++ {
+		member, err := ctx.ReadUtf8Vr(stream)
+		if err != nil {
+			return nil, err
+		}
+		traits.Members = append(traits.Members, member)
+	}
+
+	ctx.addTraits(traits)
+	return traits, nil
+}
+
+func (this *SrsAmf3Object) Decode(ctx *SrsAmf3Context, stream *utils.SrsStream) error {
+	ref, u29, err := ctx.readComplexHeader(stream, RTMP_AMF3_Object, this)
+	if err != nil {
+		return err
+	}
+
+	if ref != nil {
+		v, ok := ref.(*SrsAmf3Object)
+		if !ok {
+			return errors.New("amf3 object reference type mismatch.")
+		}
+		*this = *v
+		return nil
+	}
+
+	if this.Traits, err = this.readTraits(ctx, stream, u29); err != nil {
+		return err
+	}
+
+	if this.Traits.Externalizable {
+		if !srsAmf3KnownExternals[this.Traits.ClassName] {
+			return errors.New("amf3 unsupported externalizable object.")
+		}
+		this.External, err = ctx.ReadAny(stream)
+		return err
+	}
+
+	this.Sealed = make([]ISrsAmf3Any, 0, len(this.Traits.Members))
+	for i := 0; i < len(this.Traits.Members); i++ {
+		value, err := ctx.ReadAny(stream)
+		if err != nil {
+			return err
+		}
+		this.Sealed = append(this.Sealed, value)
+	}
+
+	if !this.Traits.Dynamic {
+		return nil
+	}
+
+	for {
+		name, err := ctx.ReadUtf8Vr(stream)
+		if err != nil {
+			return err
+		}
+
+		if name == "" {
+			break
+		}
+
+		value, err := ctx.ReadAny(stream)
+		if err != nil {
+			return err
+		}
+		this.Dynamic = append(this.Dynamic, SrsAmf3ValuePair{Name: name, Value: value})
+	}
+	return nil
+}
+
+func (this *SrsAmf3Object) Encode(ctx *SrsAmf3Context, stream *utils.SrsStream) error {
+	isRef, err := ctx.writeComplexHeader(stream, RTMP_AMF3_Object, this)
+	if err != nil || isRef {
+		return err
+	}
+
+	traits := this.Traits
+	if traits == nil {
+		traits = NewSrsAmf3Traits("", true)
+	}
+
+	if index, ok := ctx.traitsRefs[traits]; ok {
+		if err := ctx.WriteU29(stream, uint32(index)<<2|0x01); err != nil {
+			return err
+		}
+	} else {
+		ctx.addTraits(traits)
+		if traits.Externalizable {
+			err = ctx.WriteU29(stream, 0x07)
+		} else {
+			var dynamic uint32 = 0
+			if traits.Dynamic {
+				dynamic = 1
+			}
+			err = ctx.WriteU29(stream, uint32(len(traits.Members))<<4|dynamic<<3|0x03)
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.WriteUtf8Vr(stream, traits.ClassName); err != nil {
+			return err
+		}
+
+		if !traits.Externalizable {
+			for i := 0; i < len(traits.Members); i++ {
+				if err := ctx.WriteUtf8Vr(stream, traits.Members[i]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if traits.Externalizable {
+		return ctx.WriteAny(stream, this.External)
+	}
+
+	for i := 0; i < len(traits.Members); i++ {
+		var value ISrsAmf3Any
+		if i < len(this.Sealed) {
+			value = this.Sealed[i]
+		}
+
+		if err := ctx.WriteAny(stream, value); err != nil {
+			return err
+		}
+	}
+
+	if !traits.Dynamic {
+		return nil
+	}
+
+	for i := 0; i < len(this.Dynamic); i++ {
+		if err := ctx.WriteUtf8Vr(stream, this.Dynamic[i].Name); err != nil {
+			return err
+		}
+
+		if err := ctx.WriteAny(stream, this.Dynamic[i].Value); err != nil {
+			return err
+		}
+	}
+	return ctx.WriteUtf8Vr(stream, "")
+}
+
+func (this *SrsAmf3Object) IsMyType(stream *utils.SrsStream) (bool, error) {
+	return isMyMarker(stream, RTMP_AMF3_Object)
+}
+
+func (this *SrsAmf3Object) GetValue() interface{} {
+	return this
+}
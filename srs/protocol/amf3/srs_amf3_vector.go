@@ -0,0 +1,297 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2019 GOSRS(gosrs)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package amf3
+
+import (
+	"encoding/binary"
+	"errors"
+	"go_srs/srs/utils"
+)
+
+/**
+* the vector of int, uint, double and object,
+* the U29 is the count of items, then the fixed-vector flag byte,
+* the int/uint is 4bytes and double is 8bytes, the object vector has the type name.
+ */
+type SrsAmf3VectorInt struct {
+	Fixed bool
+	Value []int32
+}
+
+func (this *SrsAmf3VectorInt) Decode(ctx *SrsAmf3Context, stream *utils.SrsStream) error {
+	ref, count, err := ctx.readComplexHeader(stream, RTMP_AMF3_VectorInt, this)
+	if err != nil {
+		return err
+	}
+
+	if ref != nil {
+		v, ok := ref.(*SrsAmf3VectorInt)
+		if !ok {
+			return errors.New("amf3 vector int reference type mismatch.")
+		}
+		*this = *v
+		return nil
+	}
+
+	if this.Fixed, err = stream.ReadBool(); err != nil {
+		return err
+	}
+
+	if err = requireElements(stream, count, 4); err != nil {
+		return err
+	}
+	this.Value = make([]int32, 0, count)
+	for i := uint32(0); i < count; i++ {
+		v, err := stream.ReadInt32(binary.BigEndian)
+		if err != nil {
+			return err
+		}
+		this.Value = append(this.Value, v)
+	}
+	return nil
+}
+
+func (this *SrsAmf3VectorInt) Encode(ctx *SrsAmf3Context, stream *utils.SrsStream) error {
+	isRef, err := ctx.writeComplexHeader(stream, RTMP_AMF3_VectorInt, this)
+	if err != nil || isRef {
+		return err
+	}
+
+	if err := ctx.WriteU29(stream, uint32(len(this.Value))<<1|0x01); err != nil {
+		return err
+	}
+	stream.WriteBool(this.Fixed)
+
+	for i := 0; i < len(this.Value); i++ {
+		stream.WriteInt32(this.Value[i], binary.BigEndian)
+	}
+	return nil
+}
+
+func (this *SrsAmf3VectorInt) IsMyType(stream *utils.SrsStream) (bool, error) {
+	return isMyMarker(stream, RTMP_AMF3_VectorInt)
+}
+
+func (this *SrsAmf3VectorInt) GetValue() interface{} {
+	return this.Value
+}
+
+type SrsAmf3VectorUInt struct {
+	Fixed bool
+	Value []uint32
+}
+
+func (this *SrsAmf3VectorUInt) Decode(ctx *SrsAmf3Context, stream *utils.SrsStream) error {
+	ref, count, err := ctx.readComplexHeader(stream, RTMP_AMF3_VectorUInt, this)
+	if err != nil {
+		return err
+	}
+
+	if ref != nil {
+		v, ok := ref.(*SrsAmf3VectorUInt)
+		if !ok {
+			return errors.New("amf3 vector uint reference type mismatch.")
+		}
+		*this = *v
+		return nil
+	}
+
+	if this.Fixed, err = stream.ReadBool(); err != nil {
+		return err
+	}
+
+	if err = requireElements(stream, count, 4); err != nil {
+		return err
+	}
+	this.Value = make([]uint32, 0, count)
+	for i := uint32(0); i < count; i++ {
+		v, err := stream.ReadInt32(binary.BigEndian)
+		if err != nil {
+			return err
+		}
+		this.Value = append(this.Value, uint32(v))
+	}
+	return nil
+}
+
+func (this *SrsAmf3VectorUInt) Encode(ctx *SrsAmf3Context, stream *utils.SrsStream) error {
+	isRef, err := ctx.writeComplexHeader(stream, RTMP_AMF3_VectorUInt, this)
+	if err != nil || isRef {
+		return err
+	}
+
+	if err := ctx.WriteU29(stream, uint32(len(this.Value))<<1|0x01); err != nil {
+		return err
+	}
+	stream.WriteBool(this.Fixed)
+
+	for i := 0; i < len(this.Value); i++ {
+		stream.WriteInt32(int32(this.Value[i]), binary.BigEndian)
+	}
+	return nil
+}
+
+func (this *SrsAmf3VectorUInt) IsMyType(stream *utils.SrsStream) (bool, error) {
+	return isMyMarker(stream, RTMP_AMF3_VectorUInt)
+}
+
+func (this *SrsAmf3VectorUInt) GetValue() interface{} {
+	return this.Value
+}
+
+type SrsAmf3VectorDouble struct {
+	Fixed bool
+	Value []float64
+}
+
+func (this *SrsAmf3VectorDouble) Decode(ctx *SrsAmf3Context, stream *utils.SrsStream) error {
+	ref, count, err := ctx.readComplexHeader(stream, RTMP_AMF3_VectorDouble, this)
+	if err != nil {
+		return err
+	}
+
+	if ref != nil {
+		v, ok := ref.(*SrsAmf3VectorDouble)
+		if !ok {
+			return errors.New("amf3 vector double reference type mismatch.")
+		}
+		*this = *v
+		return nil
+	}
+
+	if this.Fixed, err = stream.ReadBool(); err != nil {
+		return err
+	}
+
+	if err = requireElements(stream, count, 8); err != nil {
+		return err
+	}
+	this.Value = make([]float64, 0, count)
+	for i := uint32(0); i < count; i++ {
+		v, err := stream.ReadFloat64(binary.BigEndian)
+		if err != nil {
+			return err
+		}
+		this.Value = append(this.Value, v)
+	}
+	return nil
+}
+
+func (this *SrsAmf3VectorDouble) Encode(ctx *SrsAmf3Context, stream *utils.SrsStream) error {
+	isRef, err := ctx.writeComplexHeader(stream, RTMP_AMF3_VectorDouble, this)
+	if err != nil || isRef {
+		return err
+	}
+
+	if err := ctx.WriteU29(stream, uint32(len(this.Value))<<1|0x01); err != nil {
+		return err
+	}
+	stream.WriteBool(this.Fixed)
+
+	for i := 0; i < len(this.Value); i++ {
+		stream.WriteFloat64(this.Value[i], binary.BigEndian)
+	}
+	return nil
+}
+
+func (this *SrsAmf3VectorDouble) IsMyType(stream *utils.SrsStream) (bool, error) {
+	return isMyMarker(stream, RTMP_AMF3_VectorDouble)
+}
+
+func (this *SrsAmf3VectorDouble) GetValue() interface{} {
+	return this.Value
+}
+
+type SrsAmf3VectorObject struct {
+	Fixed    bool
+	TypeName string
+	Value    []ISrsAmf3Any
+}
+
+func (this *SrsAmf3VectorObject) Decode(ctx *SrsAmf3Context, stream *utils.SrsStream) error {
+	ref, count, err := ctx.readComplexHeader(stream, RTMP_AMF3_VectorObject, this)
+	if err != nil {
+		return err
+	}
+
+	if ref != nil {
+		v, ok := ref.(*SrsAmf3VectorObject)
+		if !ok {
+			return errors.New("amf3 vector object reference type mismatch.")
+		}
+		*this = *v
+		return nil
+	}
+
+	if this.Fixed, err = stream.ReadBool(); err != nil {
+		return err
+	}
+
+	if this.TypeName, err = ctx.ReadUtf8Vr(stream); err != nil {
+		return err
+	}
+
+	if err = requireElements(stream, count, 1); err != nil {
+		return err
+	}
+	this.Value = make([]ISrsAmf3Any, 0, count)
+	for i := uint32(0); i < count; i++ {
+		v, err := ctx.ReadAny(stream)
+		if err != nil {
+			return err
+		}
+		this.Value = append(this.Value, v)
+	}
+	return nil
+}
+
+func (this *SrsAmf3VectorObject) Encode(ctx *SrsAmf3Context, stream *utils.SrsStream) error {
+	isRef, err := ctx.writeComplexHeader(stream, RTMP_AMF3_VectorObject, this)
+	if err != nil || isRef {
+		return err
+	}
+
+	if err := ctx.WriteU29(stream, uint32(len(this.Value))<<1|0x01); err != nil {
+		return err
+	}
+	stream.WriteBool(this.Fixed)
+
+	if err := ctx.WriteUtf8Vr(stream, this.TypeName); err != nil {
+		return err
+	}
+
+	for i := 0; i < len(this.Value); i++ {
+		if err := ctx.WriteAny(stream, this.Value[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (this *SrsAmf3VectorObject) IsMyType(stream *utils.SrsStream) (bool, error) {
+	return isMyMarker(stream, RTMP_AMF3_VectorObject)
+}
+
+func (this *SrsAmf3VectorObject) GetValue() interface{} {
+	return this.Value
+}
@@ -0,0 +1,99 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2019 GOSRS(gosrs)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package amf3
+
+import (
+	"errors"
+	"go_srs/srs/utils"
+)
+
+/**
+* the xml and xml document, the marker is RTMP_AMF3_XmlDocument(0x07) or RTMP_AMF3_Xml(0x0B),
+* both are encoded as the complex type, the U29 is the length of utf8 string.
+ */
+type SrsAmf3Xml struct {
+	Marker byte
+	Value  string
+}
+
+func NewSrsAmf3Xml(marker byte, str string) *SrsAmf3Xml {
+	return &SrsAmf3Xml{
+		Marker: marker,
+		Value:  str,
+	}
+}
+
+func (this *SrsAmf3Xml) Decode(ctx *SrsAmf3Context, stream *utils.SrsStream) error {
+	marker, err := stream.PeekByte()
+	if err != nil {
+		return err
+	}
+
+	if marker != RTMP_AMF3_XmlDocument && marker != RTMP_AMF3_Xml {
+		return errors.New("amf3 check xml marker failed.")
+	}
+	this.Marker = marker
+
+	ref, length, err := ctx.readComplexHeader(stream, marker, this)
+	if err != nil {
+		return err
+	}
+
+	if ref != nil {
+		v, ok := ref.(*SrsAmf3Xml)
+		if !ok {
+			return errors.New("amf3 xml reference type mismatch.")
+		}
+		*this = *v
+		return nil
+	}
+
+	this.Value, err = stream.ReadString(length)
+	return err
+}
+
+func (this *SrsAmf3Xml) Encode(ctx *SrsAmf3Context, stream *utils.SrsStream) error {
+	marker := this.Marker
+	if marker != RTMP_AMF3_XmlDocument {
+		marker = RTMP_AMF3_Xml
+	}
+
+	isRef, err := ctx.writeComplexHeader(stream, marker, this)
+	if err != nil || isRef {
+		return err
+	}
+
+	if err := ctx.WriteU29(stream, uint32(len(this.Value))<<1|0x01); err != nil {
+		return err
+	}
+	stream.WriteString(this.Value)
+	return nil
+}
+
+func (this *SrsAmf3Xml) IsMyType(stream *utils.SrsStream) (bool, error) {
+	return isMyMarker(stream, RTMP_AMF3_XmlDocument, RTMP_AMF3_Xml)
+}
+
+func (this *SrsAmf3Xml) GetValue() interface{} {
+	return this.Value
+}
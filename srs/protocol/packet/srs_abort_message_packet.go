@@ -0,0 +1,65 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2019 GOSRS(gosrs)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package packet
+
+import (
+	"encoding/binary"
+	"go_srs/srs/global"
+	"go_srs/srs/utils"
+)
+
+/**
+* 5.2. Abort Message (2)
+* Protocol control message 2, Abort Message, is used to notify the peer
+* if it is waiting for chunks to complete a message, then to discard
+* the partially received message over a chunk stream.
+ */
+type SrsAbortMessagePacket struct {
+	/**
+	 * This field holds the chunk stream ID, whose current message is to be discarded.
+	 */
+	ChunkStreamId uint32
+}
+
+func NewSrsAbortMessagePacket() *SrsAbortMessagePacket {
+	return &SrsAbortMessagePacket{}
+}
+
+func (this *SrsAbortMessagePacket) GetMessageType() int8 {
+	return global.RTMP_MSG_AbortMessage
+}
+
+func (this *SrsAbortMessagePacket) GetPreferCid() int32 {
+	return global.RTMP_CID_ProtocolControl
+}
+
+func (this *SrsAbortMessagePacket) Decode(stream *utils.SrsStream) error {
+	n, err := stream.ReadInt32(binary.BigEndian)
+	this.ChunkStreamId = uint32(n)
+	return err
+}
+
+func (this *SrsAbortMessagePacket) Encode(stream *utils.SrsStream) error {
+	stream.WriteInt32(int32(this.ChunkStreamId), binary.BigEndian)
+	return nil
+}
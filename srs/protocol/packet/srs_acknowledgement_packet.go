@@ -0,0 +1,64 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2019 GOSRS(gosrs)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package packet
+
+import (
+	"encoding/binary"
+	"go_srs/srs/global"
+	"go_srs/srs/utils"
+)
+
+/**
+* 5.3. Acknowledgement (3)
+* The client or the server sends the acknowledgment to the peer after
+* receiving bytes equal to the window size.
+ */
+type SrsAcknowledgementPacket struct {
+	/**
+	 * This field holds the number of bytes received so far.
+	 */
+	SequenceNumber uint32
+}
+
+func NewSrsAcknowledgementPacket() *SrsAcknowledgementPacket {
+	return &SrsAcknowledgementPacket{}
+}
+
+func (this *SrsAcknowledgementPacket) GetMessageType() int8 {
+	return global.RTMP_MSG_Acknowledgement
+}
+
+func (this *SrsAcknowledgementPacket) GetPreferCid() int32 {
+	return global.RTMP_CID_ProtocolControl
+}
+
+func (this *SrsAcknowledgementPacket) Decode(stream *utils.SrsStream) error {
+	n, err := stream.ReadInt32(binary.BigEndian)
+	this.SequenceNumber = uint32(n)
+	return err
+}
+
+func (this *SrsAcknowledgementPacket) Encode(stream *utils.SrsStream) error {
+	stream.WriteInt32(int32(this.SequenceNumber), binary.BigEndian)
+	return nil
+}
@@ -0,0 +1,169 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2019 GOSRS(gosrs)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package packet
+
+import (
+	"errors"
+	"go_srs/srs/global"
+	"go_srs/srs/protocol/amf0"
+	"go_srs/srs/utils"
+)
+
+/**
+* the generic call packet, for the command not decoded as specified packet,
+* for example, the NetConnection.call("myCall", responder, args) of flash.
+* @remark the CommandObject and Arguments are nil if not present.
+ */
+type SrsCallPacket struct {
+	CommandName   amf0.SrsAmf0String
+	TransactionId amf0.SrsAmf0Number
+	// the command object, generally null.
+	CommandObject amf0.ISrsAmf0Any
+	// the first argument of call, the others are ignored.
+	Arguments amf0.ISrsAmf0Any
+}
+
+func NewSrsCallPacket(command string) *SrsCallPacket {
+	return &SrsCallPacket{
+		CommandName:   amf0.SrsAmf0String{Value: amf0.SrsAmf0Utf8{Value: command}},
+		TransactionId: amf0.SrsAmf0Number{Value: 0},
+	}
+}
+
+func (this *SrsCallPacket) GetMessageType() int8 {
+	return global.RTMP_MSG_AMF0CommandMessage
+}
+
+func (this *SrsCallPacket) GetPreferCid() int32 {
+	return global.RTMP_CID_OverConnection
+}
+
+// the command name is decoded by protocol.
+func (this *SrsCallPacket) Decode(stream *utils.SrsStream) error {
+	var err error
+	if err = this.TransactionId.Decode(stream); err != nil {
+		return err
+	}
+
+	if this.CommandObject, err = decodeAmf0Any(stream); err != nil {
+		return err
+	}
+
+	if this.Arguments, err = decodeAmf0Any(stream); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (this *SrsCallPacket) Encode(stream *utils.SrsStream) error {
+	_ = this.CommandName.Encode(stream)
+	_ = this.TransactionId.Encode(stream)
+	if this.CommandObject != nil {
+		_ = this.CommandObject.Encode(stream)
+	}
+	if this.Arguments != nil {
+		_ = this.Arguments.Encode(stream)
+	}
+	return nil
+}
+
+/**
+* the response of call, the _result or _error with the same transaction id.
+ */
+type SrsCallResPacket struct {
+	CommandName   amf0.SrsAmf0String
+	TransactionId amf0.SrsAmf0Number
+	// the command object, null if not specified.
+	CommandObject amf0.ISrsAmf0Any
+	// the response, not encoded if nil.
+	Response amf0.ISrsAmf0Any
+}
+
+func NewSrsCallResPacket(trans_id float64) *SrsCallResPacket {
+	return &SrsCallResPacket{
+		CommandName:   amf0.SrsAmf0String{Value: amf0.SrsAmf0Utf8{Value: amf0.RTMP_AMF0_COMMAND_RESULT}},
+		TransactionId: amf0.SrsAmf0Number{Value: trans_id},
+		CommandObject: amf0.NewSrsAmf0Null(),
+	}
+}
+
+func (this *SrsCallResPacket) GetMessageType() int8 {
+	return global.RTMP_MSG_AMF0CommandMessage
+}
+
+func (this *SrsCallResPacket) GetPreferCid() int32 {
+	return global.RTMP_CID_OverConnection
+}
+
+// the command name is decoded by protocol.
+func (this *SrsCallResPacket) Decode(stream *utils.SrsStream) error {
+	var err error
+	if err = this.TransactionId.Decode(stream); err != nil {
+		return err
+	}
+
+	if this.CommandObject, err = decodeAmf0Any(stream); err != nil {
+		return err
+	}
+
+	if this.Response, err = decodeAmf0Any(stream); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (this *SrsCallResPacket) Encode(stream *utils.SrsStream) error {
+	_ = this.CommandName.Encode(stream)
+	_ = this.TransactionId.Encode(stream)
+	if this.CommandObject != nil {
+		_ = this.CommandObject.Encode(stream)
+	} else {
+		_ = amf0.NewSrsAmf0Null().Encode(stream)
+	}
+	if this.Response != nil {
+		_ = this.Response.Encode(stream)
+	}
+	return nil
+}
+
+// decode the optional amf0 value, nil if no more data.
+func decodeAmf0Any(stream *utils.SrsStream) (amf0.ISrsAmf0Any, error) {
+	if stream.Empty() {
+		return nil, nil
+	}
+
+	marker, err := stream.PeekByte()
+	if err != nil {
+		return nil, err
+	}
+
+	value := amf0.GenerateSrsAmf0Any(marker)
+	if value == nil {
+		return nil, errors.New("amf0 invalid marker of call.")
+	}
+
+	if err = value.Decode(stream); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
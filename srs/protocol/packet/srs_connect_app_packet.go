@@ -72,6 +72,7 @@ func (this *SrsConnectAppPacket) Decode(stream *utils.SrsStream) error {
 	}
 
 	if !stream.Empty() {
+		this.Args = amf0.NewSrsAmf0Object()
 		err = this.Args.Decode(stream)
 		if err != nil {
 			return err
@@ -90,3 +91,21 @@ func (this *SrsConnectAppPacket) Encode(stream *utils.SrsStream) error {
 	}
 	return nil
 }
+
+/**
+* the typed command object of connect, which is converted from or to
+* the CommandObj by amf0.UnmarshalAny or amf0.MarshalAny.
+ */
+type SrsConnectAppCommandObject struct {
+	App            string  `amf0:"app"`
+	FlashVer       string  `amf0:"flashVer"`
+	SwfUrl         string  `amf0:"swfUrl"`
+	TcUrl          string  `amf0:"tcUrl"`
+	Fpad           bool    `amf0:"fpad"`
+	Capabilities   float64 `amf0:"capabilities"`
+	AudioCodecs    float64 `amf0:"audioCodecs"`
+	VideoCodecs    float64 `amf0:"videoCodecs"`
+	VideoFunction  float64 `amf0:"videoFunction"`
+	PageUrl        string  `amf0:"pageUrl"`
+	ObjectEncoding float64 `amf0:"objectEncoding"`
+}
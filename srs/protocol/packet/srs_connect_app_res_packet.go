@@ -65,6 +65,10 @@ func (this *SrsConnectAppResPacket) Decode(stream *utils.SrsStream) error {
 		return err
 	}
 
+	if err = this.Props.Decode(stream); err != nil {
+		return err
+	}
+
 	if err = this.Info.Decode(stream); err != nil {
 		return err
 	}
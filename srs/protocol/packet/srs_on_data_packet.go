@@ -0,0 +1,77 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2019 GOSRS(gosrs)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package packet
+
+import (
+	"go_srs/srs/global"
+	"go_srs/srs/protocol/amf0"
+	"go_srs/srs/utils"
+)
+
+/**
+* the timed data message of stream, delivered to players with audio and video,
+* for example, the onTextData of captions and the onCuePoint of cue points.
+* @remark the @setDataFrame is unwrapped by protocol, the name is the wrapped one.
+ */
+type SrsOnDataPacket struct {
+	Name amf0.SrsAmf0String
+	// the values of data, generally an object.
+	Values []amf0.ISrsAmf0Any
+}
+
+func NewSrsOnDataPacket(name string) *SrsOnDataPacket {
+	return &SrsOnDataPacket{
+		Name:   amf0.SrsAmf0String{Value: amf0.SrsAmf0Utf8{Value: name}},
+		Values: make([]amf0.ISrsAmf0Any, 0),
+	}
+}
+
+func (this *SrsOnDataPacket) GetMessageType() int8 {
+	return global.RTMP_MSG_AMF0DataMessage
+}
+
+func (this *SrsOnDataPacket) GetPreferCid() int32 {
+	return global.RTMP_CID_OverConnection2
+}
+
+// the name is decoded by protocol.
+func (this *SrsOnDataPacket) Decode(stream *utils.SrsStream) error {
+	for !stream.Empty() {
+		value, err := decodeAmf0Any(stream)
+		if err != nil {
+			return err
+		}
+		this.Values = append(this.Values, value)
+	}
+	return nil
+}
+
+func (this *SrsOnDataPacket) Encode(stream *utils.SrsStream) error {
+	_ = this.Name.Encode(stream)
+	for i := 0; i < len(this.Values); i++ {
+		if err := this.Values[i].Encode(stream); err != nil {
+			return err
+		}
+	}
+	return nil
+}
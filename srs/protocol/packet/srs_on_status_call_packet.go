@@ -23,6 +23,7 @@ CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
 package packet
 
 import (
+	"errors"
 	"go_srs/srs/global"
 	"go_srs/srs/protocol/amf0"
 	"go_srs/srs/utils"
@@ -51,8 +52,28 @@ func (this *SrsOnStatusCallPacket) GetPreferCid() int32 {
 	return global.RTMP_CID_OverStream
 }
 
-func (p *SrsOnStatusCallPacket) Decode(stream *utils.SrsStream) error {
-	return nil
+func (this *SrsOnStatusCallPacket) Decode(stream *utils.SrsStream) error {
+	if err := this.TransactionId.Decode(stream); err != nil {
+		return err
+	}
+
+	// the command object is null or object, ignore it.
+	marker, err := stream.PeekByte()
+	if err != nil {
+		return err
+	}
+
+	args := amf0.GenerateSrsAmf0Any(marker)
+	if args == nil {
+		return errors.New("amf0 decode onStatus command object failed.")
+	}
+
+	if err = args.Decode(stream); err != nil {
+		return err
+	}
+
+	this.Data = amf0.NewSrsAmf0Object()
+	return this.Data.Decode(stream)
 }
 
 func (this *SrsOnStatusCallPacket) Encode(stream *utils.SrsStream) error {
@@ -48,8 +48,9 @@ func (this *SrsOnStatusDataPacket) GetPreferCid() int32 {
 	return global.RTMP_CID_OverStream
 }
 
-func (p *SrsOnStatusDataPacket) Decode(stream *utils.SrsStream) error {
-	return nil
+// the command name is decoded by protocol.
+func (this *SrsOnStatusDataPacket) Decode(stream *utils.SrsStream) error {
+	return this.Data.Decode(stream)
 }
 
 func (this *SrsOnStatusDataPacket) Encode(stream *utils.SrsStream) error {
@@ -36,6 +36,13 @@ type SrsPausePacket struct {
 	TimeMs        amf0.SrsAmf0Number
 }
 
+func NewSrsPausePacket() *SrsPausePacket {
+	return &SrsPausePacket{
+		CommandName:   amf0.SrsAmf0String{Value: amf0.SrsAmf0Utf8{Value: amf0.RTMP_AMF0_COMMAND_PAUSE}},
+		TransactionId: amf0.SrsAmf0Number{Value: 0},
+	}
+}
+
 func (s *SrsPausePacket) GetMessageType() int8 {
 	return global.RTMP_MSG_AMF0CommandMessage
 }
@@ -66,5 +73,10 @@ func (this *SrsPausePacket) Decode(stream *utils.SrsStream) error {
 }
 
 func (this *SrsPausePacket) Encode(stream *utils.SrsStream) error {
+	_ = this.CommandName.Encode(stream)
+	_ = this.TransactionId.Encode(stream)
+	_ = this.NullObj.Encode(stream)
+	_ = this.IsPause.Encode(stream)
+	_ = this.TimeMs.Encode(stream)
 	return nil
 }
@@ -69,13 +69,38 @@ func (this *SrsPlayPacket) Decode(stream *utils.SrsStream) error {
 		return err
 	}
 
-	if len(this.StreamName.GetValue().(string)) > 0 {
+	// the start, duration and reset are optional.
+	if !stream.Empty() {
 		if err := this.Start.Decode(stream); err != nil {
 			return err
 		}
 	}
-	//todo fix this
-	return nil
+
+	if !stream.Empty() {
+		if err := this.Duration.Decode(stream); err != nil {
+			return err
+		}
+	}
+
+	if stream.Empty() {
+		return nil
+	}
+
+	// the reset maybe boolean or number, reset when number is not 0.
+	marker, err := stream.PeekByte()
+	if err != nil {
+		return err
+	}
+
+	if marker == amf0.RTMP_AMF0_Number {
+		var reset amf0.SrsAmf0Number
+		if err := reset.Decode(stream); err != nil {
+			return err
+		}
+		this.Reset.Value = reset.Value != 0
+		return nil
+	}
+	return this.Reset.Decode(stream)
 }
 
 func (this *SrsPlayPacket) Encode(stream *utils.SrsStream) error {
@@ -59,7 +59,13 @@ func (this *SrsSetPeerBandwidthPacket) GetPreferCid() int32 {
 }
 
 func (this *SrsSetPeerBandwidthPacket) Decode(stream *utils.SrsStream) error {
-	return nil
+	var err error
+	if this.Bandwidth, err = stream.ReadInt32(binary.BigEndian); err != nil {
+		return err
+	}
+
+	this.Type, err = stream.ReadInt8()
+	return err
 }
 
 func (this *SrsSetPeerBandwidthPacket) Encode(stream *utils.SrsStream) error {
@@ -46,7 +46,7 @@ func (this *SrsSetWindowAckSizePacket) GetPreferCid() int32 {
 
 func (this *SrsSetWindowAckSizePacket) Decode(stream *utils.SrsStream) error {
 	var err error
-	this.AckowledgementWindowSize, err = stream.ReadInt32(binary.LittleEndian)
+	this.AckowledgementWindowSize, err = stream.ReadInt32(binary.BigEndian)
 	return err
 }
 
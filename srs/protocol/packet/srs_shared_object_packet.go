@@ -0,0 +1,240 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2019 GOSRS(gosrs)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package packet
+
+import (
+	"encoding/binary"
+	"errors"
+	"go_srs/srs/global"
+	"go_srs/srs/protocol/amf0"
+	"go_srs/srs/utils"
+)
+
+/**
+* the event of shared object message, the data of event depends on the type:
+*     use, release, clear, use success: no data.
+*     request change, change: the name and value of property.
+*     success, remove, request remove: the name of property.
+*     send message: the amf0 values, the method name and the args.
+*     status: the code and level, the utf8 strings.
+* @remark the request change with multiple properties is decoded to multiple events.
+ */
+type SrsSharedObjectEvent struct {
+	Type  byte
+	Name  string
+	Value amf0.ISrsAmf0Any
+	// the method name and args of send message.
+	Args []amf0.ISrsAmf0Any
+	// the code and level of status.
+	Code  string
+	Level string
+}
+
+func NewSrsSharedObjectEvent(typ byte) *SrsSharedObjectEvent {
+	return &SrsSharedObjectEvent{
+		Type: typ,
+	}
+}
+
+func (this *SrsSharedObjectEvent) decode(stream *utils.SrsStream) ([]*SrsSharedObjectEvent, error) {
+	var err error
+	switch this.Type {
+	case global.RTMP_SO_RequestChange, global.RTMP_SO_Change:
+		events := make([]*SrsSharedObjectEvent, 0, 1)
+		for !stream.Empty() {
+			event := NewSrsSharedObjectEvent(this.Type)
+			var name amf0.SrsAmf0Utf8
+			if err = name.Decode(stream); err != nil {
+				return nil, err
+			}
+			event.Name = name.Value
+
+			if event.Value, err = decodeAmf0Any(stream); err != nil {
+				return nil, err
+			}
+			if event.Value == nil {
+				return nil, errors.New("shared object change without value")
+			}
+			events = append(events, event)
+		}
+		return events, nil
+	case global.RTMP_SO_Success, global.RTMP_SO_Remove, global.RTMP_SO_RequestRemove:
+		var name amf0.SrsAmf0Utf8
+		if err = name.Decode(stream); err != nil {
+			return nil, err
+		}
+		this.Name = name.Value
+	case global.RTMP_SO_SendMessage:
+		for !stream.Empty() {
+			var arg amf0.ISrsAmf0Any
+			if arg, err = decodeAmf0Any(stream); err != nil {
+				return nil, err
+			}
+			this.Args = append(this.Args, arg)
+		}
+	case global.RTMP_SO_Status:
+		var code, level amf0.SrsAmf0Utf8
+		if err = code.Decode(stream); err != nil {
+			return nil, err
+		}
+		if err = level.Decode(stream); err != nil {
+			return nil, err
+		}
+		this.Code, this.Level = code.Value, level.Value
+	}
+	return []*SrsSharedObjectEvent{this}, nil
+}
+
+func (this *SrsSharedObjectEvent) encode(stream *utils.SrsStream) error {
+	switch this.Type {
+	case global.RTMP_SO_RequestChange, global.RTMP_SO_Change:
+		_ = amf0.NewSrsAmf0Utf8(this.Name).Encode(stream)
+		if this.Value == nil {
+			return errors.New("shared object change without value")
+		}
+		return this.Value.Encode(stream)
+	case global.RTMP_SO_Success, global.RTMP_SO_Remove, global.RTMP_SO_RequestRemove:
+		return amf0.NewSrsAmf0Utf8(this.Name).Encode(stream)
+	case global.RTMP_SO_SendMessage:
+		for i := 0; i < len(this.Args); i++ {
+			if err := this.Args[i].Encode(stream); err != nil {
+				return err
+			}
+		}
+	case global.RTMP_SO_Status:
+		_ = amf0.NewSrsAmf0Utf8(this.Code).Encode(stream)
+		_ = amf0.NewSrsAmf0Utf8(this.Level).Encode(stream)
+	}
+	return nil
+}
+
+/**
+* 3.3. Shared object message
+* the name of shared object, the version, the 8bytes flags, then the events,
+* each event is 1byte type, 4bytes length and the data.
+ */
+type SrsSharedObjectPacket struct {
+	Name string
+	// the version of shared object, increased when changed by server.
+	Version uint32
+	// whether the shared object is persistent, the flags is 2 if persistent.
+	Persistent bool
+	Events     []*SrsSharedObjectEvent
+}
+
+func NewSrsSharedObjectPacket(name string, version uint32, persistent bool) *SrsSharedObjectPacket {
+	return &SrsSharedObjectPacket{
+		Name:       name,
+		Version:    version,
+		Persistent: persistent,
+		Events:     make([]*SrsSharedObjectEvent, 0),
+	}
+}
+
+func (this *SrsSharedObjectPacket) GetMessageType() int8 {
+	return global.RTMP_MSG_AMF0SharedObject
+}
+
+func (this *SrsSharedObjectPacket) GetPreferCid() int32 {
+	return global.RTMP_CID_OverConnection
+}
+
+func (this *SrsSharedObjectPacket) AddEvent(event *SrsSharedObjectEvent) {
+	this.Events = append(this.Events, event)
+}
+
+func (this *SrsSharedObjectPacket) Decode(stream *utils.SrsStream) error {
+	var err error
+	var name amf0.SrsAmf0Utf8
+	if err = name.Decode(stream); err != nil {
+		return err
+	}
+	this.Name = name.Value
+
+	var version, flags int32
+	if version, err = stream.ReadInt32(binary.BigEndian); err != nil {
+		return err
+	}
+	this.Version = uint32(version)
+
+	// 4bytes flags and 4bytes reserved.
+	if flags, err = stream.ReadInt32(binary.BigEndian); err != nil {
+		return err
+	}
+	this.Persistent = flags&global.RTMP_SO_FLAG_PERSISTENT != 0
+	if _, err = stream.ReadBytes(4); err != nil {
+		return err
+	}
+
+	for !stream.Empty() {
+		var typ byte
+		if typ, err = stream.ReadByte(); err != nil {
+			return err
+		}
+
+		var size int32
+		if size, err = stream.ReadInt32(binary.BigEndian); err != nil {
+			return err
+		}
+
+		if size < 0 {
+			return errors.New("shared object event size invalid")
+		}
+
+		var data []byte
+		if data, err = stream.ReadBytes(uint32(size)); err != nil {
+			return err
+		}
+
+		events, err := NewSrsSharedObjectEvent(typ).decode(utils.NewSrsStream(data))
+		if err != nil {
+			return err
+		}
+		this.Events = append(this.Events, events...)
+	}
+	return nil
+}
+
+func (this *SrsSharedObjectPacket) Encode(stream *utils.SrsStream) error {
+	_ = amf0.NewSrsAmf0Utf8(this.Name).Encode(stream)
+	stream.WriteInt32(int32(this.Version), binary.BigEndian)
+
+	var flags int32
+	if this.Persistent {
+		flags = global.RTMP_SO_FLAG_PERSISTENT
+	}
+	stream.WriteInt32(flags, binary.BigEndian)
+	stream.WriteInt32(0, binary.BigEndian)
+
+	for i := 0; i < len(this.Events); i++ {
+		data := utils.NewSrsStream([]byte{})
+		if err := this.Events[i].encode(data); err != nil {
+			return err
+		}
+
+		stream.WriteByte(this.Events[i].Type)
+		stream.WriteInt32(int32(len(data.Data())), binary.BigEndian)
+		stream.WriteBytes(data.Data())
+	}
+	return nil
+}
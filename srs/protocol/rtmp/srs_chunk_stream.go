@@ -48,6 +48,15 @@ type SrsChunkStream struct {
 	 * whether the chunk message header has extended timestamp.
 	 */
 	ExtendedTimestamp bool
+	/**
+	 * the last extended timestamp of chunk, the fmt=3 continue chunk may repeat it.
+	 */
+	extendedTimestamp uint32
+	/**
+	 * the 32bits timestamp of last message on the wire,
+	 * used to unwrap the message timestamp to 64bits.
+	 */
+	wireTimestamp uint32
 
 	MsgCount int32
 
@@ -76,6 +76,10 @@ func (this *SrsMessageHeader) SetTimestamp(t int64) {
 	this.timestamp = t
 }
 
+func (this *SrsMessageHeader) GetStreamId() int32 {
+	return this.streamId
+}
+
 func (this *SrsMessageHeader) Print() {
 }
 
@@ -103,6 +107,10 @@ func (s *SrsMessageHeader) IsAmf3Data() bool {
 	return s.messageType == global.RTMP_MSG_AMF3DataMessage
 }
 
+func (s *SrsMessageHeader) IsAmf0SharedObject() bool {
+	return s.messageType == global.RTMP_MSG_AMF0SharedObject
+}
+
 func (s *SrsMessageHeader) IsWindowAckledgementSize() bool {
 	return s.messageType == global.RTMP_MSG_WindowAcknowledgementSize
 }
@@ -115,6 +123,10 @@ func (s *SrsMessageHeader) IsSetChunkSize() bool {
 	return s.messageType == global.RTMP_MSG_SetChunkSize
 }
 
+func (s *SrsMessageHeader) IsAbortMessage() bool {
+	return s.messageType == global.RTMP_MSG_AbortMessage
+}
+
 func (s *SrsMessageHeader) IsUserControlMessage() bool {
 	return s.messageType == global.RTMP_MSG_UserControlMessage
 }
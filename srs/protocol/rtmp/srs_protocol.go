@@ -24,22 +24,33 @@ package rtmp
 
 import (
 	_ "bufio"
-	"bytes"
 	_ "context"
 	"encoding/binary"
 	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
 	"go_srs/srs/global"
 	"go_srs/srs/protocol/amf0"
+	"go_srs/srs/protocol/amf3"
 	"go_srs/srs/protocol/packet"
 	"go_srs/srs/protocol/skt"
 	"go_srs/srs/utils"
-	_ "log"
-	"reflect"
+	"net"
+	"sync"
 	"time"
 )
 
 const SRS_PERF_CHUNK_STREAM_CACHE = 16
 
+/**
+* when the bytes not acked exceed the bandwidth limited by peer, wait for the acknowledgement
+* in the timeout, and the window is allowed to exceed 1/slack for the different counting of peers.
+ */
+const (
+	SRS_RTMP_PEER_ACK_TIMEOUT  = 5 * time.Second
+	SRS_RTMP_PEER_WINDOW_SLACK = 4
+)
+
 type AckWindowSize struct {
 	Window         uint32
 	RecvBytes      int64
@@ -52,8 +63,55 @@ type SrsProtocol struct {
 	chunkStreams map[int32]*SrsChunkStream
 	inChunkSize  int32
 	OutChunkSize int32
-	OutAckSize   AckWindowSize
-	Requests     map[float64]string
+	/**
+	 * input ack size, when to send the acked packet.
+	 * the window is set by the peer, RecvBytes is the bytes when last acked.
+	 */
+	InAckSize AckWindowSize
+	/**
+	 * output ack size, the window we told the peer,
+	 * the SequenceNumber is the last acked bytes of peer.
+	 */
+	OutAckSize AckWindowSize
+	/**
+	 * the output bandwidth limited by the SetPeerBandwidth of peer, 0 for no limit,
+	 * the bytes sent and not acked by peer should not exceed it.
+	 */
+	peerBandwidth uint32
+	peerLimitType int8
+	// the peer never acks in time, its window is not honored any more.
+	peerAckDisabled bool
+	// guard the OutAckSize and peer bandwidth, which are updated by the recv goroutine.
+	ackMtx sync.Mutex
+	// closed and renewed when got the acknowledgement of peer.
+	ackNotify chan struct{}
+	Requests  map[float64]string
+	// the chunks of a message must not interlace with others.
+	sendMtx sync.Mutex
+	/**
+	 * the ping state, the timestamp of PingRequest is the ms since pingEpoch,
+	 * the rtt is updated when got the PingResponse of peer.
+	 */
+	pingMtx          sync.Mutex
+	pingEpoch        time.Time
+	lastPingResponse time.Time
+	rtt              time.Duration
+	// the handler for the command which is not expected when Expect.
+	unexpectedHandler SrsUnexpectedHandler
+	/**
+	 * the reusable buffers to merged write messages, the chunk headers are built into mwHeaders,
+	 * and the iovecs reference the headers and the payloads.
+	 */
+	mwHeaders []byte
+	mwChunks  []srsMwChunk
+	mwIovs    [][]byte
+}
+
+// the chunk to send, the header is mwHeaders[start:end].
+type srsMwChunk struct {
+	start   int
+	end     int
+	payload []byte
 }
 
 func NewSrsProtocol(io_ *skt.SrsIOReadWriter) *SrsProtocol {
@@ -70,9 +128,27 @@ func NewSrsProtocol(io_ *skt.SrsIOReadWriter) *SrsProtocol {
 		io:           io_,
 		inChunkSize:  global.SRS_CONSTS_RTMP_PROTOCOL_CHUNK_SIZE,
 		OutChunkSize: global.SRS_CONSTS_RTMP_PROTOCOL_CHUNK_SIZE,
+		Requests:     make(map[float64]string),
+		pingEpoch:    time.Now(),
+		ackNotify:    make(chan struct{}),
 	}
 }
 
+func (this *SrsProtocol) GetRecvBytes() int64 {
+	return this.io.GetRecvBytes()
+}
+
+func (this *SrsProtocol) GetSendBytes() int64 {
+	return this.io.GetSendBytes()
+}
+
+// the bytes acked by peer, 0 if peer never send acknowledgement.
+func (this *SrsProtocol) GetPeerAckedBytes() uint32 {
+	this.ackMtx.Lock()
+	defer this.ackMtx.Unlock()
+	return this.OutAckSize.SequenceNumber
+}
+
 var mhSizes = [4]int{11, 7, 3, 0}
 
 func (this *SrsProtocol) ReadBasicHeader() (fmt byte, cid int32, err error) {
@@ -104,7 +180,7 @@ func (this *SrsProtocol) ReadBasicHeader() (fmt byte, cid int32, err error) {
 
 		cid = 64
 		cid += (int32)(buffer3[0])
-		cid += (int32)(buffer3[1])
+		cid += (int32)(buffer3[1]) * 256
 		return
 	}
 	return
@@ -147,7 +223,7 @@ func (s *SrsProtocol) ReadMessageHeader(chunk *SrsChunkStream, format byte) (err
 	if chunk.MsgCount == 0 && format != RTMP_FMT_TYPE0 {
 		if chunk.Cid == global.RTMP_CID_ProtocolControl && format == RTMP_FMT_TYPE1 {
 		} else {
-			err = errors.New("error rtmp chunk start")
+			err = NewSrsProtocolError(ERROR_RTMP_CHUNK_START, "fresh chunk stream must start with fmt=0")
 			return
 		}
 	}
@@ -155,7 +231,7 @@ func (s *SrsProtocol) ReadMessageHeader(chunk *SrsChunkStream, format byte) (err
 	// when exists cache msg, means got an partial message,
 	// the fmt must not be type0 which means new message.
 	if chunk.RtmpMessage != nil && format == RTMP_FMT_TYPE0 {
-		err = errors.New("error rtmp chunk start")
+		err = NewSrsProtocolError(ERROR_RTMP_CHUNK_START, "fmt=0 chunk in the middle of message")
 		return
 	}
 
@@ -183,104 +259,46 @@ func (s *SrsProtocol) ReadMessageHeader(chunk *SrsChunkStream, format byte) (err
 	 *   fmt=1, 0x4X
 	 *   fmt=2, 0x8X
 	 *   fmt=3, 0xCX
+	 * @remark the timestamp of fmt=0 is absolute, which is also used as the delta
+	 * of the following fmt=3 chunks.
 	 */
-	var pos int32 = 0
 	if format <= RTMP_FMT_TYPE2 {
-		bufTimestamp := make([]byte, 4)
-		bufTimestamp[2] = buf[pos]
-		pos++
-		bufTimestamp[1] = buf[pos]
-		pos++
-		bufTimestamp[0] = buf[pos]
-		pos++
-		bufTimestamp[3] = 0
-		//trans to int32
-		bufReader := bytes.NewBuffer(bufTimestamp)
-		binary.Read(bufReader, binary.LittleEndian, &chunk.Header.timestampDelta)
-		chunk.ExtendedTimestamp = chunk.Header.timestampDelta >= global.RTMP_EXTENDED_TIMESTAMP
-		if !chunk.ExtendedTimestamp {
-			// Extended timestamp: 0 or 4 bytes
-			// This field MUST be sent when the normal timsestamp is set to
-			// 0xffffff, it MUST NOT be sent if the normal timestamp is set to
-			// anything else. So for values less than 0xffffff the normal
-			// timestamp field SHOULD be used in which case the extended timestamp
-			// MUST NOT be present. For values greater than or equal to 0xffffff
-			// the normal timestamp field MUST NOT be used and MUST be set to
-			// 0xffffff and the extended timestamp MUST be sent.
-
-			if format == RTMP_FMT_TYPE0 {
-				chunk.Header.timestamp = (int64)(chunk.Header.timestampDelta)
-			} else {
-				chunk.Header.timestamp += (int64)(chunk.Header.timestampDelta)
-			}
-		}
+		// 3bytes timestamp, big-endian.
+		var timestamp uint32 = uint32(buf[0])<<16 | uint32(buf[1])<<8 | uint32(buf[2])
+		// Extended timestamp: 0 or 4 bytes
+		// This field MUST be sent when the normal timsestamp is set to
+		// 0xffffff, it MUST NOT be sent if the normal timestamp is set to
+		// anything else. So for values less than 0xffffff the normal
+		// timestamp field SHOULD be used in which case the extended timestamp
+		// MUST NOT be present. For values greater than or equal to 0xffffff
+		// the normal timestamp field MUST NOT be used and MUST be set to
+		// 0xffffff and the extended timestamp MUST be sent.
+		chunk.ExtendedTimestamp = timestamp >= global.RTMP_EXTENDED_TIMESTAMP
+		chunk.Header.timestampDelta = int32(timestamp)
 
 		if format <= RTMP_FMT_TYPE1 {
-			lengthBuf := make([]byte, 4)
-			lengthBuf[2] = buf[pos]
-			pos += 1
-			lengthBuf[1] = buf[pos]
-			pos += 1
-			lengthBuf[0] = buf[pos]
-			pos += 1
-			lengthBuf[3] = 0
-			var payloadLength int32
-			//trans to int32
-			bufReader := bytes.NewBuffer(lengthBuf)
-			binary.Read(bufReader, binary.LittleEndian, &payloadLength)
+			// 3bytes payload length, big-endian.
+			var payloadLength int32 = int32(buf[3])<<16 | int32(buf[4])<<8 | int32(buf[5])
 			// for a message, if msg exists in cache, the size must not changed.
 			// always use the actual msg size to compare, for the cache payload length can changed,
 			// for the fmt type1(stream_id not changed), user can change the payload
 			// length(it's not allowed in the continue chunks).
 			if !isFirstChunkOfMsg && chunk.Header.payloadLength != payloadLength {
-				err = errors.New("error rtmp packet size")
+				err = NewSrsProtocolError(ERROR_RTMP_PACKET_SIZE, "payload length changed in continue chunk")
 				return
 			}
 
 			chunk.Header.payloadLength = payloadLength
-			chunk.Header.messageType = int8(buf[pos])
-			pos += 1
+			chunk.Header.messageType = int8(buf[6])
 
 			if format == RTMP_FMT_TYPE0 {
-				streamIdBuf := make([]byte, 4)
-				streamIdBuf[0] = buf[pos]
-				pos += 1
-				streamIdBuf[1] = buf[pos]
-				pos += 1
-				streamIdBuf[2] = buf[pos]
-				pos += 1
-				streamIdBuf[3] = buf[pos]
-				bufReader := bytes.NewBuffer(lengthBuf)
-				binary.Read(bufReader, binary.LittleEndian, &chunk.Header.streamId)
+				// 4bytes stream id, little-endian.
+				chunk.Header.streamId = int32(binary.LittleEndian.Uint32(buf[7:11]))
 			}
 		}
-	} else {
-		// update the timestamp even fmt=3 for first chunk packet
-		if isFirstChunkOfMsg && !chunk.ExtendedTimestamp {
-			chunk.Header.timestamp += (int64)(chunk.Header.timestampDelta)
-		}
 	}
 
 	if chunk.ExtendedTimestamp {
-		mhSize += 4
-		var buf2 []byte
-		if buf2, err = s.ReadNByte(4); err != nil {
-			return
-		}
-
-		bufTimestamp := make([]byte, 4)
-		bufTimestamp[3] = buf2[0]
-		bufTimestamp[2] = buf2[1]
-		bufTimestamp[1] = buf2[2]
-		bufTimestamp[0] = buf2[3]
-
-		var ts int32
-		bufReader := bytes.NewBuffer(bufTimestamp)
-		binary.Read(bufReader, binary.LittleEndian, &ts)
-		// always use 31bits timestamp, for some server may use 32bits extended timestamp.
-		// @see https://github.com/ossrs/srs/issues/111
-		ts &= 0x7ffffff
-
 		/**
 		 * RTMP specification and ffmpeg/librtmp is false,
 		 * but, adobe changed the specification, so flash/FMLE/FMS always true.
@@ -298,46 +316,63 @@ func (s *SrsProtocol) ReadMessageHeader(chunk *SrsChunkStream, format byte) (err
 		 * @remark, srs always send the extended-timestamp, to keep simple,
 		 * and compatible with adobe products.
 		 */
-		var chunkTimestamp int32 = (int32)(chunk.Header.timestamp)
-		/**
-		 * if chunk_timestamp<=0, the chunk previous packet has no extended-timestamp,
-		 * always use the extended timestamp.
-		 */
+		var b []byte
+		if b, err = s.io.Peek(4); err != nil {
+			return
+		}
+		var extendedTimestamp uint32 = binary.BigEndian.Uint32(b)
+
 		/**
 		 * about the is_first_chunk_of_msg.
 		 * @remark, for the first chunk of message, always use the extended timestamp.
+		 * for the continue fmt=3 chunk, the 4bytes is the extended timestamp only when
+		 * it equals to the previous one, or it's the payload.
 		 */
-		if !isFirstChunkOfMsg && chunkTimestamp > 0 && chunkTimestamp != ts {
-			mhSize -= 4
-			//这里需要考虑下怎么处理
-			//no 4bytes extended timestamp in the continued chunk
+		if format == RTMP_FMT_TYPE3 && !isFirstChunkOfMsg && extendedTimestamp != chunk.extendedTimestamp {
+			// no 4bytes extended timestamp in the continued chunk
 		} else {
-			chunk.Header.timestamp = (int64)(ts)
+			if _, err = s.ReadNByte(4); err != nil {
+				return
+			}
+			// the fmt=3 chunk repeats the extended timestamp, the delta not changed.
+			if format != RTMP_FMT_TYPE3 {
+				chunk.Header.timestampDelta = int32(extendedTimestamp)
+			}
+			chunk.extendedTimestamp = extendedTimestamp
 		}
 	}
 
-	// the extended-timestamp must be unsigned-int,
-	//         24bits timestamp: 0xffffff = 16777215ms = 16777.215s = 4.66h
-	//         32bits timestamp: 0xffffffff = 4294967295ms = 4294967.295s = 1193.046h = 49.71d
-	// because the rtmp protocol says the 32bits timestamp is about "50 days":
-	//         3. Byte Order, Alignment, and Time Format
-	//                Because timestamps are generally only 32 bits long, they will roll
-	//                over after fewer than 50 days.
-	//
-	// but, its sample says the timestamp is 31bits:
-	//         An application could assume, for example, that all
-	//        adjacent timestamps are within 2^31 milliseconds of each other, so
-	//        10000 comes after 4000000000, while 3000000000 comes before
-	//        4000000000.
-	// and flv specification says timestamp is 31bits:
-	//        Extension of the Timestamp field to form a SI32 value. This
-	//        field represents the upper 8 bits, while the previous
-	//        Timestamp field represents the lower 24 bits of the time in
-	//        milliseconds.
-	// in a word, 31bits timestamp is ok.
-	// convert extended timestamp to 31bits.
-
-	chunk.Header.timestamp &= 0x7fffffff
+	// the timestamp is decided by the first chunk of message,
+	// update the timestamp even fmt=3 for first chunk packet.
+	if isFirstChunkOfMsg {
+		var timestamp uint32 = uint32(chunk.Header.timestampDelta)
+		if format != RTMP_FMT_TYPE0 {
+			timestamp += chunk.wireTimestamp
+		}
+
+		// the extended-timestamp must be unsigned-int,
+		//         24bits timestamp: 0xffffff = 16777215ms = 16777.215s = 4.66h
+		//         32bits timestamp: 0xffffffff = 4294967295ms = 4294967.295s = 1193.046h = 49.71d
+		// because the rtmp protocol says the 32bits timestamp is about "50 days":
+		//         3. Byte Order, Alignment, and Time Format
+		//                Because timestamps are generally only 32 bits long, they will roll
+		//                over after fewer than 50 days.
+		//
+		// and its sample says the adjacent timestamps are within 2^31 milliseconds:
+		//         An application could assume, for example, that all
+		//        adjacent timestamps are within 2^31 milliseconds of each other, so
+		//        10000 comes after 4000000000, while 3000000000 comes before
+		//        4000000000.
+		// so we unwrap the 32bits timestamp on the wire to 64bits by the signed
+		// distance to the previous one, which keeps increasing after 49.71d.
+		if chunk.MsgCount == 0 {
+			chunk.Header.timestamp = int64(timestamp)
+		} else {
+			chunk.Header.timestamp += int64(int32(timestamp - chunk.wireTimestamp))
+		}
+		chunk.wireTimestamp = timestamp
+	}
+
 	// copy header to msg
 	chunk.RtmpMessage.header = chunk.Header
 	// increase the msg count, the chunk stream can accept fmt=1/2/3 message now.
@@ -379,7 +414,9 @@ func (this *SrsProtocol) RecvInterlacedMessage() (*SrsRtmpMessage, error) {
 
 func (s *SrsProtocol) RecvMessagePayload(chunk *SrsChunkStream) (msg *SrsRtmpMessage, err error) {
 	if chunk.Header.payloadLength <= 0 {
-		return chunk.RtmpMessage, nil
+		newMsg := chunk.RtmpMessage
+		chunk.RtmpMessage = nil
+		return newMsg, nil
 	}
 
 	// the chunk payload size.
@@ -389,18 +426,20 @@ func (s *SrsProtocol) RecvMessagePayload(chunk *SrsChunkStream) (msg *SrsRtmpMes
 	if s.inChunkSize < payloadSize { //如果长度大于in_chunk_size，则最大是in_chunk_size
 		payloadSize = s.inChunkSize
 	}
-	// create msg payload if not initialized
-	if chunk.RtmpMessage.payload == nil {
-		chunk.RtmpMessage.payload = make([]byte, 0)
+	// create msg payload from pool if not initialized, the chunks are read into it directly.
+	if chunk.RtmpMessage.shared == nil {
+		chunk.RtmpMessage.shared = NewSrsSharedPayload(int(chunk.Header.payloadLength))
+		chunk.RtmpMessage.payload = chunk.RtmpMessage.shared.data
+	}
+	if int32(len(chunk.RtmpMessage.payload)) != chunk.Header.payloadLength {
+		return nil, NewSrsProtocolError(ERROR_RTMP_PACKET_SIZE, "payload size mismatch with message header")
 	}
 
 	// read payload to buffer
-	var buf []byte
-	if buf, err = s.ReadNByte(int(payloadSize)); err != nil {
+	recvedSize := chunk.RtmpMessage.recvedSize
+	if _, err = s.io.ReadFully(chunk.RtmpMessage.payload[recvedSize:recvedSize+payloadSize], 1000); err != nil {
 		return nil, err
 	}
-
-	chunk.RtmpMessage.payload = append(chunk.RtmpMessage.payload, buf...)
 	chunk.RtmpMessage.recvedSize += payloadSize
 
 	if chunk.Header.payloadLength == chunk.RtmpMessage.recvedSize {
@@ -418,6 +457,10 @@ func (s *SrsProtocol) RecvMessage() (*SrsRtmpMessage, error) {
 			return nil, err
 		}
 
+		if err = s.responseAcknowledgement(); err != nil {
+			return nil, err
+		}
+
 		if rtmpMsg == nil {
 			continue
 		}
@@ -437,10 +480,6 @@ func (s *SrsProtocol) RecvMessage() (*SrsRtmpMessage, error) {
 
 func (this *SrsProtocol) doDecodeMessage(msg *SrsRtmpMessage, stream *utils.SrsStream) (pkt packet.SrsPacket, err error) {
 	if msg.header.IsAmf0Command() || msg.header.IsAmf3Command() || msg.header.IsAmf0Data() || msg.header.IsAmf3Data() {
-		// skip 1bytes to decode the amf3 command.
-		if msg.header.IsAmf3Command() && stream.Require(1) {
-			stream.Skip(1)
-		}
 		// amf0 command message.
 		// need to read the command name.
 		var amf0Command amf0.SrsAmf0String
@@ -450,9 +489,37 @@ func (this *SrsProtocol) doDecodeMessage(msg *SrsRtmpMessage, stream *utils.SrsS
 			return
 		}
 		command := amf0Command.Value.Value
+		// unwrap the data of publisher, for example, @setDataFrame("onMetaData", metadata) of FMLE.
+		if command == amf0.SRS_CONSTS_RTMP_SET_DATAFRAME && (msg.header.IsAmf0Data() || msg.header.IsAmf3Data()) {
+			if err = amf0Command.Decode(stream); err != nil {
+				err = errors.New("srs_amf0_read_string error of @setDataFrame")
+				return
+			}
+			command = amf0Command.Value.Value
+		}
+
+		// the response of request we sent, find the request by transaction id.
+		if command == amf0.RTMP_AMF0_COMMAND_RESULT || command == amf0.RTMP_AMF0_COMMAND_ERROR {
+			pkt, err = this.decodeResponse(stream)
+			return
+		}
+
+		// the onStatus in data message, for example, NetStream.Data.Start of play.
+		if command == amf0.RTMP_AMF0_COMMAND_ON_STATUS && (msg.header.IsAmf0Data() || msg.header.IsAmf3Data()) {
+			pkt = packet.NewSrsOnStatusDataPacket()
+			err = pkt.Decode(stream)
+			return
+		}
+
 		// decode command object.
 		// todo other message
-		if command == amf0.RTMP_AMF0_COMMAND_CONNECT {
+		if command == amf0.RTMP_AMF0_COMMAND_ON_STATUS || command == global.RTMP_AMF0_COMMAND_ON_FC_PUBLISH ||
+			command == global.RTMP_AMF0_COMMAND_ON_FC_UNPUBLISH {
+			pkt = packet.NewSrsOnStatusCallPacket()
+			pkt.(*packet.SrsOnStatusCallPacket).CommandName.Value.Value = command
+			err = pkt.Decode(stream)
+			return
+		} else if command == amf0.RTMP_AMF0_COMMAND_CONNECT {
 			pkt = packet.NewSrsConnectAppPacket()
 			err = pkt.Decode(stream)
 			return
@@ -479,6 +546,15 @@ func (this *SrsProtocol) doDecodeMessage(msg *SrsRtmpMessage, stream *utils.SrsS
 		} else if command == amf0.RTMP_AMF0_COMMAND_UNPUBLISH {
 			pkt = packet.NewSrsFMLEStartPacket(command)
 			err = pkt.Decode(stream)
+			return
+		} else if command == amf0.RTMP_AMF0_COMMAND_PAUSE {
+			pkt = packet.NewSrsPausePacket()
+			err = pkt.Decode(stream)
+			return
+		} else if command == amf0.RTMP_AMF0_COMMAND_SEEK {
+			pkt = packet.NewSrsSeekPacket()
+			err = pkt.Decode(stream)
+			return
 		} else if command == amf0.RTMP_AMF0_COMMAND_CLOSE_STREAM {
 			pkt = packet.NewSrsCloseStreamPacket()
 			err = pkt.Decode(stream)
@@ -487,17 +563,94 @@ func (this *SrsProtocol) doDecodeMessage(msg *SrsRtmpMessage, stream *utils.SrsS
 			pkt = packet.NewSrsOnMetaDataPacket(command)
 			err = pkt.Decode(stream)
 			return
+		} else if command == amf0.SRS_CONSTS_RTMP_ON_TEXTDATA || command == amf0.SRS_CONSTS_RTMP_ON_CUEPOINT {
+			pkt = packet.NewSrsOnDataPacket(command)
+			err = pkt.Decode(stream)
+			return
 		}
+
+		// the generic call for the other commands, response by the call handler.
+		if msg.header.IsAmf0Command() || msg.header.IsAmf3Command() {
+			pkt = packet.NewSrsCallPacket(command)
+			err = pkt.Decode(stream)
+			return
+		}
+	} else if msg.header.IsAmf0SharedObject() {
+		pkt = packet.NewSrsSharedObjectPacket("", 0, false)
+		err = pkt.Decode(stream)
+		return
+	} else if msg.header.IsAbortMessage() {
+		pkt = packet.NewSrsAbortMessagePacket()
+		err = pkt.Decode(stream)
+		return
 	} else if msg.header.IsSetChunkSize() {
 		pkt = packet.NewSrsSetChunkSizePacket()
 		err = pkt.Decode(stream)
 		return
+	} else if msg.header.IsWindowAckledgementSize() {
+		pkt = packet.NewSrsSetWindowAckSizePacket()
+		err = pkt.Decode(stream)
+		return
+	} else if msg.header.IsAckledgement() {
+		pkt = packet.NewSrsAcknowledgementPacket()
+		err = pkt.Decode(stream)
+		return
+	} else if msg.header.IsSetPeerBandwidth() {
+		pkt = packet.NewSrsSetPeerBandwidthPacket()
+		err = pkt.Decode(stream)
+		return
+	} else if msg.header.IsUserControlMessage() {
+		pkt = packet.NewSrsUserControlPacket()
+		err = pkt.Decode(stream)
+		return
 	}
 	return
 }
 
+/**
+* decode the _result or _error response, the packet is decided by the request,
+* for example, the response of connect is SrsConnectAppResPacket.
+* @return nil packet if the request is unknown.
+ */
+func (this *SrsProtocol) decodeResponse(stream *utils.SrsStream) (packet.SrsPacket, error) {
+	var transactionId amf0.SrsAmf0Number
+	if err := transactionId.Decode(utils.NewSrsStream(stream.PeekLeftBytes())); err != nil {
+		return nil, err
+	}
+
+	request, ok := this.Requests[transactionId.Value]
+	if !ok {
+		return nil, nil
+	}
+	delete(this.Requests, transactionId.Value)
+
+	var pkt packet.SrsPacket
+	switch request {
+	case amf0.RTMP_AMF0_COMMAND_CONNECT:
+		pkt = packet.NewSrsConnectAppResPacket()
+	case amf0.RTMP_AMF0_COMMAND_CREATE_STREAM:
+		pkt = packet.NewSrsCreateStreamResPacket(0, 0)
+	case amf0.RTMP_AMF0_COMMAND_RELEASE_STREAM, amf0.RTMP_AMF0_COMMAND_FC_PUBLISH, amf0.RTMP_AMF0_COMMAND_UNPUBLISH:
+		pkt = packet.NewSrsFMLEStartResPacket(0)
+	default:
+		return nil, nil
+	}
+
+	if err := pkt.Decode(stream); err != nil {
+		return nil, err
+	}
+	return pkt, nil
+}
+
 func (s *SrsProtocol) DecodeMessage(msg *SrsRtmpMessage) (packet packet.SrsPacket, err error) {
-	stream := utils.NewSrsStream(msg.payload)
+	payload := msg.payload
+	if msg.header.IsAmf3Command() || msg.header.IsAmf3Data() {
+		if payload, err = s.convertAmf3Payload(msg); err != nil {
+			return
+		}
+	}
+
+	stream := utils.NewSrsStream(payload)
 	if stream == nil {
 		err = errors.New("NewSrsStream failed")
 		return
@@ -510,66 +663,255 @@ func (s *SrsProtocol) DecodeMessage(msg *SrsRtmpMessage) (packet packet.SrsPacke
 	return
 }
 
+/**
+* convert the payload of amf3 command/data message to amf0,
+* the amf3 command starts with 1byte format selector which is always 0,
+* some encoder also send the selector for the amf3 data message.
+ */
+func (s *SrsProtocol) convertAmf3Payload(msg *SrsRtmpMessage) ([]byte, error) {
+	payload := msg.payload
+	if len(payload) > 0 && (msg.header.IsAmf3Command() || payload[0] == 0x00) {
+		payload = payload[1:]
+	}
+	return amf3.ConvertToAmf0(payload)
+}
+
 func (s *SrsProtocol) OnRecvRtmpMessage(msg *SrsRtmpMessage) error {
 	var pkt packet.SrsPacket
-	if msg.header.messageType == global.RTMP_MSG_SetChunkSize || msg.header.messageType == global.RTMP_MSG_UserControlMessage || msg.header.messageType == global.RTMP_MSG_WindowAcknowledgementSize {
+	switch msg.header.messageType {
+	case global.RTMP_MSG_SetChunkSize, global.RTMP_MSG_UserControlMessage, global.RTMP_MSG_WindowAcknowledgementSize,
+		global.RTMP_MSG_Acknowledgement, global.RTMP_MSG_SetPeerBandwidth, global.RTMP_MSG_AbortMessage:
 		var err error
 		pkt, err = s.DecodeMessage(msg)
 		if err != nil {
-			return errors.New("decode packet from message payload failed.")
+			return NewSrsProtocolError(ERROR_RTMP_MESSAGE_DECODE, "decode protocol control message failed, "+err.Error())
 		}
-		_ = pkt
 	}
 
-	if msg.header.messageType == global.RTMP_MSG_SetChunkSize {
-		//参数检查
-		s.inChunkSize = pkt.(*packet.SrsSetChunkSizePacket).ChunkSize
+	switch msg.header.messageType {
+	case global.RTMP_MSG_SetChunkSize:
+		// for some server, the actual chunk size can greater than the max value(65536),
+		// but we only support [128, 65536] as srs does, and the smaller one will cause
+		// too many chunks for the message.
+		chunkSize := pkt.(*packet.SrsSetChunkSizePacket).ChunkSize
+		if chunkSize < global.SRS_CONSTS_RTMP_MIN_CHUNK_SIZE || chunkSize > global.SRS_CONSTS_RTMP_MAX_CHUNK_SIZE {
+			return NewSrsProtocolError(ERROR_RTMP_CHUNK_SIZE, fmt.Sprintf("chunk size %d out of range [%d, %d]",
+				chunkSize, global.SRS_CONSTS_RTMP_MIN_CHUNK_SIZE, global.SRS_CONSTS_RTMP_MAX_CHUNK_SIZE))
+		}
+		s.inChunkSize = chunkSize
+	case global.RTMP_MSG_AbortMessage:
+		s.onAbortMessage(int32(pkt.(*packet.SrsAbortMessagePacket).ChunkStreamId))
+	case global.RTMP_MSG_WindowAcknowledgementSize:
+		// the peer want us to ack when received bytes of window.
+		if size := pkt.(*packet.SrsSetWindowAckSizePacket).AckowledgementWindowSize; size > 0 {
+			s.InAckSize.Window = uint32(size)
+		}
+	case global.RTMP_MSG_Acknowledgement:
+		s.onAcknowledgement(pkt.(*packet.SrsAcknowledgementPacket).SequenceNumber)
+	case global.RTMP_MSG_SetPeerBandwidth:
+		// 5.6. Set Peer Bandwidth (6)
+		// The peer receiving this message SHOULD respond with a Window
+		// Acknowledgement Size message if the window size is different from the
+		// last one sent to the sender of this message.
+		bwPkt := pkt.(*packet.SrsSetPeerBandwidthPacket)
+		if window := s.onSetPeerBandwidth(bwPkt.Bandwidth, bwPkt.Type); window > 0 {
+			ackPkt := packet.NewSrsSetWindowAckSizePacket()
+			ackPkt.AckowledgementWindowSize = int32(window)
+			if err := s.SendPacket(ackPkt, 0); err != nil {
+				return err
+			}
+		}
+	case global.RTMP_MSG_UserControlMessage:
+		ucPkt := pkt.(*packet.SrsUserControlPacket)
+		switch ucPkt.EventType {
+		case global.SrcPCUCPingRequest:
+			// answer the ping of peer with the timestamp it sent.
+			resPkt := packet.NewSrsUserControlPacket()
+			resPkt.EventType = global.SrcPCUCPingResponse
+			resPkt.EventData = ucPkt.EventData
+			if err := s.SendPacket(resPkt, 0); err != nil {
+				return err
+			}
+		case global.SrcPCUCPingResponse:
+			s.onPingResponse(ucPkt.EventData)
+		}
 	}
 
 	return nil
 }
 
-func (this *SrsProtocol) ExpectMessage(pkt packet.SrsPacket) error {
-	if reflect.TypeOf(pkt).Kind() != reflect.Ptr {
-		return errors.New("need ptr to store result")
+/**
+* discard the partial received message of the chunk stream,
+* the next chunk of the stream starts a new message.
+ */
+func (this *SrsProtocol) onAbortMessage(cid int32) {
+	var chunk *SrsChunkStream
+	if cid >= 0 && cid < SRS_PERF_CHUNK_STREAM_CACHE {
+		chunk = this.chunkCache[cid]
+	} else {
+		chunk = this.chunkStreams[cid]
+	}
+
+	if chunk == nil || chunk.RtmpMessage == nil {
+		return
+	}
+	chunk.RtmpMessage.Release()
+	chunk.RtmpMessage = nil
+}
+
+/**
+* send the PingRequest to peer, the event data is the ms since the protocol created,
+* which the peer echo back in PingResponse to calc the rtt.
+ */
+func (this *SrsProtocol) SendPingRequest() error {
+	pkt := packet.NewSrsUserControlPacket()
+	pkt.EventType = global.SrcPCUCPingRequest
+	pkt.EventData = int32(time.Since(this.pingEpoch) / time.Millisecond)
+	return this.SendPacket(pkt, 0)
+}
+
+func (this *SrsProtocol) onPingResponse(timestamp int32) {
+	this.pingMtx.Lock()
+	defer this.pingMtx.Unlock()
+
+	now := time.Now()
+	this.lastPingResponse = now
+	// ignore the response which timestamp is not sent by us.
+	if rtt := now.Sub(this.pingEpoch) - time.Duration(timestamp)*time.Millisecond; rtt >= 0 && timestamp >= 0 {
+		this.rtt = rtt
 	}
+}
 
-	donePkt := make(chan packet.SrsPacket)
-	//todo 这里需要修改为cancelctx
-	go func() {
-		for {
-			msg, err := this.RecvMessage()
-			if err != nil {
-				continue
-			}
+// the rtt of last ping, 0 if peer never response the ping.
+func (this *SrsProtocol) GetRtt() time.Duration {
+	this.pingMtx.Lock()
+	defer this.pingMtx.Unlock()
+	return this.rtt
+}
 
-			if msg == nil {
-				continue
-			}
+// the time when got the last PingResponse, zero if peer never response.
+func (this *SrsProtocol) GetLastPingResponse() time.Time {
+	this.pingMtx.Lock()
+	defer this.pingMtx.Unlock()
+	return this.lastPingResponse
+}
 
-			p, err1 := this.DecodeMessage(msg)
-			if err1 != nil {
-				continue
-			}
+func (this *SrsProtocol) onAcknowledgement(sequenceNumber uint32) {
+	this.ackMtx.Lock()
+	defer this.ackMtx.Unlock()
 
-			if reflect.TypeOf(p) != reflect.TypeOf(pkt) {
-				continue
-			}
-			donePkt <- p
-			break
+	this.OutAckSize.SequenceNumber = sequenceNumber
+	close(this.ackNotify)
+	this.ackNotify = make(chan struct{})
+}
+
+/**
+* limit the output bandwidth by the SetPeerBandwidth of peer,
+* the hard limits to the bandwidth, the soft limits to the smaller one of the bandwidth and
+* the current limit, the dynamic is treated as hard if the previous limit is hard, otherwise ignored.
+* @return the window to respond when the limit changed and different from the window we told peer,
+*       0 when nothing changed, for example, the ignored dynamic.
+ */
+func (this *SrsProtocol) onSetPeerBandwidth(bandwidth int32, typ int8) uint32 {
+	this.ackMtx.Lock()
+	defer this.ackMtx.Unlock()
+
+	if bandwidth <= 0 {
+		return 0
+	}
+
+	size := uint32(bandwidth)
+	limit, limitType := this.peerBandwidth, this.peerLimitType
+	switch typ {
+	case packet.SrsPeerBandwidthHard:
+		limit, limitType = size, typ
+	case packet.SrsPeerBandwidthSoft:
+		if limit == 0 || size < limit {
+			limit = size
 		}
-	}()
+		limitType = typ
+	case packet.SrsPeerBandwidthDynamic:
+		if limit > 0 && limitType == packet.SrsPeerBandwidthHard {
+			limit = size
+		}
+	}
+
+	if limit == this.peerBandwidth && limitType == this.peerLimitType {
+		return 0
+	}
+	this.peerBandwidth, this.peerLimitType = limit, limitType
+
+	if limit == this.OutAckSize.Window {
+		return 0
+	}
+	return limit
+}
 
-	var tmp_pkt packet.SrsPacket
+/**
+* wait for the acknowledgement of peer when the bytes sent and not acked exceed the bandwidth
+* limited by peer, it's called out of the sendMtx for the media only, so the others are never blocked.
+* for the peer which never acks in time, the window is not honored any more.
+ */
+func (this *SrsProtocol) waitPeerWindow() {
+	var timer *time.Timer
 	for {
+		this.ackMtx.Lock()
+		limit := this.peerBandwidth
+		if limit == 0 || this.peerAckDisabled {
+			this.ackMtx.Unlock()
+			break
+		}
+		// some peers count the bytes of handshake while others not, so allow some slack.
+		unacked := uint32(this.io.GetSendBytes()) - this.OutAckSize.SequenceNumber
+		if unacked < limit+limit/SRS_RTMP_PEER_WINDOW_SLACK {
+			this.ackMtx.Unlock()
+			break
+		}
+		notify := this.ackNotify
+		this.ackMtx.Unlock()
+
+		if timer == nil {
+			timer = time.NewTimer(SRS_RTMP_PEER_ACK_TIMEOUT)
+		}
 		select {
-		case tmp_pkt = <-donePkt:
-			reflect.ValueOf(pkt).Elem().Set(reflect.ValueOf(tmp_pkt).Elem())
-			return nil
-		case <-time.After(time.Second * 2):
-			return errors.New("expect message timeout, type=" + reflect.TypeOf(pkt).String())
+		case <-notify:
+		case <-timer.C:
+			log.Warnf("peer not acked %d bytes in %v, ignore its window %d", unacked, SRS_RTMP_PEER_ACK_TIMEOUT, limit)
+			this.ackMtx.Lock()
+			this.peerAckDisabled = true
+			this.ackMtx.Unlock()
+			return
 		}
 	}
+
+	if timer != nil {
+		timer.Stop()
+	}
+}
+
+/**
+* send the acknowledgement when the received bytes exceed the window,
+* the sequence number is the total bytes received.
+ */
+func (this *SrsProtocol) responseAcknowledgement() error {
+	if this.InAckSize.Window <= 0 {
+		return nil
+	}
+
+	recvBytes := this.io.GetRecvBytes()
+	if recvBytes-this.InAckSize.RecvBytes < int64(this.InAckSize.Window) {
+		return nil
+	}
+
+	pkt := packet.NewSrsAcknowledgementPacket()
+	pkt.SequenceNumber = uint32(recvBytes)
+	if err := this.SendPacket(pkt, 0); err != nil {
+		return err
+	}
+
+	this.InAckSize.RecvBytes = recvBytes
+	this.InAckSize.SequenceNumber = pkt.SequenceNumber
+	return nil
 }
 
 func (this *SrsProtocol) SendPacket(packet packet.SrsPacket, streamId int32) error {
@@ -606,6 +948,9 @@ func (this *SrsProtocol) doSendPacket(pkt packet.SrsPacket, streamId int32) erro
 }
 
 func (this *SrsProtocol) doSimpleSend(mh *SrsMessageHeader, payload []byte) error {
+	this.sendMtx.Lock()
+	defer this.sendMtx.Unlock()
+
 	var sendedCount int = 0
 	var d []byte
 	var err error
@@ -614,12 +959,12 @@ func (this *SrsProtocol) doSimpleSend(mh *SrsMessageHeader, payload []byte) erro
 	for len(leftPayload) > 0 {
 		if firstPkt {
 			firstPkt = false
-			d, err = srs_chunk_header_c0(mh.perferCid, int32(mh.timestamp), mh.payloadLength, mh.messageType, mh.streamId)
+			d, err = srs_chunk_header_c0(mh.perferCid, uint32(mh.timestamp), mh.payloadLength, mh.messageType, mh.streamId)
 			if err != nil {
 				return err
 			}
 		} else {
-			d, err = srs_chunk_header_c3(mh.perferCid, int32(mh.timestamp))
+			d, err = srs_chunk_header_c3(mh.perferCid, uint32(mh.timestamp))
 		}
 
 		payloadSize := utils.MinInt32(int32(len(leftPayload)), this.OutChunkSize) //int32(len(leftPayload))//
@@ -635,47 +980,82 @@ func (this *SrsProtocol) doSimpleSend(mh *SrsMessageHeader, payload []byte) erro
 	return nil
 }
 
+/**
+* send the messages in one writev, the chunk headers are built into the reusable buffer,
+* and the iovecs reference the headers and the payloads without copy.
+ */
 func (this *SrsProtocol) SendMessages(msgs []*SrsRtmpMessage, streamId int) error {
-	for i := 0; i < len(msgs); i++ {
-		if msgs[i] == nil {
-			continue
-		}
+	// only the media is limited by the window of peer, the command and control messages are
+	// never blocked, which may be sent by the recv goroutine which reads the acknowledgement.
+	if hasMediaMessage(msgs) {
+		this.waitPeerWindow()
+	}
+
+	this.sendMtx.Lock()
+	defer this.sendMtx.Unlock()
 
-		if len(msgs[i].GetPayload()) <= 0 {
+	this.mwHeaders = this.mwHeaders[:0]
+	this.mwChunks = this.mwChunks[:0]
+	for i := 0; i < len(msgs); i++ {
+		if msgs[i] == nil || len(msgs[i].GetPayload()) <= 0 {
 			continue
 		}
 
-		msg := msgs[i]
-		leftPayload := msg.GetPayload()
-		var sendedCount int = 0
-		var d []byte
-		var err error
+		header := msgs[i].GetHeader()
+		leftPayload := msgs[i].GetPayload()
 		firstPkt := true
 		for len(leftPayload) > 0 {
+			var d []byte
+			var err error
 			if firstPkt {
 				firstPkt = false
-				d, err = srs_chunk_header_c0(msg.GetHeader().perferCid, int32(msg.GetHeader().timestamp), msg.GetHeader().payloadLength, msg.GetHeader().messageType, int32(streamId))
+				d, err = srs_chunk_header_c0(header.perferCid, uint32(header.timestamp), header.payloadLength, header.messageType, int32(streamId))
 				if err != nil {
 					return err
 				}
 			} else {
-				d, err = srs_chunk_header_c3(msg.GetHeader().perferCid, int32(msg.GetHeader().timestamp))
+				d, _ = srs_chunk_header_c3(header.perferCid, uint32(header.timestamp))
 			}
 
-			payloadSize := utils.MinInt32(int32(len(leftPayload)), this.OutChunkSize) //int32(len(leftPayload))//
-			sendPayload := leftPayload[:payloadSize]
+			start := len(this.mwHeaders)
+			this.mwHeaders = append(this.mwHeaders, d...)
+			payloadSize := utils.MinInt32(int32(len(leftPayload)), this.OutChunkSize)
+			this.mwChunks = append(this.mwChunks, srsMwChunk{start: start, end: len(this.mwHeaders), payload: leftPayload[:payloadSize]})
 			leftPayload = leftPayload[payloadSize:]
-			d = append(d, sendPayload...)
-			n2, err2 := this.io.Write(d)
-			if err2 != nil {
-				return err2
-			}
-			sendedCount += n2
 		}
+	}
+
+	if len(this.mwChunks) == 0 {
 		return nil
+	}
 
+	// the headers buffer is stable now, build the iovecs.
+	this.mwIovs = this.mwIovs[:0]
+	for i := 0; i < len(this.mwChunks); i++ {
+		chunk := &this.mwChunks[i]
+		this.mwIovs = append(this.mwIovs, this.mwHeaders[chunk.start:chunk.end], chunk.payload)
+		// release the payload, which is referenced by iovecs.
+		chunk.payload = nil
 	}
-	return nil
+
+	bufs := net.Buffers(this.mwIovs)
+	_, err := this.io.WriteBuffers(&bufs)
+	return err
+}
+
+// whether the messages contain audio, video, data or aggregate message.
+func hasMediaMessage(msgs []*SrsRtmpMessage) bool {
+	for i := 0; i < len(msgs); i++ {
+		if msgs[i] == nil {
+			continue
+		}
+
+		header := msgs[i].GetHeader()
+		if header.IsAV() || header.IsAmf0Data() || header.IsAmf3Data() || header.IsAggregate() {
+			return true
+		}
+	}
+	return false
 }
 
 func (this *SrsProtocol) onSendPacket(mh *SrsMessageHeader, pkt packet.SrsPacket) error {
@@ -687,7 +1067,9 @@ func (this *SrsProtocol) onSendPacket(mh *SrsMessageHeader, pkt packet.SrsPacket
 	case global.RTMP_MSG_SetChunkSize:
 		this.OutChunkSize = pkt.(*packet.SrsSetChunkSizePacket).ChunkSize
 	case global.RTMP_MSG_WindowAcknowledgementSize:
+		this.ackMtx.Lock()
 		this.OutAckSize.Window = uint32(pkt.(*packet.SrsSetWindowAckSizePacket).AckowledgementWindowSize)
+		this.ackMtx.Unlock()
 	case global.RTMP_MSG_AMF0CommandMessage, global.RTMP_MSG_AMF3CommandMessage:
 		switch pkt.(type) {
 		case *packet.SrsConnectAppPacket:
@@ -716,7 +1098,7 @@ func (this *SrsProtocol) onSendPacket(mh *SrsMessageHeader, pkt packet.SrsPacket
 
 const SRS_CONSTS_RTMP_MAX_FMT0_HEADER_SIZE = 16
 
-func srs_chunk_header_c0(perferCid int32, timestamp int32, payload_length int32, message_type int8, stream_id int32) ([]byte, error) {
+func srs_chunk_header_c0(perferCid int32, timestamp uint32, payload_length int32, message_type int8, stream_id int32) ([]byte, error) {
 	var len int32 = 0
 	// to directly set the field.
 	data := make([]byte, SRS_CONSTS_RTMP_MAX_FMT0_HEADER_SIZE)
@@ -726,10 +1108,9 @@ func srs_chunk_header_c0(perferCid int32, timestamp int32, payload_length int32,
 	// chunk message header, 11 bytes
 	// timestamp, 3bytes, big-endian
 	if timestamp < global.RTMP_EXTENDED_TIMESTAMP {
-		b := utils.Int32ToBytes(timestamp, binary.LittleEndian)
-		data[1] = b[2]
-		data[2] = b[1]
-		data[3] = b[0]
+		data[1] = byte(timestamp >> 16)
+		data[2] = byte(timestamp >> 8)
+		data[3] = byte(timestamp)
 	} else { //有扩展字段，则timestamp全f
 		data[1] = 0xFF
 		data[2] = 0xFF
@@ -773,11 +1154,7 @@ func srs_chunk_header_c0(perferCid int32, timestamp int32, payload_length int32,
 	// @see: http://blog.csdn.net/win_lin/article/details/13363699
 	// TODO: FIXME: extract to outer.
 	if timestamp >= global.RTMP_EXTENDED_TIMESTAMP {
-		b = utils.Int32ToBytes(timestamp, binary.BigEndian)
-		data[12] = b[3]
-		data[13] = b[2]
-		data[14] = b[1]
-		data[15] = b[0]
+		binary.BigEndian.PutUint32(data[12:16], timestamp)
 		len += 4
 	}
 	return data[:len], nil
@@ -785,7 +1162,7 @@ func srs_chunk_header_c0(perferCid int32, timestamp int32, payload_length int32,
 
 const SRS_CONSTS_RTMP_MAX_FMT3_HEADER_SIZE = 5
 
-func srs_chunk_header_c3(prefer_cid int32, timestamp int32) ([]byte, error) {
+func srs_chunk_header_c3(prefer_cid int32, timestamp uint32) ([]byte, error) {
 	// to directly set the field.
 	var len int32 = 0
 	// to directly set the field.
@@ -814,11 +1191,7 @@ func srs_chunk_header_c3(prefer_cid int32, timestamp int32) ([]byte, error) {
 	// @see: http://blog.csdn.net/win_lin/article/details/13363699
 	// TODO: FIXME: extract to outer.
 	if timestamp >= global.RTMP_EXTENDED_TIMESTAMP {
-		b := utils.Int32ToBytes(timestamp, binary.BigEndian)
-		data[1] = b[3]
-		data[2] = b[2]
-		data[3] = b[1]
-		data[4] = b[0]
+		binary.BigEndian.PutUint32(data[1:5], timestamp)
 		len += 4
 	}
 	return data[:len], nil
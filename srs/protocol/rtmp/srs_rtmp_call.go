@@ -0,0 +1,113 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2019 GOSRS(gosrs)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package rtmp
+
+import (
+	"go_srs/srs/global"
+	"go_srs/srs/protocol/amf0"
+	"go_srs/srs/protocol/packet"
+	"sync"
+)
+
+/**
+* the handler for the call of client, for example, NetConnection.call("myCall", responder, args),
+* @return the response of _result, or error to response _error.
+ */
+type SrsCallHandler func(call *packet.SrsCallPacket) (amf0.ISrsAmf0Any, error)
+
+var callHandlersMtx sync.RWMutex
+var callHandlers map[string]SrsCallHandler
+
+func init() {
+	callHandlers = make(map[string]SrsCallHandler)
+
+	// the live stream has no length.
+	RegisterCallHandler(amf0.RTMP_AMF0_COMMAND_GET_STREAM_LENGTH, func(call *packet.SrsCallPacket) (amf0.ISrsAmf0Any, error) {
+		return amf0.NewSrsAmf0Number(0), nil
+	})
+	// the bandwidth check of flash, response to avoid client timeout.
+	RegisterCallHandler(amf0.RTMP_AMF0_COMMAND_CHECK_BW, func(call *packet.SrsCallPacket) (amf0.ISrsAmf0Any, error) {
+		return nil, nil
+	})
+}
+
+/**
+* register the handler for the call command, replace the previous one,
+* remove the handler when handler is nil.
+ */
+func RegisterCallHandler(command string, handler SrsCallHandler) {
+	callHandlersMtx.Lock()
+	defer callHandlersMtx.Unlock()
+
+	if handler == nil {
+		delete(callHandlers, command)
+		return
+	}
+	callHandlers[command] = handler
+}
+
+func getCallHandler(command string) SrsCallHandler {
+	callHandlersMtx.RLock()
+	defer callHandlersMtx.RUnlock()
+	return callHandlers[command]
+}
+
+/**
+* response the call by the registered handler, _result with null if no handler,
+* _error with NetConnection.Call.Failed if handler failed.
+* @remark no response when transaction id is 0, which means client need no response.
+ */
+func (this *SrsRtmpServer) OnCall(call *packet.SrsCallPacket, streamId int) error {
+	var response amf0.ISrsAmf0Any
+	var err error
+	if handler := getCallHandler(call.CommandName.Value.Value); handler != nil {
+		response, err = handler(call)
+	}
+
+	if call.TransactionId.Value == 0 {
+		return nil
+	}
+
+	pkt := packet.NewSrsCallResPacket(call.TransactionId.Value)
+	pkt.Response = response
+	if err != nil {
+		info := amf0.NewSrsAmf0Object()
+		info.Set(global.StatusLevel, global.StatusLevelError)
+		info.Set(global.StatusCode, global.StatusCodeCallFailed)
+		info.Set(global.StatusDescription, err.Error())
+		pkt.CommandName.Value.Value = amf0.RTMP_AMF0_COMMAND_ERROR
+		pkt.Response = info
+	} else if pkt.Response == nil {
+		pkt.Response = amf0.NewSrsAmf0Null()
+	}
+	return this.Protocol.SendPacket(pkt, int32(streamId))
+}
+
+/**
+* response the releaseStream, FCPublish or FCUnpublish out of the publish flow,
+* for example, the client call releaseStream after identified.
+ */
+func (this *SrsRtmpServer) OnFmleStart(req *packet.SrsFMLEStartPacket) error {
+	pkt := packet.NewSrsFMLEStartResPacket(req.TransactionId.Value)
+	return this.Protocol.SendPacket(pkt, 0)
+}
@@ -0,0 +1,430 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2019 GOSRS(gosrs)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package rtmp
+
+import (
+	"context"
+	"errors"
+	"go_srs/srs/global"
+	"go_srs/srs/protocol/amf0"
+	"go_srs/srs/protocol/packet"
+	"go_srs/srs/protocol/skt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+/**
+* the rtmp client, the client side of SrsRtmpServer,
+* used to connect to the rtmp server to publish or play stream,
+* for example, the relay to other server, the health probe.
+* the flow to play:
+*       HandShake, ConnectApp, CreateStream, Play, then RecvMessage or PlayCycle.
+* the flow to publish:
+*       HandShake, ConnectApp, FmlePublish, then SendMsg.
+ */
+type SrsRtmpClient struct {
+	io         *skt.SrsIOReadWriter
+	Protocol   *SrsProtocol
+	HandShaker HandShaker
+	// the info parsed from url when dial.
+	tcUrl  string
+	app    string
+	stream string
+	// the transaction id of the next request.
+	transactionId float64
+	// the ctx is cancelled when close, to cancel the expecting of responses.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewSrsRtmpClient(io *skt.SrsIOReadWriter) *SrsRtmpClient {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &SrsRtmpClient{
+		io:            io,
+		Protocol:      NewSrsProtocol(io),
+		HandShaker:    NewSrsSimpleHandShake(io),
+		transactionId: 2,
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+}
+
+/**
+* parse the rtmp url to tcUrl, app and stream,
+* for example, rtmp://127.0.0.1/live/livestream?vhost=xxx
+*       tcUrl is rtmp://127.0.0.1:1935/live?vhost=xxx, app is live, stream is livestream.
+ */
+func ParseRtmpUrl(rtmpUrl string) (tcUrl string, app string, stream string, err error) {
+	u, err := url.Parse(rtmpUrl)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	if u.Scheme != "rtmp" {
+		return "", "", "", errors.New("only support rtmp url")
+	}
+
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), global.SRS_CONSTS_RTMP_DEFAULT_PORT)
+	}
+
+	p := strings.Trim(u.Path, "/")
+	if pos := strings.LastIndex(p, "/"); pos > 0 {
+		app = p[:pos]
+		stream = p[pos+1:]
+	} else {
+		app = p
+	}
+
+	if app == "" {
+		return "", "", "", errors.New("no app in rtmp url")
+	}
+
+	tcUrl = "rtmp://" + host + "/" + app
+	if u.RawQuery != "" {
+		tcUrl += "?" + u.RawQuery
+	}
+	return tcUrl, app, stream, nil
+}
+
+/**
+* dial the rtmp url, do handshake and connect app,
+* then user can FmlePublish or CreateStream then Play the Stream().
+ */
+func DialSrsRtmpClient(rtmpUrl string, timeout time.Duration) (*SrsRtmpClient, error) {
+	tcUrl, app, stream, err := ParseRtmpUrl(rtmpUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	u, _ := url.Parse(tcUrl)
+	conn, err := net.DialTimeout("tcp", u.Host, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	client := NewSrsRtmpClient(skt.NewSrsIOReadWriter(conn))
+	client.tcUrl = tcUrl
+	client.app = app
+	client.stream = stream
+
+	if err = client.HandShake(); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	if err = client.ConnectApp(app, tcUrl); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+func (this *SrsRtmpClient) Close() {
+	this.cancel()
+	this.io.Close()
+}
+
+// expect the response of server in timeout, the client must be closed when error.
+func (this *SrsRtmpClient) expect(keys ...SrsExpectKey) (packet.SrsPacket, error) {
+	ctx, cancel := context.WithTimeout(this.ctx, SRS_RTMP_EXPECT_TIMEOUT)
+	defer cancel()
+	return this.Protocol.Expect(ctx, keys...)
+}
+
+func (this *SrsRtmpClient) TcUrl() string {
+	return this.tcUrl
+}
+
+func (this *SrsRtmpClient) App() string {
+	return this.app
+}
+
+func (this *SrsRtmpClient) Stream() string {
+	return this.stream
+}
+
+func (this *SrsRtmpClient) GetRecvBytes() int64 {
+	return this.Protocol.GetRecvBytes()
+}
+
+func (this *SrsRtmpClient) GetSendBytes() int64 {
+	return this.Protocol.GetSendBytes()
+}
+
+func (this *SrsRtmpClient) HandShake() error {
+	return this.HandShaker.HandShakeWithServer()
+}
+
+func (this *SrsRtmpClient) ConnectApp(app string, tcUrl string) error {
+	obj, err := amf0.MarshalAny(&packet.SrsConnectAppCommandObject{
+		App:            app,
+		FlashVer:       "WIN 15,0,0,239",
+		TcUrl:          tcUrl,
+		Capabilities:   239,
+		AudioCodecs:    3575,
+		VideoCodecs:    252,
+		VideoFunction:  1,
+		ObjectEncoding: global.RTMP_SIG_AMF0_VER,
+	})
+	if err != nil {
+		return err
+	}
+
+	pkt := packet.NewSrsConnectAppPacket()
+	pkt.CommandObj = obj.(*amf0.SrsAmf0Object)
+	if err := this.Protocol.SendPacket(pkt, 0); err != nil {
+		return err
+	}
+
+	// the window ack size is used by server to ack our data.
+	ackPkt := packet.NewSrsSetWindowAckSizePacket()
+	ackPkt.AckowledgementWindowSize = 2500000
+	if err := this.Protocol.SendPacket(ackPkt, 0); err != nil {
+		return err
+	}
+
+	res, err := this.expect(SrsExpectKey{amf0.RTMP_AMF0_COMMAND_RESULT, pkt.TransactionId.Value})
+	if err != nil {
+		return err
+	}
+
+	resPkt, ok := res.(*packet.SrsConnectAppResPacket)
+	if !ok {
+		return errors.New("connect app got unknown response.")
+	}
+
+	var code string
+	if err := resPkt.Info.Get(global.StatusCode, &code); err != nil || code != global.StatusCodeConnectSuccess {
+		return errors.New("connect app failed, code=" + code)
+	}
+	return nil
+}
+
+func (this *SrsRtmpClient) SetChunkSize(chunkSize uint32) error {
+	pkt := packet.NewSrsSetChunkSizePacket()
+	pkt.ChunkSize = int32(chunkSize)
+	return this.Protocol.SendPacket(pkt, 0)
+}
+
+func (this *SrsRtmpClient) nextTransactionId() float64 {
+	tid := this.transactionId
+	this.transactionId++
+	return tid
+}
+
+// create stream, return the stream id which is used to play or publish.
+func (this *SrsRtmpClient) CreateStream() (int, error) {
+	pkt := packet.NewSrsCreateStreamPacket()
+	pkt.TransactionId.Value = this.nextTransactionId()
+	if err := this.Protocol.SendPacket(pkt, 0); err != nil {
+		return 0, err
+	}
+
+	res, err := this.expect(SrsExpectKey{amf0.RTMP_AMF0_COMMAND_RESULT, pkt.TransactionId.Value})
+	if err != nil {
+		return 0, err
+	}
+
+	resPkt, ok := res.(*packet.SrsCreateStreamResPacket)
+	if !ok {
+		return 0, errors.New("create stream got unknown response.")
+	}
+	return int(resPkt.StreamId.Value), nil
+}
+
+// play the stream on the stream id, wait for the NetStream.Play.Start.
+func (this *SrsRtmpClient) Play(stream string, streamId int) error {
+	pkt := packet.NewSrsPlayPacket()
+	pkt.StreamName.Value.Value = stream
+	if err := this.Protocol.SendPacket(pkt, int32(streamId)); err != nil {
+		return err
+	}
+
+	// the buffer length of client, in ms.
+	bufPkt := packet.NewSrsUserControlPacket()
+	bufPkt.EventType = packet.SrcPCUCSetBufferLength
+	bufPkt.EventData = int32(streamId)
+	bufPkt.ExtraData = 1000
+	if err := this.Protocol.SendPacket(bufPkt, 0); err != nil {
+		return err
+	}
+
+	return this.expectStatus(global.StatusCodeStreamStart)
+}
+
+// publish the stream on the stream id, the flash style publish.
+func (this *SrsRtmpClient) Publish(stream string, streamId int) error {
+	pkt := packet.NewSrsPublishPacket()
+	pkt.StreamName.Value.Value = stream
+	if err := this.Protocol.SendPacket(pkt, int32(streamId)); err != nil {
+		return err
+	}
+
+	return this.expectStatus(global.StatusCodePublishStart)
+}
+
+/**
+* publish the stream by the FMLE style, return the stream id to send messages:
+*       releaseStream, FCPublish, createStream, publish.
+ */
+func (this *SrsRtmpClient) FmlePublish(stream string) (int, error) {
+	for _, command := range []string{amf0.RTMP_AMF0_COMMAND_RELEASE_STREAM, amf0.RTMP_AMF0_COMMAND_FC_PUBLISH} {
+		pkt := packet.NewSrsFMLEStartPacket(command)
+		pkt.TransactionId.Value = this.nextTransactionId()
+		pkt.StreamName.Value.Value = stream
+		if err := this.Protocol.SendPacket(pkt, 0); err != nil {
+			return 0, err
+		}
+
+		if _, err := this.expect(SrsExpectKey{amf0.RTMP_AMF0_COMMAND_RESULT, pkt.TransactionId.Value}); err != nil {
+			return 0, err
+		}
+	}
+
+	streamId, err := this.CreateStream()
+	if err != nil {
+		return 0, err
+	}
+
+	if err = this.Publish(stream, streamId); err != nil {
+		return 0, err
+	}
+	return streamId, nil
+}
+
+// wait for the onStatus with the code, error if the level of status is error.
+func (this *SrsRtmpClient) expectStatus(expect string) error {
+	for {
+		res, err := this.expect(SrsExpectKey{amf0.RTMP_AMF0_COMMAND_ON_STATUS, SRS_EXPECT_ANY_TID})
+		if err != nil {
+			return err
+		}
+
+		// ignore the onStatus in data message, for example, NetStream.Data.Start.
+		pkt, ok := res.(*packet.SrsOnStatusCallPacket)
+		if !ok {
+			continue
+		}
+
+		var code, level string
+		_ = pkt.Data.Get(global.StatusCode, &code)
+		_ = pkt.Data.Get(global.StatusLevel, &level)
+		if code == expect {
+			return nil
+		}
+
+		if level == global.StatusLevelError {
+			return errors.New("rtmp status error, code=" + code)
+		}
+	}
+}
+
+func (this *SrsRtmpClient) RecvMessage() (*SrsRtmpMessage, error) {
+	return this.Protocol.RecvMessage()
+}
+
+func (this *SrsRtmpClient) DecodeMessage(msg *SrsRtmpMessage) (packet.SrsPacket, error) {
+	return this.Protocol.DecodeMessage(msg)
+}
+
+func (this *SrsRtmpClient) SendPacket(pkt packet.SrsPacket, streamId int) error {
+	return this.Protocol.SendPacket(pkt, int32(streamId))
+}
+
+func (this *SrsRtmpClient) SendMsg(msg *SrsRtmpMessage, streamId int) error {
+	msgs := make([]*SrsRtmpMessage, 1)
+	msgs[0] = msg
+	return this.Protocol.SendMessages(msgs, streamId)
+}
+
+/**
+* the cycle to play, the handler is called for each audio, video and data message,
+* the protocol control messages are processed by protocol and ignored.
+* @remark the handler owns the message, which must release it when done.
+* @return when the handler or recv message error.
+ */
+func (this *SrsRtmpClient) PlayCycle(handler func(msg *SrsRtmpMessage) error) error {
+	for {
+		msg, err := this.RecvMessage()
+		if err != nil {
+			return err
+		}
+
+		header := msg.GetHeader()
+		if !header.IsAV() && !header.IsAmf0Data() && !header.IsAmf3Data() && !header.IsAggregate() {
+			msg.Release()
+			continue
+		}
+
+		if err = handler(msg); err != nil {
+			return err
+		}
+	}
+}
+
+/**
+* start the play cycle in goroutine, the messages are delivered to the returned channel,
+* the channel is closed when play cycle quit, and the error is sent to the error channel.
+* the play cycle quits when ctx done or client closed, and the client is closed when ctx done,
+* so the caller can stop playing without draining the channel.
+ */
+func (this *SrsRtmpClient) PlayChan(ctx context.Context, size int) (<-chan *SrsRtmpMessage, <-chan error) {
+	msgs := make(chan *SrsRtmpMessage, size)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(msgs)
+
+		// close the client to interrupt the recv when ctx done.
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-ctx.Done():
+				this.Close()
+			case <-done:
+			}
+		}()
+
+		err := this.PlayCycle(func(msg *SrsRtmpMessage) error {
+			select {
+			case msgs <- msg:
+				return nil
+			case <-ctx.Done():
+				msg.Release()
+				return ctx.Err()
+			case <-this.ctx.Done():
+				msg.Release()
+				return errors.New("rtmp client closed")
+			}
+		})
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		errs <- err
+	}()
+	return msgs, errs
+}
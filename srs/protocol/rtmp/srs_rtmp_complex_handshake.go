@@ -0,0 +1,284 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2019 GOSRS(gosrs)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package rtmp
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"go_srs/srs/protocol/skt"
+	"math/big"
+	"time"
+)
+
+/**
+* the complex handshake schema of c1s1.
+* schema0:
+*     time: 4bytes
+*     version: 4bytes
+*     key: 764bytes
+*     digest: 764bytes
+* schema1:
+*     time: 4bytes
+*     version: 4bytes
+*     digest: 764bytes
+*     key: 764bytes
+* @see also: http://blog.csdn.net/win_lin/article/details/13006803
+ */
+type srsC1S1Schema int
+
+const (
+	srs_schema0 srsC1S1Schema = 0
+	srs_schema1 srsC1S1Schema = 1
+)
+
+const (
+	SRS_HANDSHAKE_C1S1_SIZE  = 1536
+	SRS_HANDSHAKE_BLOCK_SIZE = 764
+	SRS_HANDSHAKE_KEY_SIZE   = 128
+	SRS_HANDSHAKE_DIGEST_LEN = 32
+)
+
+// the version of s1, FMS 3.5.1 use 0x01000504.
+var srsS1Version = []byte{0x01, 0x00, 0x05, 0x04}
+
+var genuineFPKeyTail = []byte{
+	0xF0, 0xEE, 0xC2, 0x4A, 0x80, 0x68, 0xBE, 0xE8,
+	0x2E, 0x00, 0xD0, 0xD1, 0x02, 0x9E, 0x7E, 0x57,
+	0x6E, 0xEC, 0x5D, 0x2D, 0x29, 0x80, 0x6F, 0xAB,
+	0x93, 0xB8, 0xE6, 0x36, 0xCF, 0xEB, 0x31, 0xAE,
+}
+
+// 68bytes, "Genuine Adobe Flash Media Server 001" + 32bytes
+var SrsGenuineFMSKey = append([]byte("Genuine Adobe Flash Media Server 001"), genuineFPKeyTail...)
+
+// 62bytes, "Genuine Adobe Flash Player 001" + 32bytes
+var SrsGenuineFPKey = append([]byte("Genuine Adobe Flash Player 001"), genuineFPKeyTail...)
+
+// the 1024bits prime of the Diffie-Hellman group 2, @see RFC2409 section 6.2
+var srsDHPrime, _ = new(big.Int).SetString(
+	"FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD1"+
+		"29024E088A67CC74020BBEA63B139B22514A08798E3404DD"+
+		"EF9519B3CD3A431B302B0A6DF25F14374FE1356D6D51C245"+
+		"E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED"+
+		"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE65381"+
+		"FFFFFFFFFFFFFFFF", 16)
+
+var errTrySimpleHandShake = errors.New("complex handshake failed, try simple handshake")
+
+func srsHmacSha256(key []byte, data ...[]byte) []byte {
+	h := hmac.New(sha256.New, key)
+	for i := 0; i < len(data); i++ {
+		h.Write(data[i])
+	}
+	return h.Sum(nil)
+}
+
+// the offset of the 764bytes key block, the 128bytes key is at block[offset:offset+128].
+func srsKeyOffset(block []byte) int {
+	p := block[SRS_HANDSHAKE_BLOCK_SIZE-4:]
+	offset := int(p[0]) + int(p[1]) + int(p[2]) + int(p[3])
+	return offset % (SRS_HANDSHAKE_BLOCK_SIZE - SRS_HANDSHAKE_KEY_SIZE - 4)
+}
+
+// the offset of the 764bytes digest block, the 32bytes digest is at block[4+offset:4+offset+32].
+func srsDigestOffset(block []byte) int {
+	offset := int(block[0]) + int(block[1]) + int(block[2]) + int(block[3])
+	return offset % (SRS_HANDSHAKE_BLOCK_SIZE - SRS_HANDSHAKE_DIGEST_LEN - 4)
+}
+
+// the position of key and digest in the 1536bytes c1s1 for the schema.
+func srsC1S1Positions(c1s1 []byte, schema srsC1S1Schema) (keyPos int, digestPos int) {
+	keyBlock, digestBlock := 8, 8+SRS_HANDSHAKE_BLOCK_SIZE
+	if schema == srs_schema1 {
+		keyBlock, digestBlock = digestBlock, keyBlock
+	}
+
+	keyPos = keyBlock + srsKeyOffset(c1s1[keyBlock:keyBlock+SRS_HANDSHAKE_BLOCK_SIZE])
+	digestPos = digestBlock + 4 + srsDigestOffset(c1s1[digestBlock:digestBlock+SRS_HANDSHAKE_BLOCK_SIZE])
+	return
+}
+
+// calc the digest of c1s1, which is the hmac of all bytes except the 32bytes digest.
+func srsC1S1Digest(c1s1 []byte, digestPos int, key []byte) []byte {
+	return srsHmacSha256(key, c1s1[:digestPos], c1s1[digestPos+SRS_HANDSHAKE_DIGEST_LEN:])
+}
+
+/**
+* the Diffie-Hellman key exchange of complex handshake,
+* the public key is written to the key block of s1.
+ */
+type SrsDH struct {
+	privateKey *big.Int
+	publicKey  *big.Int
+}
+
+func NewSrsDH() (*SrsDH, error) {
+	b := make([]byte, SRS_HANDSHAKE_KEY_SIZE)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+
+	dh := &SrsDH{
+		privateKey: new(big.Int).SetBytes(b),
+	}
+	dh.publicKey = new(big.Int).Exp(big.NewInt(2), dh.privateKey, srsDHPrime)
+	return dh, nil
+}
+
+// the 128bytes public key, left padding with zero.
+func (this *SrsDH) PublicKey() []byte {
+	key := make([]byte, SRS_HANDSHAKE_KEY_SIZE)
+	b := this.publicKey.Bytes()
+	copy(key[SRS_HANDSHAKE_KEY_SIZE-len(b):], b)
+	return key
+}
+
+/**
+* the complex handshake, with the digest and key of schema0/schema1,
+* which is required by flash player to play h.264/aac.
+* when c1 digest validate failed, fallback to the simple handshake.
+ */
+type SrsComplexHandShake struct {
+	HSBytes *SrsHandshakeBytes
+	io      *skt.SrsIOReadWriter
+	simple  *SrsSimpleHandShake
+}
+
+func NewSrsComplexHandShake(io_ *skt.SrsIOReadWriter) *SrsComplexHandShake {
+	simple := NewSrsSimpleHandShake(io_)
+	return &SrsComplexHandShake{
+		HSBytes: simple.HSBytes,
+		io:      io_,
+		simple:  simple,
+	}
+}
+
+func (this *SrsComplexHandShake) HandShakeWithClient() error {
+	err := this.doHandShakeWithClient()
+	if err == errTrySimpleHandShake {
+		// the c0c1 is already read, the simple handshake will reuse it.
+		return this.simple.HandShakeWithClient()
+	}
+	return err
+}
+
+func (this *SrsComplexHandShake) doHandShakeWithClient() error {
+	err := this.HSBytes.ReadC0C1()
+	if err != nil {
+		return err
+	}
+
+	if this.HSBytes.C0C1[0] != 0x03 {
+		return errors.New("only support rtmp plain text.")
+	}
+
+	c1 := this.HSBytes.C0C1[1:]
+	// try schema0, then schema1.
+	schema, c1Digest, ok := this.validateC1(c1)
+	if !ok {
+		return errTrySimpleHandShake
+	}
+
+	s1, err := this.createS1(schema)
+	if err != nil {
+		return err
+	}
+
+	s2, err := this.createS2(c1Digest)
+	if err != nil {
+		return err
+	}
+
+	this.HSBytes.S0S1S2 = make([]byte, 0, 3073)
+	this.HSBytes.S0S1S2 = append(this.HSBytes.S0S1S2, 0x03)
+	this.HSBytes.S0S1S2 = append(this.HSBytes.S0S1S2, s1...)
+	this.HSBytes.S0S1S2 = append(this.HSBytes.S0S1S2, s2...)
+	if _, err = this.io.Write(this.HSBytes.S0S1S2); err != nil {
+		return err
+	}
+
+	// never verify c2, for ffmpeg will failed, it's ok for flash.
+	if 0 != this.HSBytes.ReadC2() {
+		return errors.New("HandShake ReadC2 failed")
+	}
+	return nil
+}
+
+func (this *SrsComplexHandShake) HandShakeWithServer() error {
+	return this.simple.HandShakeWithServer()
+}
+
+func (this *SrsComplexHandShake) validateC1(c1 []byte) (srsC1S1Schema, []byte, bool) {
+	schemas := []srsC1S1Schema{srs_schema0, srs_schema1}
+	for i := 0; i < len(schemas); i++ {
+		_, digestPos := srsC1S1Positions(c1, schemas[i])
+		expect := srsC1S1Digest(c1, digestPos, SrsGenuineFPKey[:30])
+		digest := c1[digestPos : digestPos+SRS_HANDSHAKE_DIGEST_LEN]
+		if bytes.Equal(expect, digest) {
+			return schemas[i], digest, true
+		}
+	}
+	return srs_schema0, nil, false
+}
+
+func (this *SrsComplexHandShake) createS1(schema srsC1S1Schema) ([]byte, error) {
+	s1 := make([]byte, SRS_HANDSHAKE_C1S1_SIZE)
+	if _, err := rand.Read(s1[8:]); err != nil {
+		return nil, err
+	}
+	binary.BigEndian.PutUint32(s1[0:4], uint32(time.Now().Unix()))
+	copy(s1[4:8], srsS1Version)
+
+	dh, err := NewSrsDH()
+	if err != nil {
+		return nil, err
+	}
+
+	// use the same schema with c1.
+	keyPos, digestPos := srsC1S1Positions(s1, schema)
+	copy(s1[keyPos:keyPos+SRS_HANDSHAKE_KEY_SIZE], dh.PublicKey())
+	digest := srsC1S1Digest(s1, digestPos, SrsGenuineFMSKey[:36])
+	copy(s1[digestPos:digestPos+SRS_HANDSHAKE_DIGEST_LEN], digest)
+	return s1, nil
+}
+
+/**
+* s2 is 1536bytes random data, the last 32bytes is the digest:
+*     temp-key = HMACsha256(FMS-Key, c1-digest)
+*     s2-digest = HMACsha256(temp-key, s2[0:1504])
+ */
+func (this *SrsComplexHandShake) createS2(c1Digest []byte) ([]byte, error) {
+	s2 := make([]byte, SRS_HANDSHAKE_C1S1_SIZE)
+	if _, err := rand.Read(s2); err != nil {
+		return nil, err
+	}
+
+	tempKey := srsHmacSha256(SrsGenuineFMSKey, c1Digest)
+	pos := SRS_HANDSHAKE_C1S1_SIZE - SRS_HANDSHAKE_DIGEST_LEN
+	copy(s2[pos:], srsHmacSha256(tempKey, s2[:pos]))
+	return s2, nil
+}
@@ -29,6 +29,7 @@ type SrsRtmpConnType int
 
 const (
 	_                           SrsRtmpConnType = iota
+	SrsRtmpConnUnknown                          = -1
 	SrsRtmpConnPlay                             = 0
 	SrsRtmpConnFMLEPublish                      = 1
 	SrsRtmpConnFlashPublish                     = 2
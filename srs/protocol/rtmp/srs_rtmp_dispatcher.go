@@ -0,0 +1,202 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2019 GOSRS(gosrs)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package rtmp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go_srs/srs/protocol/amf0"
+	"go_srs/srs/protocol/packet"
+	"go_srs/srs/utils"
+	"time"
+)
+
+// the timeout to wait for the expected command of peer.
+const SRS_RTMP_EXPECT_TIMEOUT = 30 * time.Second
+
+// the transaction id which matches any transaction.
+const SRS_EXPECT_ANY_TID float64 = -1
+
+/**
+* the key of the expected command, the command name and the transaction id,
+* for the response of request, the command is _result with the transaction id of request,
+* and the _error of the transaction fails the expect.
+ */
+type SrsExpectKey struct {
+	Command       string
+	TransactionId float64
+}
+
+func (this SrsExpectKey) String() string {
+	if this.TransactionId == SRS_EXPECT_ANY_TID {
+		return this.Command
+	}
+	return fmt.Sprintf("%s(tid=%v)", this.Command, this.TransactionId)
+}
+
+/**
+* the handler for the command packet which is not expected,
+* for example, the server response the call of client when wait for the publish.
+ */
+type SrsUnexpectedHandler func(msg *SrsRtmpMessage, pkt packet.SrsPacket) error
+
+func (this *SrsProtocol) SetUnexpectedHandler(handler SrsUnexpectedHandler) {
+	this.unexpectedHandler = handler
+}
+
+/**
+* read the command name and transaction id of the command or data message,
+* the transaction id is 0 when message has no transaction, for example, the onMetaData.
+ */
+func (this *SrsProtocol) peekCommand(msg *SrsRtmpMessage) (command string, tid float64, err error) {
+	payload := msg.payload
+	if msg.header.IsAmf3Command() || msg.header.IsAmf3Data() {
+		if payload, err = this.convertAmf3Payload(msg); err != nil {
+			return
+		}
+	}
+
+	stream := utils.NewSrsStream(payload)
+	var name amf0.SrsAmf0String
+	if err = name.Decode(stream); err != nil {
+		return
+	}
+
+	var id amf0.SrsAmf0Number
+	if id.Decode(stream) == nil {
+		tid = id.Value
+	}
+	return name.Value.Value, tid, nil
+}
+
+/**
+* read messages until the command matched one of the keys, or the ctx is done,
+* the caller must be the only reader of protocol, the protocol control messages are processed,
+* the unmatched command is passed to the unexpected handler, or dropped if no handler.
+* @remark when ctx is done, the blocking read is interrupted and the connection is broken,
+*       so the connection must be closed when return error.
+ */
+func (this *SrsProtocol) Expect(ctx context.Context, keys ...SrsExpectKey) (packet.SrsPacket, error) {
+	// interrupt the blocking read when ctx is done.
+	done := make(chan bool)
+	exited := make(chan bool)
+	interrupted := false
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			interrupted = true
+			_ = this.io.SetReadDeadline(time.Now())
+		case <-done:
+		}
+	}()
+	defer func() {
+		close(done)
+		<-exited
+		// the ctx may be done after the message matched, clear the deadline for the next read.
+		if interrupted {
+			_ = this.io.SetReadDeadline(time.Time{})
+		}
+	}()
+
+	for {
+		msg, err := this.RecvMessage()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("expect %v failed, %v", keys, ctx.Err())
+			}
+			return nil, err
+		}
+
+		header := msg.GetHeader()
+		// the shared object message is not a command, but should be processed, for example, the chat client.
+		if header.IsAmf0SharedObject() {
+			if err = this.onUnexpectedMessage(msg); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if !header.IsAmf0Command() && !header.IsAmf3Command() && !header.IsAmf0Data() && !header.IsAmf3Data() {
+			continue
+		}
+
+		command, tid, err := this.peekCommand(msg)
+		if err != nil {
+			return nil, err
+		}
+
+		if key, ok := matchExpectKey(keys, command, tid); ok {
+			if command == amf0.RTMP_AMF0_COMMAND_ERROR {
+				delete(this.Requests, tid)
+				return nil, errors.New("peer response _error for " + key.String())
+			}
+
+			pkt, err := this.DecodeMessage(msg)
+			if err != nil {
+				return nil, err
+			}
+
+			if pkt == nil {
+				return nil, errors.New("decode the response failed for " + key.String())
+			}
+			return pkt, nil
+		}
+
+		if err = this.onUnexpectedMessage(msg); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// pass the message not expected to the handler, dropped if no handler.
+func (this *SrsProtocol) onUnexpectedMessage(msg *SrsRtmpMessage) error {
+	pkt, err := this.DecodeMessage(msg)
+	if err != nil {
+		return err
+	}
+
+	if pkt != nil && this.unexpectedHandler != nil {
+		return this.unexpectedHandler(msg, pkt)
+	}
+	return nil
+}
+
+func matchExpectKey(keys []SrsExpectKey, command string, tid float64) (SrsExpectKey, bool) {
+	for _, key := range keys {
+		if key.TransactionId != SRS_EXPECT_ANY_TID && key.TransactionId != tid {
+			continue
+		}
+
+		if key.Command == command {
+			return key, true
+		}
+
+		// the _error of request also terminates the wait for its _result.
+		if key.Command == amf0.RTMP_AMF0_COMMAND_RESULT && command == amf0.RTMP_AMF0_COMMAND_ERROR {
+			return key, true
+		}
+	}
+	return SrsExpectKey{}, false
+}
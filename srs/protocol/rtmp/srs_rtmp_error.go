@@ -0,0 +1,70 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2019 GOSRS(gosrs)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package rtmp
+
+import (
+	"fmt"
+)
+
+/**
+* the error codes of rtmp chunk layer,
+* the connection must be closed when got them, for the chunk stream is corrupt.
+ */
+const (
+	// the fmt of chunk is not allowed, for example, the fresh chunk stream not starts with fmt0.
+	ERROR_RTMP_CHUNK_START = 2001
+	// the control message is corrupt, which can not be decoded.
+	ERROR_RTMP_MESSAGE_DECODE = 2007
+	// the chunk size is out of range.
+	ERROR_RTMP_CHUNK_SIZE = 2010
+	// the payload length of message changed in the chunks of message.
+	ERROR_RTMP_PACKET_SIZE = 2013
+)
+
+/**
+* the error of rtmp protocol, the app can get the code by GetProtocolErrorCode,
+* to distinguish the corrupt peer from the network error.
+ */
+type SrsProtocolError struct {
+	Code    int
+	Message string
+}
+
+func NewSrsProtocolError(code int, message string) *SrsProtocolError {
+	return &SrsProtocolError{
+		Code:    code,
+		Message: message,
+	}
+}
+
+func (this *SrsProtocolError) Error() string {
+	return fmt.Sprintf("rtmp protocol error, code=%d, %s", this.Code, this.Message)
+}
+
+// get the code of protocol error, 0 if not protocol error.
+func GetProtocolErrorCode(err error) int {
+	if pe, ok := err.(*SrsProtocolError); ok {
+		return pe.Code
+	}
+	return 0
+}
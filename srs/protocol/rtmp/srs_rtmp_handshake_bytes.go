@@ -46,9 +46,10 @@ func NewSrsHandshakeBytes(io_ *skt.SrsIOReadWriter) *SrsHandshakeBytes {
 }
 
 func (this *SrsHandshakeBytes) ReadC0C1() error {
+	// the c0c1 maybe already read by the complex handshake,
+	// reuse it when fallback to the simple handshake.
 	if len(this.C0C1) > 0 {
-		err := errors.New("handshake read c0c1 failed, already read")
-		return err
+		return nil
 	}
 
 	this.C0C1 = make([]byte, 1537)
@@ -109,3 +110,51 @@ func (this *SrsHandshakeBytes) ReadC2() int {
 func (this *SrsHandshakeBytes) CheckC2() bool {
 	return bytes.Equal(this.C2, this.S0S1S2[1:1537])
 }
+
+func (this *SrsHandshakeBytes) CreateC0C1() error {
+	if len(this.C0C1) > 0 {
+		return errors.New("already create")
+	}
+	rand.Seed(time.Now().UnixNano())
+	this.C0C1 = make([]byte, 1537)
+	//c0 = version
+	this.C0C1[0] = 0x3
+	//c1 for bytes(timestamp), then 4bytes zero
+	b := utils.Int32ToBytes(int32(time.Now().Unix()), binary.LittleEndian)
+	copy(this.C0C1[1:5], b)
+	//c1 rand bytes
+	if n, err := rand.Read(this.C0C1[9:1537]); err != nil || n != 1528 {
+		return errors.New("create rand number failed")
+	}
+	return nil
+}
+
+func (this *SrsHandshakeBytes) ReadS0S1S2() error {
+	if len(this.S0S1S2) > 0 {
+		return nil
+	}
+
+	this.S0S1S2 = make([]byte, 3073)
+	left := 3073
+	for {
+		n, err := this.io.Read(this.S0S1S2[3073-left : 3073])
+		if err != nil {
+			return err
+		}
+
+		left = left - n
+		if left <= 0 {
+			return nil
+		}
+	}
+}
+
+func (this *SrsHandshakeBytes) CreateC2() error {
+	if len(this.C2) > 0 {
+		return errors.New("already create")
+	}
+	//c2=s1
+	this.C2 = make([]byte, 1536)
+	copy(this.C2, this.S0S1S2[1:1537])
+	return nil
+}
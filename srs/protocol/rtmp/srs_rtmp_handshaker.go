@@ -76,5 +76,28 @@ func (this *SrsSimpleHandShake) HandShakeWithClient() error {
 }
 
 func (this *SrsSimpleHandShake) HandShakeWithServer() error {
+	if err := this.HSBytes.CreateC0C1(); err != nil {
+		return err
+	}
+
+	if _, err := this.io.Write(this.HSBytes.C0C1); err != nil {
+		return err
+	}
+
+	if err := this.HSBytes.ReadS0S1S2(); err != nil {
+		return err
+	}
+
+	if this.HSBytes.S0S1S2[0] != 0x03 {
+		return errors.New("only support rtmp plain text.")
+	}
+
+	if err := this.HSBytes.CreateC2(); err != nil {
+		return err
+	}
+
+	if _, err := this.io.Write(this.HSBytes.C2); err != nil {
+		return err
+	}
 	return nil
 }
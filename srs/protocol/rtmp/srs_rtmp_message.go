@@ -22,6 +22,22 @@ CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
 */
 package rtmp
 
+import (
+	"errors"
+	"go_srs/srs/global"
+	"go_srs/srs/utils"
+)
+
+/**
+* the ownership of message, the payload maybe pooled and shared by the holders:
+* 1. the message returned by RecvMessage is owned by the caller, who must release it,
+*       or pass it to the next owner, for example, the queue of SrsQueueRecvThread,
+*       or the handler of SrsRecvThread and PlayCycle.
+* 2. the holder who keeps the message, for example, the queue of consumer and the gop cache,
+*       retains it when hold and releases it when done, the Copy is retained too.
+* 3. never release the message not owned, which recycles the payload in use by others,
+*       while the message not released is only reclaimed by GC.
+ */
 type SrsRtmpMessage struct {
 	// 4.1. Message Header
 	header SrsMessageHeader
@@ -37,14 +53,75 @@ type SrsRtmpMessage struct {
 	 * user must use SrsProtocol.decode_message to get concrete packet.
 	 * @remark, not all message payload can be decoded to packet. for example,
 	 *       video/audio packet use raw bytes, no video/audio packet.
+	 * @remark, the payload is immutable, which is shared by all consumers.
 	 */
 	payload []byte
+	// the pooled buffer of payload, nil if not pooled.
+	shared *SrsSharedPayload
 }
 
 func NewSrsRtmpMessage() *SrsRtmpMessage {
 	return &SrsRtmpMessage{}
 }
 
+/**
+* create the message to send, for example, the audio/video message to publish,
+* the prefer cid is decided by the message type, the stream id is set when sending.
+ */
+func NewSrsRtmpMessageWithPayload(messageType int8, timestamp int64, payload []byte) *SrsRtmpMessage {
+	msg := &SrsRtmpMessage{}
+	msg.header.messageType = messageType
+	msg.header.timestamp = timestamp
+	msg.header.payloadLength = int32(len(payload))
+	msg.recvedSize = int32(len(payload))
+	msg.payload = payload
+
+	switch messageType {
+	case global.RTMP_MSG_AudioMessage:
+		msg.header.perferCid = global.RTMP_CID_Audio
+	case global.RTMP_MSG_VideoMessage:
+		msg.header.perferCid = global.RTMP_CID_Video
+	case global.RTMP_MSG_AMF0DataMessage, global.RTMP_MSG_AMF3DataMessage:
+		msg.header.perferCid = global.RTMP_CID_OverConnection2
+	default:
+		msg.header.perferCid = global.RTMP_CID_OverStream
+	}
+	return msg
+}
+
+/**
+* copy the message which shares the payload, the header of copy can be changed,
+* for example, the timestamp corrected by jitter of consumer.
+* @remark the copy retains the payload, user must release it when done.
+ */
+func (this *SrsRtmpMessage) Copy() *SrsRtmpMessage {
+	msg := &SrsRtmpMessage{
+		header:     this.header,
+		recvedSize: this.recvedSize,
+		payload:    this.payload,
+		shared:     this.shared,
+	}
+	msg.Retain()
+	return msg
+}
+
+/**
+* retain the payload when hold the message, for example, in the queue of consumer,
+* the payload must be released when done.
+ */
+func (this *SrsRtmpMessage) Retain() {
+	if this.shared != nil {
+		this.shared.retain()
+	}
+}
+
+// release the payload, which is recycled when not used by anyone.
+func (this *SrsRtmpMessage) Release() {
+	if this.shared != nil {
+		this.shared.release()
+	}
+}
+
 func (this *SrsRtmpMessage) DeepCopy() *SrsRtmpMessage {
 	msg := &SrsRtmpMessage{
 		header:     this.header,
@@ -75,10 +152,68 @@ func (this *SrsRtmpMessage) GetPayload() []byte {
 
 func (this *SrsRtmpMessage) ChunkHeader(c0 bool) ([]byte, error) {
 	if c0 {
-		d, err := srs_chunk_header_c0(this.header.perferCid, int32(this.header.timestamp), this.header.payloadLength, this.header.messageType, this.header.streamId)
+		d, err := srs_chunk_header_c0(this.header.perferCid, uint32(this.header.timestamp), this.header.payloadLength, this.header.messageType, this.header.streamId)
 		return d, err
 	} else {
-		d, err := srs_chunk_header_c3(this.header.perferCid, int32(this.header.timestamp))
+		d, err := srs_chunk_header_c3(this.header.perferCid, uint32(this.header.timestamp))
 		return d, err
 	}
 }
+
+/**
+* demux the aggregate message to sub messages, each sub message is a flv tag:
+*     1bytes type, 3bytes data size, 3bytes timestamp, 1byte timestamp extended,
+*     3bytes stream id, data, 4bytes previous tag size.
+* the timestamp of sub messages is rebased onto the timestamp of aggregate message.
+* @remark the sub messages retain the payload, user must release them when done.
+ */
+func (this *SrsRtmpMessage) DemuxAggregate() ([]*SrsRtmpMessage, error) {
+	if !this.header.IsAggregate() {
+		return nil, errors.New("not aggregate message")
+	}
+
+	msgs := make([]*SrsRtmpMessage, 0)
+	stream := utils.NewSrsStream(this.payload)
+	var delta int64 = 0
+	for i := 0; !stream.Empty(); i++ {
+		b, err := stream.ReadBytes(11)
+		if err != nil {
+			return nil, errors.New("invalid aggregate tag header")
+		}
+
+		typ := int8(b[0])
+		dataSize := int32(b[1])<<16 | int32(b[2])<<8 | int32(b[3])
+		timestamp := int64(b[4])<<16 | int64(b[5])<<8 | int64(b[6]) | int64(b[7])<<24
+		streamId := int32(b[8])<<16 | int32(b[9])<<8 | int32(b[10])
+
+		// adjust abs timestamp in aggregate msg.
+		if i == 0 {
+			delta = this.header.timestamp - timestamp
+		}
+		timestamp += delta
+
+		data, err := stream.ReadBytes(uint32(dataSize))
+		if err != nil {
+			return nil, errors.New("invalid aggregate tag data")
+		}
+
+		// previous tag size.
+		if _, err = stream.ReadBytes(4); err != nil {
+			return nil, errors.New("invalid aggregate previous tag size")
+		}
+
+		msg := NewSrsRtmpMessage()
+		msg.header.messageType = typ
+		msg.header.payloadLength = dataSize
+		msg.header.timestamp = timestamp
+		msg.header.streamId = streamId
+		msg.header.perferCid = this.header.perferCid
+		msg.payload = data
+		msg.recvedSize = dataSize
+		// the sub message shares the payload of aggregate message.
+		msg.shared = this.shared
+		msg.Retain()
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
@@ -23,7 +23,9 @@ CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
 package rtmp
 
 import (
-	_ "context"
+	"context"
+	"errors"
+	"fmt"
 	"go_srs/srs/global"
 	"go_srs/srs/protocol/amf0"
 	"go_srs/srs/protocol/packet"
@@ -31,28 +33,49 @@ import (
 	_ "log"
 	_ "net/url"
 	_ "strings"
-	_ "time"
+	"time"
 )
 
+/**
+* the handler for the shared object message of client, which is processed by server
+* when waiting for the command of client, or by the app when publishing or playing.
+ */
+type SrsSharedObjectHandler interface {
+	OnSharedObject(pkt *packet.SrsSharedObjectPacket) error
+	// whether the client is using shared objects, which keeps the client without stream alive.
+	UsingSharedObjects() bool
+}
+
 type SrsRtmpServer struct {
 	io            *skt.SrsIOReadWriter
 	Protocol      *SrsProtocol
-	HandShaker    *SrsSimpleHandShake
+	HandShaker    HandShaker
 	IOErrListener skt.SrsIOErrListener
+	// the ctx is cancelled when close, to cancel the expecting of commands.
+	ctx    context.Context
+	cancel context.CancelFunc
+	// the handler of shared object, nil to ignore the shared object messages.
+	soHandler SrsSharedObjectHandler
 }
 
 func NewSrsRtmpServer(io *skt.SrsIOReadWriter, listener skt.SrsIOErrListener) *SrsRtmpServer {
 	//io_ := skt.NewSrsIOReadWriter(conn)
 	//io_ = io
-	return &SrsRtmpServer{
+	ctx, cancel := context.WithCancel(context.Background())
+	server := &SrsRtmpServer{
 		io:            io,
 		Protocol:      NewSrsProtocol(io),
-		HandShaker:    NewSrsSimpleHandShake(io),
+		HandShaker:    NewSrsComplexHandShake(io),
 		IOErrListener: listener,
+		ctx:           ctx,
+		cancel:        cancel,
 	}
+	server.Protocol.SetUnexpectedHandler(server.onUnexpectedPacket)
+	return server
 }
 
 func (this *SrsRtmpServer) Close() {
+	this.cancel()
 	this.io.Close()
 }
 
@@ -60,17 +83,94 @@ func (this *SrsRtmpServer) GetClientIP() string {
 	return this.io.GetClientIP()
 }
 
+func (this *SrsRtmpServer) GetRecvBytes() int64 {
+	return this.Protocol.GetRecvBytes()
+}
+
+func (this *SrsRtmpServer) GetSendBytes() int64 {
+	return this.Protocol.GetSendBytes()
+}
+
+func (this *SrsRtmpServer) GetPeerAckedBytes() uint32 {
+	return this.Protocol.GetPeerAckedBytes()
+}
+
+func (this *SrsRtmpServer) SendPingRequest() error {
+	return this.Protocol.SendPingRequest()
+}
+
+func (this *SrsRtmpServer) GetRtt() time.Duration {
+	return this.Protocol.GetRtt()
+}
+
+func (this *SrsRtmpServer) GetLastPingResponse() time.Time {
+	return this.Protocol.GetLastPingResponse()
+}
+
 func (this *SrsRtmpServer) HandShake() error {
 	err := this.HandShaker.HandShakeWithClient()
 	return err
 }
 
 func (this *SrsRtmpServer) ConnectApp() (packet.SrsPacket, error) {
-	connPacket := packet.NewSrsConnectAppPacket()
-	if err := this.Protocol.ExpectMessage(connPacket); err != nil {
-		return nil, err
+	return this.expect(SrsExpectKey{amf0.RTMP_AMF0_COMMAND_CONNECT, SRS_EXPECT_ANY_TID})
+}
+
+func (this *SrsRtmpServer) SetSharedObjectHandler(handler SrsSharedObjectHandler) {
+	this.soHandler = handler
+}
+
+// send the shared object message to client, over the NetConnection.
+func (this *SrsRtmpServer) SendSharedObject(pkt *packet.SrsSharedObjectPacket) error {
+	return this.Protocol.SendPacket(pkt, 0)
+}
+
+// expect the commands of client in timeout, the connection must be closed when error.
+func (this *SrsRtmpServer) expect(keys ...SrsExpectKey) (packet.SrsPacket, error) {
+	ctx, cancel := context.WithCancel(this.ctx)
+	defer cancel()
+
+	go this.expectTimeout(ctx, cancel)
+	return this.Protocol.Expect(ctx, keys...)
+}
+
+/**
+* cancel the expect when timeout, while the client using shared objects is kept alive,
+* for example, the chat client never publish or play stream.
+ */
+func (this *SrsRtmpServer) expectTimeout(ctx context.Context, cancel context.CancelFunc) {
+	timer := time.NewTimer(SRS_RTMP_EXPECT_TIMEOUT)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		if this.soHandler == nil || !this.soHandler.UsingSharedObjects() {
+			cancel()
+			return
+		}
+		timer.Reset(SRS_RTMP_EXPECT_TIMEOUT)
+	}
+}
+
+/**
+* response the call of client which is not expected, for example, the getStreamLength of flash player,
+* and the shared object message of client.
+ */
+func (this *SrsRtmpServer) onUnexpectedPacket(msg *SrsRtmpMessage, pkt packet.SrsPacket) error {
+	switch p := pkt.(type) {
+	case *packet.SrsCallPacket:
+		return this.OnCall(p, int(msg.GetHeader().GetStreamId()))
+	case *packet.SrsSharedObjectPacket:
+		if this.soHandler != nil {
+			return this.soHandler.OnSharedObject(p)
+		}
 	}
-	return connPacket, nil
+	return nil
 }
 
 func (this *SrsRtmpServer) RecvMessage() (*SrsRtmpMessage, error) {
@@ -88,102 +188,57 @@ func (this *SrsRtmpServer) OnRecvError(err error) {
 }
 
 func (this *SrsRtmpServer) IdentifyClient(streamId int) (SrsRtmpConnType, string, float64, error) {
-	var typ SrsRtmpConnType
-	var streamname string
-	var duration float64
-	for {
-		msg, err := this.Protocol.RecvMessage()
-		if err != nil {
-			continue
-		}
-		header := msg.GetHeader()
-		if header.IsAckledgement() || header.IsSetChunkSize() || header.IsWindowAckledgementSize() || header.IsUserControlMessage() {
-			continue
-		}
-
-		if !header.IsAmf0Command() && !header.IsAmf3Command() {
-			continue
-		}
+	pkt, err := this.expect(
+		SrsExpectKey{amf0.RTMP_AMF0_COMMAND_CREATE_STREAM, SRS_EXPECT_ANY_TID},
+		SrsExpectKey{amf0.RTMP_AMF0_COMMAND_RELEASE_STREAM, SRS_EXPECT_ANY_TID},
+		SrsExpectKey{amf0.RTMP_AMF0_COMMAND_FC_PUBLISH, SRS_EXPECT_ANY_TID},
+		SrsExpectKey{amf0.RTMP_AMF0_COMMAND_PLAY, SRS_EXPECT_ANY_TID},
+	)
+	if err != nil {
+		return SrsRtmpConnUnknown, "", 0, err
+	}
 
-		pkt, err := this.Protocol.DecodeMessage(msg)
-		switch pkt.(type) {
-		//todo
-		case *packet.SrsCreateStreamPacket:
-			{
-				typ, streamname, duration, err = this.identifyCreateStreamClient(pkt.(*packet.SrsCreateStreamPacket), streamId)
-				return typ, streamname, duration, err
-			}
-		case *packet.SrsFMLEStartPacket:
-			{
-				typ, streamname, err = this.identifyFmlePublishClient(pkt.(*packet.SrsFMLEStartPacket))
-				if err != nil {
-					return typ, streamname, 0, nil
-				}
-				return typ, streamname, 0, nil
-			}
-		case *packet.SrsPlayPacket:
-			{
-				typ, streamname, duration, err = this.identifyPlayclient(pkt.(*packet.SrsPlayPacket))
-				return typ, streamname, duration, err
-			}
-		}
-		return typ, streamname, 0, nil
+	switch pkt.(type) {
+	case *packet.SrsCreateStreamPacket:
+		return this.identifyCreateStreamClient(pkt.(*packet.SrsCreateStreamPacket), streamId)
+	case *packet.SrsFMLEStartPacket:
+		typ, streamname, err := this.identifyFmlePublishClient(pkt.(*packet.SrsFMLEStartPacket))
+		return typ, streamname, 0, err
+	case *packet.SrsPlayPacket:
+		return this.identifyPlayclient(pkt.(*packet.SrsPlayPacket))
 	}
-	_ = typ
-	return typ, streamname, 0, nil
+	return SrsRtmpConnUnknown, "", 0, errors.New("identify client got unknown packet.")
 }
 
 func (this *SrsRtmpServer) identifyCreateStreamClient(req *packet.SrsCreateStreamPacket, streamId int) (SrsRtmpConnType, string, float64, error) {
-	typ := SrsRtmpConnType(SrsRtmpConnFMLEPublish)
 	resPkt := packet.NewSrsCreateStreamResPacket(req.TransactionId.GetValue().(float64), float64(streamId))
-	err := this.Protocol.SendPacket(resPkt, 0)
-	var streamname string
-	var duration float64
-	if err != nil {
-		return SrsRtmpConnType(SrsRtmpConnFMLEPublish), "", 0, err
+	if err := this.Protocol.SendPacket(resPkt, 0); err != nil {
+		return SrsRtmpConnUnknown, "", 0, err
 	}
 
-	for {
-		msg, err := this.Protocol.RecvMessage()
-		if err != nil {
-			continue
-		}
-		header := msg.GetHeader()
-		if header.IsAckledgement() || header.IsSetChunkSize() || header.IsWindowAckledgementSize() || header.IsUserControlMessage() {
-			continue
-		}
-
-		if !header.IsAmf0Command() && !header.IsAmf3Command() {
-			continue
-		}
+	pkt, err := this.expect(
+		SrsExpectKey{amf0.RTMP_AMF0_COMMAND_PLAY, SRS_EXPECT_ANY_TID},
+		SrsExpectKey{amf0.RTMP_AMF0_COMMAND_CREATE_STREAM, SRS_EXPECT_ANY_TID},
+		SrsExpectKey{amf0.RTMP_AMF0_COMMAND_FC_PUBLISH, SRS_EXPECT_ANY_TID},
+		SrsExpectKey{amf0.RTMP_AMF0_COMMAND_PUBLISH, SRS_EXPECT_ANY_TID},
+	)
+	if err != nil {
+		return SrsRtmpConnUnknown, "", 0, err
+	}
 
-		pkt, err := this.Protocol.DecodeMessage(msg)
-		switch pkt.(type) {
-		case *packet.SrsPlayPacket:
-			{
-				typ, streamname, duration, err = this.identifyPlayclient(pkt.(*packet.SrsPlayPacket))
-				return typ, streamname, duration, err
-			}
-		case *packet.SrsCreateStreamPacket:
-			{
-				typ, streamname, duration, err = this.identifyCreateStreamClient(pkt.(*packet.SrsCreateStreamPacket), streamId)
-				return typ, streamname, duration, err
-			}
-		case *packet.SrsFMLEStartPacket:
-			{
-				typ, streamname, err = this.identifyFmlePublishClient(pkt.(*packet.SrsFMLEStartPacket))
-				if err != nil {
-					return typ, streamname, 0, nil
-				}
-				return typ, streamname, 0, nil
-			}
-			//todo
-			//identify_haivision_publish_client
-			//identify_flash_publish_client
-		}
+	switch pkt.(type) {
+	case *packet.SrsPlayPacket:
+		return this.identifyPlayclient(pkt.(*packet.SrsPlayPacket))
+	case *packet.SrsCreateStreamPacket:
+		return this.identifyCreateStreamClient(pkt.(*packet.SrsCreateStreamPacket), streamId)
+	case *packet.SrsFMLEStartPacket:
+		typ, streamname, err := this.identifyHaivisionPublishClient(pkt.(*packet.SrsFMLEStartPacket))
+		return typ, streamname, 0, err
+	case *packet.SrsPublishPacket:
+		typ, streamname := this.identifyFlashPublishClient(pkt.(*packet.SrsPublishPacket))
+		return typ, streamname, 0, nil
 	}
-	_ = typ
-	return typ, streamname, 0, nil
+	return SrsRtmpConnUnknown, "", 0, errors.New("identify create stream client got unknown packet.")
 }
 
 func (this *SrsRtmpServer) identifyPlayclient(pkt *packet.SrsPlayPacket) (SrsRtmpConnType, string, float64, error) {
@@ -196,6 +251,11 @@ func (this *SrsRtmpServer) SendMsg(msg *SrsRtmpMessage, streamId int) error {
 	return this.Protocol.SendMessages(msgs, streamId)
 }
 
+// send the messages in a merged write.
+func (this *SrsRtmpServer) SendMessages(msgs []*SrsRtmpMessage, streamId int) error {
+	return this.Protocol.SendMessages(msgs, streamId)
+}
+
 func (this *SrsRtmpServer) identifyFmlePublishClient(req *packet.SrsFMLEStartPacket) (SrsRtmpConnType, string, error) {
 	typ := SrsRtmpConnType(SrsRtmpConnFMLEPublish)
 	pkt := packet.NewSrsFMLEStartResPacket(req.TransactionId.Value)
@@ -206,6 +266,28 @@ func (this *SrsRtmpServer) identifyFmlePublishClient(req *packet.SrsFMLEStartPac
 	return typ, req.StreamName.Value.Value, nil
 }
 
+/**
+* the flash publish client, createStream then publish directly,
+* for example, the flash NetStream.publish.
+ */
+func (this *SrsRtmpServer) identifyFlashPublishClient(req *packet.SrsPublishPacket) (SrsRtmpConnType, string) {
+	return SrsRtmpConnFlashPublish, req.StreamName.Value.Value
+}
+
+/**
+* the haivision publish client, createStream then FCPublish and publish,
+* response the FCPublish here, the publish is expected when start publish.
+ */
+func (this *SrsRtmpServer) identifyHaivisionPublishClient(req *packet.SrsFMLEStartPacket) (SrsRtmpConnType, string, error) {
+	typ := SrsRtmpConnType(SrsRtmpConnHaivisionPublish)
+	pkt := packet.NewSrsFMLEStartResPacket(req.TransactionId.Value)
+	err := this.Protocol.SendPacket(pkt, 0)
+	if err != nil {
+		return typ, req.StreamName.Value.Value, err
+	}
+	return typ, req.StreamName.Value.Value, nil
+}
+
 func (this *SrsRtmpServer) StartPlay(streamId int) error {
 	// StreamBegin
 	pkt := packet.NewSrsUserControlPacket()
@@ -287,6 +369,11 @@ func (this *SrsRtmpServer) SetPeerBandwidth(bandwidth int, typ int8) error {
 }
 
 func (this *SrsRtmpServer) ResponseConnectApp(objectEncoding float64) error {
+	// only amf0 and amf3 are supported, response amf3 only when client request it.
+	if objectEncoding != global.RTMP_SIG_AMF3_VER {
+		objectEncoding = global.RTMP_SIG_AMF0_VER
+	}
+
 	pkt := packet.NewSrsConnectAppResPacket()
 	_ = pkt
 	pkt.Props.Set("fmsVer", "FMS/"+global.RTMP_SIG_FMS_VER)
@@ -325,16 +412,56 @@ func (this *SrsRtmpServer) OnBwDone() error {
 }
 
 func (this *SrsRtmpServer) StartFmlePublish(streamId int) error {
+	if err := this.expectFmlePublish(streamId); err != nil {
+		return err
+	}
+
+	// publish response onFCPublish(NetStream.Publish.Start)
+	{
+		statusPacket := packet.NewSrsOnStatusCallPacket()
+		statusPacket.CommandName.Value.Value = global.RTMP_AMF0_COMMAND_ON_FC_PUBLISH
+		statusPacket.Data.Set(global.StatusCode, global.StatusCodePublishStart)
+		statusPacket.Data.Set(global.StatusDescription, "Started publishing stream.")
+		err := this.Protocol.SendPacket(statusPacket, 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	{
+		statusPacket := packet.NewSrsOnStatusCallPacket()
+		statusPacket.Data.Set(global.StatusLevel, global.StatusLevelStatus)
+		statusPacket.Data.Set(global.StatusCode, global.StatusCodePublishStart)
+		statusPacket.Data.Set(global.StatusDescription, "Started publishing stream.")
+		statusPacket.Data.Set(global.StatusClientId, global.RTMP_SIG_CLIENT_ID)
+		err := this.Protocol.SendPacket(statusPacket, 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// expect the FCPublish, createStream and publish of fmle, response the FCPublish and createStream.
+func (this *SrsRtmpServer) expectFmlePublish(streamId int) error {
 	// FCPublish
 	var fc_publish_tid float64 = 0
 	{
-		startPkt := packet.NewSrsFMLEStartPacket("")
-		if err := this.Protocol.ExpectMessage(startPkt); err != nil {
+		pkt, err := this.expect(
+			SrsExpectKey{amf0.RTMP_AMF0_COMMAND_RELEASE_STREAM, SRS_EXPECT_ANY_TID},
+			SrsExpectKey{amf0.RTMP_AMF0_COMMAND_FC_PUBLISH, SRS_EXPECT_ANY_TID},
+		)
+		if err != nil {
 			return err
 		}
+		startPkt, ok := pkt.(*packet.SrsFMLEStartPacket)
+		if !ok {
+			return errors.New("expect FCPublish got unknown packet.")
+		}
 		fc_publish_tid = startPkt.TransactionId.GetValue().(float64)
 		startResPkt := packet.NewSrsFMLEStartResPacket(fc_publish_tid)
-		err := this.Protocol.SendPacket(startResPkt, 0)
+		err = this.Protocol.SendPacket(startResPkt, 0)
 		if err != nil {
 			return err
 		}
@@ -342,13 +469,17 @@ func (this *SrsRtmpServer) StartFmlePublish(streamId int) error {
 
 	var create_stream_tid float64 = 0
 	{
-		createPkt := packet.NewSrsCreateStreamPacket()
-		if err := this.Protocol.ExpectMessage(createPkt); err != nil {
+		pkt, err := this.expect(SrsExpectKey{amf0.RTMP_AMF0_COMMAND_CREATE_STREAM, SRS_EXPECT_ANY_TID})
+		if err != nil {
 			return err
 		}
+		createPkt, ok := pkt.(*packet.SrsCreateStreamPacket)
+		if !ok {
+			return errors.New("expect createStream got unknown packet.")
+		}
 		create_stream_tid = createPkt.TransactionId.Value
 		createResPkt := packet.NewSrsCreateStreamResPacket(create_stream_tid, float64(streamId))
-		err := this.Protocol.SendPacket(createResPkt, 0)
+		err = this.Protocol.SendPacket(createResPkt, 0)
 		if err != nil {
 			return err
 		}
@@ -356,8 +487,35 @@ func (this *SrsRtmpServer) StartFmlePublish(streamId int) error {
 
 	// publish
 	{
-		publishPacket := packet.NewSrsPublishPacket()
-		if err := this.Protocol.ExpectMessage(publishPacket); err != nil {
+		if _, err := this.expect(SrsExpectKey{amf0.RTMP_AMF0_COMMAND_PUBLISH, SRS_EXPECT_ANY_TID}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/**
+* start flash publish, the publish packet is already received when identify client,
+* response onStatus(NetStream.Publish.Start) only.
+ */
+func (this *SrsRtmpServer) StartFlashPublish(streamId int) error {
+	statusPacket := packet.NewSrsOnStatusCallPacket()
+	statusPacket.Data.Set(global.StatusLevel, global.StatusLevelStatus)
+	statusPacket.Data.Set(global.StatusCode, global.StatusCodePublishStart)
+	statusPacket.Data.Set(global.StatusDescription, "Started publishing stream.")
+	statusPacket.Data.Set(global.StatusClientId, global.RTMP_SIG_CLIENT_ID)
+	return this.Protocol.SendPacket(statusPacket, int32(streamId))
+}
+
+/**
+* start haivision publish, the FCPublish is already responsed when identify client,
+* expect the publish, then response onFCPublish and onStatus(NetStream.Publish.Start).
+ */
+func (this *SrsRtmpServer) StartHaivisionPublish(streamId int) error {
+	// publish
+	{
+		if _, err := this.expect(SrsExpectKey{amf0.RTMP_AMF0_COMMAND_PUBLISH, SRS_EXPECT_ANY_TID}); err != nil {
 			return err
 		}
 	}
@@ -368,19 +526,20 @@ func (this *SrsRtmpServer) StartFmlePublish(streamId int) error {
 		statusPacket.CommandName.Value.Value = global.RTMP_AMF0_COMMAND_ON_FC_PUBLISH
 		statusPacket.Data.Set(global.StatusCode, global.StatusCodePublishStart)
 		statusPacket.Data.Set(global.StatusDescription, "Started publishing stream.")
-		err := this.Protocol.SendPacket(statusPacket, 0)
+		err := this.Protocol.SendPacket(statusPacket, int32(streamId))
 		if err != nil {
 			return err
 		}
 	}
 
+	// publish response onStatus(NetStream.Publish.Start)
 	{
 		statusPacket := packet.NewSrsOnStatusCallPacket()
 		statusPacket.Data.Set(global.StatusLevel, global.StatusLevelStatus)
 		statusPacket.Data.Set(global.StatusCode, global.StatusCodePublishStart)
 		statusPacket.Data.Set(global.StatusDescription, "Started publishing stream.")
 		statusPacket.Data.Set(global.StatusClientId, global.RTMP_SIG_CLIENT_ID)
-		err := this.Protocol.SendPacket(statusPacket, 0)
+		err := this.Protocol.SendPacket(statusPacket, int32(streamId))
 		if err != nil {
 			return err
 		}
@@ -388,3 +547,68 @@ func (this *SrsRtmpServer) StartFmlePublish(streamId int) error {
 
 	return nil
 }
+
+/**
+* reject the publish when stream is busy, response onStatus(NetStream.Publish.BadName),
+* expect the publish of fmle and haivision first, the publish of flash is received when identify client.
+ */
+func (this *SrsRtmpServer) RejectPublish(typ SrsRtmpConnType, streamId int) error {
+	switch typ {
+	case SrsRtmpConnFMLEPublish:
+		if err := this.expectFmlePublish(streamId); err != nil {
+			return err
+		}
+	case SrsRtmpConnHaivisionPublish:
+		if _, err := this.expect(SrsExpectKey{amf0.RTMP_AMF0_COMMAND_PUBLISH, SRS_EXPECT_ANY_TID}); err != nil {
+			return err
+		}
+	}
+
+	statusPacket := packet.NewSrsOnStatusCallPacket()
+	statusPacket.Data.Set(global.StatusLevel, global.StatusLevelError)
+	statusPacket.Data.Set(global.StatusCode, global.StatusCodePublishBadName)
+	statusPacket.Data.Set(global.StatusDescription, "Stream already publishing.")
+	statusPacket.Data.Set(global.StatusClientId, global.RTMP_SIG_CLIENT_ID)
+	return this.Protocol.SendPacket(statusPacket, int32(streamId))
+}
+
+/**
+* when client pause or unpause the play,
+* for pause, response onStatus(NetStream.Pause.Notify) and StreamEOF,
+* for unpause, response onStatus(NetStream.Unpause.Notify) and StreamBegin.
+ */
+func (this *SrsRtmpServer) OnPlayClientPause(streamId int, isPause bool) error {
+	code, description := global.StatusCodeStreamPause, "Paused stream."
+	var eventType int16 = global.SrcPCUCStreamEOF
+	if !isPause {
+		code, description = global.StatusCodeStreamUnpause, "Unpaused stream."
+		eventType = global.SrcPCUCStreamBegin
+	}
+
+	statusPkt := packet.NewSrsOnStatusCallPacket()
+	statusPkt.Data.Set(global.StatusLevel, global.StatusLevelStatus)
+	statusPkt.Data.Set(global.StatusCode, code)
+	statusPkt.Data.Set(global.StatusDescription, description)
+	if err := this.Protocol.SendPacket(statusPkt, int32(streamId)); err != nil {
+		return err
+	}
+
+	pkt := packet.NewSrsUserControlPacket()
+	pkt.EventType = eventType
+	pkt.EventData = int32(streamId)
+	return this.Protocol.SendPacket(pkt, 0)
+}
+
+/**
+* when client seek the play, response onStatus(NetStream.Seek.Notify),
+* the live stream can not seek, so the play continue from the live.
+ */
+func (this *SrsRtmpServer) OnPlayClientSeek(streamId int, offset float64) error {
+	statusPkt := packet.NewSrsOnStatusCallPacket()
+	statusPkt.Data.Set(global.StatusLevel, global.StatusLevelStatus)
+	statusPkt.Data.Set(global.StatusCode, global.StatusCodeStreamSeek)
+	statusPkt.Data.Set(global.StatusDescription, fmt.Sprintf("Seeking %.0f (stream ID: %d).", offset, streamId))
+	statusPkt.Data.Set(global.StatusDetails, "stream")
+	statusPkt.Data.Set(global.StatusClientId, global.RTMP_SIG_CLIENT_ID)
+	return this.Protocol.SendPacket(statusPkt, int32(streamId))
+}
@@ -0,0 +1,100 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2019 GOSRS(gosrs)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package rtmp
+
+import (
+	log "github.com/sirupsen/logrus"
+	"sync"
+	"sync/atomic"
+)
+
+/**
+* the payload buffers are pooled by size class of power of 2,
+* from 128B to 4MB, the larger payload is allocated and not pooled.
+ */
+const (
+	SRS_PAYLOAD_POOL_MIN_SHIFT = 7
+	SRS_PAYLOAD_POOL_MAX_SHIFT = 22
+)
+
+var payloadPools [SRS_PAYLOAD_POOL_MAX_SHIFT - SRS_PAYLOAD_POOL_MIN_SHIFT + 1]sync.Pool
+
+/**
+* the payload shared by the messages, for example, the message fan out to all consumers
+* and the sub messages of aggregate message, the payload is immutable when shared.
+* each holder of message retains it and releases it when done, the buffer is returned
+* to the pool when the last holder released it.
+* @remark the holder which never releases only makes the buffer reclaimed by GC,
+*       while the extra release recycles the buffer in use, so it's logged and leaked.
+ */
+type SrsSharedPayload struct {
+	data []byte
+	refs int32
+	// the index of pool, -1 for not pooled.
+	pool int
+}
+
+// get a payload of size from pool, which is owned by the caller.
+func NewSrsSharedPayload(size int) *SrsSharedPayload {
+	pool := payloadPoolIndex(size)
+	if pool < 0 {
+		return &SrsSharedPayload{data: make([]byte, size), refs: 1, pool: -1}
+	}
+
+	p, _ := payloadPools[pool].Get().(*SrsSharedPayload)
+	if p == nil {
+		p = &SrsSharedPayload{data: make([]byte, 1<<uint(pool+SRS_PAYLOAD_POOL_MIN_SHIFT)), pool: pool}
+	}
+	p.data = p.data[:size]
+	p.refs = 1
+	return p
+}
+
+func payloadPoolIndex(size int) int {
+	for shift := SRS_PAYLOAD_POOL_MIN_SHIFT; shift <= SRS_PAYLOAD_POOL_MAX_SHIFT; shift++ {
+		if size <= 1<<uint(shift) {
+			return shift - SRS_PAYLOAD_POOL_MIN_SHIFT
+		}
+	}
+	return -1
+}
+
+func (this *SrsSharedPayload) retain() {
+	// the buffer maybe recycled and reused, for the holder released too early.
+	if refs := atomic.AddInt32(&this.refs, 1); refs <= 1 {
+		log.Error("rtmp shared payload retained after released, refs=", refs)
+	}
+}
+
+func (this *SrsSharedPayload) release() {
+	refs := atomic.AddInt32(&this.refs, -1)
+	if refs < 0 {
+		// never recycle the buffer again, it's reclaimed by GC.
+		log.Error("rtmp shared payload released too many times, refs=", refs)
+		return
+	}
+
+	if refs == 0 && this.pool >= 0 {
+		payloadPools[this.pool].Put(this)
+	}
+}
@@ -27,6 +27,7 @@ import (
 	_ "fmt"
 	"io"
 	"net"
+	"sync/atomic"
 	"time"
 )
 
@@ -52,11 +53,11 @@ func NewSrsIOReadWriter(c net.Conn) *SrsIOReadWriter {
 }
 
 func (this *SrsIOReadWriter) GetRecvBytes() int64 {
-	return this.readBytes
+	return atomic.LoadInt64(&this.readBytes)
 }
 
 func (this *SrsIOReadWriter) GetSendBytes() int64 {
-	return this.writeBytes
+	return atomic.LoadInt64(&this.writeBytes)
 }
 
 func (this *SrsIOReadWriter) GetClientIP() string {
@@ -66,7 +67,7 @@ func (this *SrsIOReadWriter) GetClientIP() string {
 func (this *SrsIOReadWriter) Read(b []byte) (int, error) {
 	c, e := this.IOReader.Read(b)
 	if e == nil {
-		this.readBytes += int64(c)
+		atomic.AddInt64(&this.readBytes, int64(c))
 	}
 	return c, e
 }
@@ -75,11 +76,21 @@ func (this *SrsIOReadWriter) Close() {
 	this.conn.Close()
 }
 
+// set the deadline of read, the blocking read return error when deadline exceed.
+func (this *SrsIOReadWriter) SetReadDeadline(t time.Time) error {
+	return this.conn.SetReadDeadline(t)
+}
+
+// peek the n bytes without consume, the bytes are valid until the next read.
+func (this *SrsIOReadWriter) Peek(n int) ([]byte, error) {
+	return this.IOReader.Peek(n)
+}
+
 func (this *SrsIOReadWriter) ReadWithTimeout(b []byte, timeoutms uint32) (int, error) {
 	this.conn.SetReadDeadline(time.Now().Add(time.Millisecond * time.Duration(timeoutms)))
 	c, e := this.IOReader.Read(b)
 	if e == nil {
-		this.readBytes += int64(c)
+		atomic.AddInt64(&this.readBytes, int64(c))
 	}
 	return c, e
 }
@@ -93,7 +104,7 @@ func (this *SrsIOReadWriter) ReadFully(b []byte, timeoutms uint32) (int, error)
 			return 0, err
 		}
 
-		this.readBytes += int64(n)
+		atomic.AddInt64(&this.readBytes, int64(n))
 		left = left - n
 		if left <= 0 {
 			return count, nil
@@ -105,7 +116,7 @@ func (this *SrsIOReadWriter) ReadFullyWithTimeout(b []byte, timeoutms uint32) (i
 	this.conn.SetReadDeadline(time.Now().Add(time.Millisecond * time.Duration(timeoutms)))
 	c, e := io.ReadFull(this.conn, b)
 	if e == nil {
-		this.readBytes += int64(c)
+		atomic.AddInt64(&this.readBytes, int64(c))
 	}
 	return c, e
 }
@@ -113,13 +124,24 @@ func (this *SrsIOReadWriter) ReadFullyWithTimeout(b []byte, timeoutms uint32) (i
 func (this *SrsIOReadWriter) Write(b []byte) (int, error) {
 	n, err := this.IOWriter.Write(b)
 	_ = this.IOWriter.Flush()
-	this.writeBytes += int64(n)
+	atomic.AddInt64(&this.writeBytes, int64(n))
+	return n, err
+}
+
+// write the buffers by writev when conn supports, for example, the tcp conn.
+func (this *SrsIOReadWriter) WriteBuffers(bufs *net.Buffers) (int64, error) {
+	if err := this.IOWriter.Flush(); err != nil {
+		return 0, err
+	}
+
+	n, err := bufs.WriteTo(this.conn)
+	atomic.AddInt64(&this.writeBytes, n)
 	return n, err
 }
 
 func (this *SrsIOReadWriter) WriteWithTimeout(b []byte, timeoutms uint32) (int, error) {
 	this.conn.SetWriteDeadline(time.Now().Add(time.Millisecond * time.Duration(timeoutms)))
 	c, e := this.IOWriter.Write(b)
-	this.writeBytes += int64(c)
+	atomic.AddInt64(&this.writeBytes, int64(c))
 	return c, e
 }